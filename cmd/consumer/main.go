@@ -0,0 +1,14 @@
+// QuestFlow submission consumer 的独立启动入口，可按需部署多个实例水平扩展
+package main
+
+import (
+	"flag"
+	"questflow/internal/app"
+)
+
+func main() {
+	configPath := flag.String("config", "./configs/config.yaml", "配置文件路径")
+	flag.Parse()
+
+	app.Run(*configPath, app.RoleConsumer)
+}
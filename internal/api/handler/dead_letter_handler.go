@@ -0,0 +1,98 @@
+// Package handler 存放 HTTP 请求的处理器函数
+package handler
+
+import (
+	"net/http"
+	"questflow/internal/service"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 死信列表的分页参数
+const (
+	defaultDeadLetterPageSize = 20
+	maxDeadLetterPageSize     = 100
+)
+
+// DeadLetterHandler 封装了死信提交管理相关的 HTTP 处理器
+type DeadLetterHandler struct {
+	deadLetterService service.DeadLetterService
+}
+
+// NewDeadLetterHandler 创建一个新的 DeadLetterHandler
+func NewDeadLetterHandler(deadLetterService service.DeadLetterService) *DeadLetterHandler {
+	return &DeadLetterHandler{deadLetterService: deadLetterService}
+}
+
+// ListDeadLetters 处理分页获取表单死信列表的请求，cursor 为上一页返回的 next_cursor
+func (h *DeadLetterHandler) ListDeadLetters(c *gin.Context) {
+	formID, err := getFormIDFromParam(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 4000, "message": "无效的 form_id"})
+		return
+	}
+	count, err := strconv.Atoi(c.DefaultQuery("count", strconv.Itoa(defaultDeadLetterPageSize)))
+	if err != nil || count < 1 || count > maxDeadLetterPageSize {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 4000, "message": "无效的 count"})
+		return
+	}
+	claims, _ := c.Get("user_claims")
+	userClaims := claims.(*service.CustomClaims)
+	messages, err := h.deadLetterService.ListDeadLetters(formID, userClaims.UserID, c.Query("cursor"), count)
+	if err != nil {
+		handleServiceError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 0, "message": "success", "data": messages})
+}
+
+// GetDeadLetter 处理获取单条死信详情的请求
+func (h *DeadLetterHandler) GetDeadLetter(c *gin.Context) {
+	formID, err := getFormIDFromParam(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 4000, "message": "无效的 form_id"})
+		return
+	}
+	claims, _ := c.Get("user_claims")
+	userClaims := claims.(*service.CustomClaims)
+	message, err := h.deadLetterService.GetDeadLetter(formID, userClaims.UserID, c.Param("message_id"))
+	if err != nil {
+		handleServiceError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 0, "message": "success", "data": message})
+}
+
+// RedriveDeadLetter 处理将死信重新投递到提交队列的请求
+func (h *DeadLetterHandler) RedriveDeadLetter(c *gin.Context) {
+	formID, err := getFormIDFromParam(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 4000, "message": "无效的 form_id"})
+		return
+	}
+	claims, _ := c.Get("user_claims")
+	userClaims := claims.(*service.CustomClaims)
+	messageID, err := h.deadLetterService.RedriveDeadLetter(formID, userClaims.UserID, c.Param("message_id"))
+	if err != nil {
+		handleServiceError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 0, "message": "已重新投递", "data": gin.H{"message_id": messageID}})
+}
+
+// DiscardDeadLetter 处理丢弃死信的请求
+func (h *DeadLetterHandler) DiscardDeadLetter(c *gin.Context) {
+	formID, err := getFormIDFromParam(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 4000, "message": "无效的 form_id"})
+		return
+	}
+	claims, _ := c.Get("user_claims")
+	userClaims := claims.(*service.CustomClaims)
+	if err := h.deadLetterService.DiscardDeadLetter(formID, userClaims.UserID, c.Param("message_id")); err != nil {
+		handleServiceError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 0, "message": "已丢弃"})
+}
@@ -0,0 +1,115 @@
+// Package handler 存放 HTTP 请求的处理器函数
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"questflow/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// uploadRequestOverhead 是上传请求中除文件内容以外的 multipart 开销上限
+const uploadRequestOverhead = 1 << 20
+
+// maxFilesPerUploadRequest 是一次上传请求中文件数的硬上限，用于限制请求体的大小
+const maxFilesPerUploadRequest = 10
+
+// FileHandler 封装了文件上传题相关的 HTTP 处理器
+type FileHandler struct {
+	fileService service.FileService
+	formService service.FormService
+}
+
+// NewFileHandler 创建一个新的 FileHandler
+func NewFileHandler(fileService service.FileService, formService service.FormService) *FileHandler {
+	return &FileHandler{fileService: fileService, formService: formService}
+}
+
+// UploadFiles 处理文件上传题的预上传请求：multipart 表单中的 files 字段可以包含多个文件，
+// 返回的文件令牌在提交时作为该问题的答案
+func (h *FileHandler) UploadFiles(c *gin.Context) {
+	formKey := c.Param("form_key")
+	if formKey == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 4000, "message": "缺少 form_key"})
+		return
+	}
+
+	form, err := h.formService.GetPublicFormByKey(formKey)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"code": 4004, "message": "表单不存在"})
+		return
+	}
+
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, service.MaxUploadSize()*maxFilesPerUploadRequest+uploadRequestOverhead)
+	multipartForm, err := c.MultipartForm()
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"code": 4000, "message": "上传的文件过大"})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"code": 4000, "message": "无效的上传请求: " + err.Error()})
+		return
+	}
+	defer multipartForm.RemoveAll()
+
+	files := multipartForm.File["files"]
+	if len(files) > maxFilesPerUploadRequest {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 4000, "message": fmt.Sprintf("一次最多上传 %d 个文件", maxFilesPerUploadRequest)})
+		return
+	}
+
+	infos, err := h.fileService.UploadFiles(form, c.Param("question_id"), c.ClientIP(), files)
+	if err != nil {
+		switch err.Error() {
+		case "question not found":
+			c.JSON(http.StatusNotFound, gin.H{"code": 4004, "message": "问题不存在"})
+		case "question does not accept files":
+			c.JSON(http.StatusBadRequest, gin.H{"code": 4000, "message": "该问题不是文件上传题"})
+		case "no files uploaded":
+			c.JSON(http.StatusBadRequest, gin.H{"code": 4000, "message": "请在 files 字段中上传文件"})
+		case "too many files":
+			c.JSON(http.StatusBadRequest, gin.H{"code": 4000, "message": "上传的文件数超过该问题的限制"})
+		case "file too large":
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"code": 4000, "message": "文件大小超过该问题的限制"})
+		case "file type not allowed":
+			c.JSON(http.StatusUnsupportedMediaType, gin.H{"code": 4000, "message": "该问题不接受此类型的文件"})
+		case "too many uploads":
+			c.JSON(http.StatusTooManyRequests, gin.H{"code": 4029, "message": "上传过于频繁，请稍后再试"})
+		case "form upload quota exceeded":
+			c.JSON(http.StatusTooManyRequests, gin.H{"code": 4029, "message": "该表单的上传量已达到上限，请稍后再试"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"code": 5000, "message": "上传失败", "error": err.Error()})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"code": 0, "message": "success", "data": infos})
+}
+
+// DownloadFile 处理表单创建者下载上传文件的请求
+func (h *FileHandler) DownloadFile(c *gin.Context) {
+	formID, err := getFormIDFromParam(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 4000, "message": "无效的 form_id"})
+		return
+	}
+
+	claims, _ := c.Get("user_claims")
+	userClaims := claims.(*service.CustomClaims)
+
+	reader, file, err := h.fileService.OpenFile(formID, userClaims.UserID, c.Param("token"))
+	if err != nil {
+		handleServiceError(c, err)
+		return
+	}
+	defer reader.Close()
+
+	c.DataFromReader(http.StatusOK, file.Size, file.ContentType, reader, map[string]string{
+		"Content-Disposition":    "attachment; filename*=UTF-8''" + url.PathEscape(file.Name),
+		"X-Content-Type-Options": "nosniff",
+	})
+}
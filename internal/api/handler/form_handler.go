@@ -105,6 +105,11 @@ func (h *FormHandler) CreateForm(c *gin.Context) {
 	definitionJSON := datatypes.JSON(req.Definition)
 	form, err := h.formService.CreateForm(creatorID, req.Title, req.Description, definitionJSON)
 	if err != nil {
+		var definitionErr *service.DefinitionError
+		if errors.As(err, &definitionErr) {
+			c.JSON(http.StatusBadRequest, gin.H{"code": 4000, "message": "表单定义无效", "error": definitionErr.Err.Error()})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"code": 5000, "message": "创建表单失败", "error": err.Error()})
 		return
 	}
@@ -189,19 +194,57 @@ func (h *FormHandler) GetPublicForm(c *gin.Context) {
 		c.JSON(http.StatusNotFound, gin.H{"code": 4004, "message": "表单未找到"})
 		return
 	}
-	c.JSON(http.StatusOK, gin.H{"code": 0, "message": "success", "data": gin.H{"form_key": form.FormKey, "title": form.Title, "description": form.Description, "definition": form.Definition}})
+	// 可选的 page 参数（从 1 开始）用于逐页加载长表单
+	page, err := strconv.Atoi(c.DefaultQuery("page", "0"))
+	if err != nil || page < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 4000, "message": "无效的 page"})
+		return
+	}
+	// 限时或随机出题的表单需要先开始答题，再携带答题令牌获取本次答题的题目
+	definition, progress, err := h.formService.GetPublicDefinition(form, c.Query("attempt_token"), page)
+	if err != nil {
+		switch err.Error() {
+		case "attempt token is required":
+			c.JSON(http.StatusBadRequest, gin.H{"code": 4000, "message": "该表单需要先开始答题"})
+		case "invalid attempt token":
+			c.JSON(http.StatusBadRequest, gin.H{"code": 4000, "message": "答题令牌无效"})
+		case "time limit exceeded":
+			c.JSON(http.StatusForbidden, gin.H{"code": 4003, "message": "已超过答题时间限制"})
+		case "page out of range":
+			c.JSON(http.StatusNotFound, gin.H{"code": 4004, "message": "页码超出范围"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"code": 5000, "message": "获取表单失败", "error": err.Error()})
+		}
+		return
+	}
+	// 隐藏字段和预填答案来自打开表单时 URL 中的查询参数
+	prefill, err := h.formService.GetPrefill(form, c.Request.URL.Query())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 5000, "message": "获取表单失败", "error": err.Error()})
+		return
+	}
+	data := gin.H{"form_key": form.FormKey, "title": form.Title, "description": form.Description, "definition": definition, "prefill": prefill}
+	if progress != nil {
+		data["page"] = progress
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 0, "message": "success", "data": data})
 }
 
-// GetStatistics 处理获取表单统计数据的请求
+// GetStatistics 处理获取表单统计数据的请求，可以通过 version_id 查询参数指定统计的版本
 func (h *FormHandler) GetStatistics(c *gin.Context) {
 	formID, err := getFormIDFromParam(c)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"code": 4000, "message": "无效的 form_id"})
 		return
 	}
+	versionID, err := getVersionIDFromQuery(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 4000, "message": "无效的 version_id"})
+		return
+	}
 	claims, _ := c.Get("user_claims")
 	userClaims := claims.(*service.CustomClaims)
-	stats, err := h.formService.GetFormStatistics(formID, userClaims.UserID)
+	stats, err := h.formService.GetFormStatistics(formID, userClaims.UserID, versionID)
 	if err != nil {
 		handleServiceError(c, err)
 		return
@@ -209,6 +252,28 @@ func (h *FormHandler) GetStatistics(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"code": 0, "message": "success", "data": stats})
 }
 
+// GetItemAnalysis 处理获取考试项目分析（难度、区分度、信度等）的请求，可以通过 version_id 查询参数指定分析的版本
+func (h *FormHandler) GetItemAnalysis(c *gin.Context) {
+	formID, err := getFormIDFromParam(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 4000, "message": "无效的 form_id"})
+		return
+	}
+	versionID, err := getVersionIDFromQuery(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 4000, "message": "无效的 version_id"})
+		return
+	}
+	claims, _ := c.Get("user_claims")
+	userClaims := claims.(*service.CustomClaims)
+	analysis, err := h.formService.GetItemAnalysis(formID, userClaims.UserID, versionID)
+	if err != nil {
+		handleServiceError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 0, "message": "success", "data": analysis})
+}
+
 // GetMyForms 处理获取当前用户创建的表单列表的请求
 func (h *FormHandler) GetMyForms(c *gin.Context) {
 	claims, _ := c.Get("user_claims")
@@ -246,7 +311,22 @@ func getFormIDFromParam(c *gin.Context) (uint, error) {
 	return uint(formID), err
 }
 
+// getVersionIDFromQuery 读取可选的 version_id 查询参数，未提供时返回 0
+func getVersionIDFromQuery(c *gin.Context) (uint, error) {
+	versionIDStr := c.Query("version_id")
+	if versionIDStr == "" {
+		return 0, nil
+	}
+	versionID, err := strconv.ParseUint(versionIDStr, 10, 32)
+	return uint(versionID), err
+}
+
 func handleServiceError(c *gin.Context, err error) {
+	var definitionErr *service.DefinitionError
+	if errors.As(err, &definitionErr) {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 4000, "message": "表单定义无效", "error": definitionErr.Err.Error()})
+		return
+	}
 	switch err.Error() {
 	case "access denied":
 		c.JSON(http.StatusForbidden, gin.H{"code": 4003, "message": "无权操作此表单"})
@@ -254,6 +334,28 @@ func handleServiceError(c *gin.Context, err error) {
 		c.JSON(http.StatusNotFound, gin.H{"code": 4004, "message": "表单未找到"})
 	case "invalid status value":
 		c.JSON(http.StatusBadRequest, gin.H{"code": 4000, "message": "无效的状态值"})
+	case "dead letter not found":
+		c.JSON(http.StatusNotFound, gin.H{"code": 4004, "message": "死信消息未找到"})
+	case "invalid cursor":
+		c.JSON(http.StatusBadRequest, gin.H{"code": 4000, "message": "无效的 cursor"})
+	case "submission not found":
+		c.JSON(http.StatusNotFound, gin.H{"code": 4004, "message": "提交记录未找到"})
+	case "question is not manually graded":
+		c.JSON(http.StatusBadRequest, gin.H{"code": 4000, "message": "该问题不需要人工阅卷"})
+	case "points out of range":
+		c.JSON(http.StatusBadRequest, gin.H{"code": 4000, "message": "分数超出题目分值范围"})
+	case "question was not answered":
+		c.JSON(http.StatusBadRequest, gin.H{"code": 4000, "message": "该提交未作答此题"})
+	case "unknown filter question":
+		c.JSON(http.StatusBadRequest, gin.H{"code": 4000, "message": "筛选条件中的问题不存在"})
+	case "form version not found":
+		c.JSON(http.StatusNotFound, gin.H{"code": 4004, "message": "表单版本未找到"})
+	case "uploaded file not found":
+		c.JSON(http.StatusNotFound, gin.H{"code": 4004, "message": "文件未找到"})
+	case "response limit reached":
+		c.JSON(http.StatusConflict, gin.H{"code": 4009, "message": "表单已达到最大提交数"})
+	case "quota is full":
+		c.JSON(http.StatusConflict, gin.H{"code": 4009, "message": "该提交所属的配额已满"})
 	default:
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			c.JSON(http.StatusNotFound, gin.H{"code": 4004, "message": "记录未找到"})
@@ -0,0 +1,86 @@
+// Package handler 存放 HTTP 请求的处理器函数
+package handler
+
+import (
+	"net/http"
+	"questflow/internal/service"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// FormVersionHandler 封装了表单版本历史相关的 HTTP 处理器
+type FormVersionHandler struct {
+	versionService service.FormVersionService
+}
+
+// NewFormVersionHandler 创建一个新的 FormVersionHandler
+func NewFormVersionHandler(versionService service.FormVersionService) *FormVersionHandler {
+	return &FormVersionHandler{versionService: versionService}
+}
+
+// ListVersions 处理获取表单版本历史的请求
+func (h *FormVersionHandler) ListVersions(c *gin.Context) {
+	formID, err := getFormIDFromParam(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 4000, "message": "无效的 form_id"})
+		return
+	}
+	claims, _ := c.Get("user_claims")
+	userClaims := claims.(*service.CustomClaims)
+	versions, err := h.versionService.ListVersions(formID, userClaims.UserID)
+	if err != nil {
+		handleServiceError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 0, "message": "success", "data": versions})
+}
+
+// GetVersion 处理获取单个版本快照的请求
+func (h *FormVersionHandler) GetVersion(c *gin.Context) {
+	formID, err := getFormIDFromParam(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 4000, "message": "无效的 form_id"})
+		return
+	}
+	versionID, err := strconv.ParseUint(c.Param("version_id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 4000, "message": "无效的 version_id"})
+		return
+	}
+	claims, _ := c.Get("user_claims")
+	userClaims := claims.(*service.CustomClaims)
+	version, err := h.versionService.GetVersion(formID, userClaims.UserID, uint(versionID))
+	if err != nil {
+		handleServiceError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 0, "message": "success", "data": version})
+}
+
+// DiffVersions 处理比较两个版本的请求，版本通过 from 和 to 查询参数指定
+func (h *FormVersionHandler) DiffVersions(c *gin.Context) {
+	formID, err := getFormIDFromParam(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 4000, "message": "无效的 form_id"})
+		return
+	}
+	fromID, err := strconv.ParseUint(c.Query("from"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 4000, "message": "无效的 from"})
+		return
+	}
+	toID, err := strconv.ParseUint(c.Query("to"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 4000, "message": "无效的 to"})
+		return
+	}
+	claims, _ := c.Get("user_claims")
+	userClaims := claims.(*service.CustomClaims)
+	diff, err := h.versionService.DiffVersions(formID, userClaims.UserID, uint(fromID), uint(toID))
+	if err != nil {
+		handleServiceError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 0, "message": "success", "data": diff})
+}
@@ -0,0 +1,105 @@
+// Package handler 存放 HTTP 请求的处理器函数
+package handler
+
+import (
+	"net/http"
+	"questflow/internal/service"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 待评分答案列表的分页参数
+const (
+	defaultGradingPageSize = 20
+	maxGradingPageSize     = 100
+)
+
+// GradingHandler 封装了人工阅卷相关的 HTTP 处理器
+type GradingHandler struct {
+	gradingService service.GradingService
+}
+
+// NewGradingHandler 创建一个新的 GradingHandler
+func NewGradingHandler(gradingService service.GradingService) *GradingHandler {
+	return &GradingHandler{gradingService: gradingService}
+}
+
+// GradeAnswerRequest 是为单个答案评分的请求体
+type GradeAnswerRequest struct {
+	Points   *int   `json:"points" binding:"required"`
+	Feedback string `json:"feedback" binding:"max=2000"`
+}
+
+// GetGradingProgress 处理获取表单阅卷进度的请求
+func (h *GradingHandler) GetGradingProgress(c *gin.Context) {
+	formID, err := getFormIDFromParam(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 4000, "message": "无效的 form_id"})
+		return
+	}
+	claims, _ := c.Get("user_claims")
+	userClaims := claims.(*service.CustomClaims)
+	progress, err := h.gradingService.GetGradingProgress(formID, userClaims.UserID)
+	if err != nil {
+		handleServiceError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 0, "message": "success", "data": progress})
+}
+
+// ListUngradedAnswers 处理分页获取某个问题待评分答案的请求
+func (h *GradingHandler) ListUngradedAnswers(c *gin.Context) {
+	formID, err := getFormIDFromParam(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 4000, "message": "无效的 form_id"})
+		return
+	}
+	page, err := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if err != nil || page < 1 {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 4000, "message": "无效的 page"})
+		return
+	}
+	pageSize, err := strconv.Atoi(c.DefaultQuery("page_size", strconv.Itoa(defaultGradingPageSize)))
+	if err != nil || pageSize < 1 || pageSize > maxGradingPageSize {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 4000, "message": "无效的 page_size"})
+		return
+	}
+
+	claims, _ := c.Get("user_claims")
+	userClaims := claims.(*service.CustomClaims)
+	answers, err := h.gradingService.ListUngradedAnswers(formID, userClaims.UserID, c.Param("question_id"), page, pageSize)
+	if err != nil {
+		handleServiceError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 0, "message": "success", "data": answers})
+}
+
+// GradeAnswer 处理为单个答案评分的请求，返回提交的最新得分
+func (h *GradingHandler) GradeAnswer(c *gin.Context) {
+	formID, err := getFormIDFromParam(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 4000, "message": "无效的 form_id"})
+		return
+	}
+	submissionID, err := strconv.ParseUint(c.Param("submission_id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 4000, "message": "无效的 submission_id"})
+		return
+	}
+	var req GradeAnswerRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 4000, "message": err.Error()})
+		return
+	}
+
+	claims, _ := c.Get("user_claims")
+	userClaims := claims.(*service.CustomClaims)
+	result, err := h.gradingService.GradeAnswer(formID, userClaims.UserID, uint(submissionID), c.Param("question_id"), *req.Points, req.Feedback)
+	if err != nil {
+		handleServiceError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 0, "message": "评分成功", "data": result})
+}
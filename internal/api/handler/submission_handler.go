@@ -3,8 +3,10 @@ package handler
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
 	"questflow/internal/service"
+	"questflow/pkg/redis"
 
 	"github.com/gin-gonic/gin"
 	"gorm.io/datatypes"
@@ -24,6 +26,39 @@ func NewSubmissionHandler(subService service.SubmissionService, formService serv
 
 type CreateSubmissionRequest struct {
 	Data json.RawMessage `json:"data" binding:"required"`
+	// IdempotencyKey 也可以通过 Idempotency-Key 请求头传递，两者同时存在时以请求头为准
+	IdempotencyKey string `json:"idempotency_key" binding:"max=64"`
+	// AttemptToken 是开始答题时签发的令牌，限时表单必须提供
+	AttemptToken string `json:"attempt_token"`
+	// HiddenFields 是获取表单时返回的 prefill.hidden_fields，原样带回
+	HiddenFields map[string]string `json:"hidden_fields"`
+}
+
+// StartAttempt 处理开始答题的请求，返回记录了服务器开始时间的答题令牌
+func (h *SubmissionHandler) StartAttempt(c *gin.Context) {
+	formKey := c.Param("form_key")
+	if formKey == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 4000, "message": "缺少 form_key"})
+		return
+	}
+
+	form, err := h.formService.GetPublicFormByKey(formKey)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"code": 4004, "message": "表单不存在"})
+		return
+	}
+
+	attempt, err := h.submissionService.StartAttempt(form, c.ClientIP())
+	if err != nil {
+		if err.Error() == "too many attempts" {
+			c.JSON(http.StatusTooManyRequests, gin.H{"code": 4029, "message": "开始答题过于频繁，请稍后再试"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 5000, "message": "开始答题失败", "error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"code": 0, "message": "success", "data": attempt})
 }
 
 // CreateSubmission 处理提交表单数据的请求
@@ -46,13 +81,59 @@ func (h *SubmissionHandler) CreateSubmission(c *gin.Context) {
 		return
 	}
 
-	clientIP := c.ClientIP()
-	userAgent := c.Request.UserAgent()
-	var submitterID *uint
+	idempotencyKey := req.IdempotencyKey
+	if header := c.GetHeader("Idempotency-Key"); header != "" {
+		idempotencyKey = header
+	}
+	if len(idempotencyKey) > 64 {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 4000, "message": "Idempotency-Key 长度不能超过 64"})
+		return
+	}
 
 	// 调用改造后的 service 方法
-	messageID, err := h.submissionService.CreateSubmission(form, datatypes.JSON(req.Data), clientIP, userAgent, submitterID)
+	messageID, err := h.submissionService.CreateSubmission(form, service.SubmissionInput{
+		Data:           datatypes.JSON(req.Data),
+		ClientIP:       c.ClientIP(),
+		UserAgent:      c.Request.UserAgent(),
+		IdempotencyKey: idempotencyKey,
+		AttemptToken:   req.AttemptToken,
+		HiddenFields:   req.HiddenFields,
+	})
 	if err != nil {
+		var validationErr *service.ValidationError
+		if errors.As(err, &validationErr) {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"code":    4000,
+				"message": "提交数据校验失败",
+				"data": gin.H{
+					"errors": validationErr.Errors,
+				},
+			})
+			return
+		}
+		switch err.Error() {
+		case "attempt token is required":
+			c.JSON(http.StatusBadRequest, gin.H{"code": 4000, "message": "该表单需要先开始答题"})
+			return
+		case "invalid attempt token":
+			c.JSON(http.StatusBadRequest, gin.H{"code": 4000, "message": "答题令牌无效"})
+			return
+		case "time limit exceeded":
+			c.JSON(http.StatusForbidden, gin.H{"code": 4003, "message": "已超过答题时间限制"})
+			return
+		case "submission is already in progress":
+			c.JSON(http.StatusConflict, gin.H{"code": 4009, "message": "相同的提交正在处理中，请稍后重试"})
+			return
+		case "idempotency keys require redis":
+			c.JSON(http.StatusServiceUnavailable, gin.H{"code": 5000, "message": "当前部署未启用 Redis，无法保证 Idempotency-Key 的幂等性"})
+			return
+		case "response limit reached":
+			c.JSON(http.StatusForbidden, gin.H{"code": 4003, "message": "该表单已达到最大提交数"})
+			return
+		case "quota is full":
+			c.JSON(http.StatusForbidden, gin.H{"code": 4003, "message": "所选选项的名额已满"})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"code": 5000, "message": "提交失败", "error": err.Error()})
 		return
 	}
@@ -66,3 +147,24 @@ func (h *SubmissionHandler) CreateSubmission(c *gin.Context) {
 		},
 	})
 }
+
+// GetSubmissionStatus 处理查询提交处理状态的请求
+func (h *SubmissionHandler) GetSubmissionStatus(c *gin.Context) {
+	messageID := c.Param("message_id")
+	if messageID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 4000, "message": "缺少 message_id"})
+		return
+	}
+
+	status, err := h.submissionService.GetSubmissionStatus(messageID)
+	if err != nil {
+		if errors.Is(err, redis.ErrSubmissionStatusNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"code": 4004, "message": "提交记录不存在或已过期"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 5000, "message": "查询失败", "error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"code": 0, "message": "success", "data": status})
+}
@@ -51,7 +51,7 @@ func (h *UserHandler) Register(c *gin.Context) {
 // LoginRequest 定义了登录请求的 JSON 结构体
 type LoginRequest struct {
 	Username string `json:"username" binding:"required"`
-	Password string `json:"password"binding:"required"`
+	Password string `json:"password" binding:"required"`
 }
 
 // Login 处理用户登录请求
@@ -4,25 +4,37 @@ package api
 import (
 	"questflow/internal/api/handler"
 	"questflow/internal/api/middleware"
+	"questflow/internal/queue"
 	"questflow/internal/repository"
 	"questflow/internal/service"
+	"questflow/internal/storage"
 
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
 )
 
 // SetupRouter 初始化 Gin 引擎并设置所有路由
-func SetupRouter(db *gorm.DB) *gin.Engine {
+func SetupRouter(db *gorm.DB, q queue.SubmissionQueue, store storage.Storage) *gin.Engine {
 	// 初始化各模块的依赖 (Repository -> Service -> Handler)
 	userRepo := repository.NewUserRepository(db)
 	userService := service.NewUserService(userRepo)
 	userHandler := handler.NewUserHandler(userService)
 	submissionRepo := repository.NewSubmissionRepository(db)
-	submissionService := service.NewSubmissionService(submissionRepo)
 	formRepo := repository.NewFormRepository(db)
-	formService := service.NewFormService(formRepo, submissionRepo)
+	versionRepo := repository.NewFormVersionRepository(db)
+	fileRepo := repository.NewUploadedFileRepository(db)
+	submissionService := service.NewSubmissionService(submissionRepo, formRepo, versionRepo, fileRepo, q)
+	formService := service.NewFormService(formRepo, versionRepo, submissionRepo, fileRepo)
 	formHandler := handler.NewFormHandler(formService)
 	submissionHandler := handler.NewSubmissionHandler(submissionService, formService)
+	deadLetterService := service.NewDeadLetterService(formRepo, submissionRepo, q)
+	deadLetterHandler := handler.NewDeadLetterHandler(deadLetterService)
+	gradingService := service.NewGradingService(formRepo, versionRepo, submissionRepo)
+	gradingHandler := handler.NewGradingHandler(gradingService)
+	versionService := service.NewFormVersionService(formRepo, versionRepo, submissionRepo)
+	versionHandler := handler.NewFormVersionHandler(versionService)
+	fileService := service.NewFileService(formRepo, fileRepo, store)
+	fileHandler := handler.NewFileHandler(fileService, formService)
 
 	r := gin.Default()
 	apiV1 := r.Group("/api/v1")
@@ -32,7 +44,10 @@ func SetupRouter(db *gorm.DB) *gin.Engine {
 		publicRoutes := apiV1.Group("/public")
 		{
 			publicRoutes.GET("/forms/:form_key", formHandler.GetPublicForm)
+			publicRoutes.POST("/forms/:form_key/attempts", submissionHandler.StartAttempt)
 			publicRoutes.POST("/forms/:form_key/submissions", submissionHandler.CreateSubmission)
+			publicRoutes.POST("/forms/:form_key/questions/:question_id/files", fileHandler.UploadFiles)
+			publicRoutes.GET("/submissions/:message_id/status", submissionHandler.GetSubmissionStatus)
 		}
 		userPublicRoutes := apiV1.Group("/users")
 		{
@@ -57,13 +72,33 @@ func SetupRouter(db *gorm.DB) *gin.Engine {
 
 				// 针对特定 form_id 的操作
 				formAuthRoutes.GET("/:form_id/stats", formHandler.GetStatistics)
+				formAuthRoutes.GET("/:form_id/stats/items", formHandler.GetItemAnalysis)
 				formAuthRoutes.DELETE("/:form_id", formHandler.DeleteForm)
 				formAuthRoutes.GET("/:form_id/details", formHandler.GetFormDetails)
 				formAuthRoutes.PUT("/:form_id", formHandler.UpdateForm)
 				formAuthRoutes.PUT("/:form_id/status", formHandler.UpdateFormStatus)
 
+				// 版本历史
+				formAuthRoutes.GET("/:form_id/versions", versionHandler.ListVersions)
+				formAuthRoutes.GET("/:form_id/versions/diff", versionHandler.DiffVersions)
+				formAuthRoutes.GET("/:form_id/versions/:version_id", versionHandler.GetVersion)
+
 				// 【核心改动】将导出路由从 GET 修改为 POST
 				formAuthRoutes.POST("/:form_id/export", formHandler.ExportSubmissions)
+
+				// 下载文件上传题的文件
+				formAuthRoutes.GET("/:form_id/files/:token", fileHandler.DownloadFile)
+
+				// 处理失败的提交（死信）管理
+				formAuthRoutes.GET("/:form_id/dead-letters", deadLetterHandler.ListDeadLetters)
+				formAuthRoutes.GET("/:form_id/dead-letters/:message_id", deadLetterHandler.GetDeadLetter)
+				formAuthRoutes.POST("/:form_id/dead-letters/:message_id/redrive", deadLetterHandler.RedriveDeadLetter)
+				formAuthRoutes.DELETE("/:form_id/dead-letters/:message_id", deadLetterHandler.DiscardDeadLetter)
+
+				// 人工阅卷
+				formAuthRoutes.GET("/:form_id/grading", gradingHandler.GetGradingProgress)
+				formAuthRoutes.GET("/:form_id/grading/questions/:question_id/ungraded", gradingHandler.ListUngradedAnswers)
+				formAuthRoutes.PUT("/:form_id/submissions/:submission_id/grades/:question_id", gradingHandler.GradeAnswer)
 			}
 		}
 	}
@@ -0,0 +1,134 @@
+// Package app 负责组装依赖，并按角色运行 API 服务和 submission consumer
+package app
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"questflow/internal/api"
+	"questflow/internal/consumer"
+	"questflow/internal/model"
+	"questflow/internal/queue"
+	"questflow/internal/repository"
+	"questflow/internal/service"
+	"questflow/internal/storage"
+	"questflow/pkg/config"
+	"questflow/pkg/db"
+	"questflow/pkg/redis"
+	"syscall"
+	"time"
+)
+
+// 进程角色
+const (
+	RoleAPI      = "api"      // 只运行 HTTP API 服务
+	RoleConsumer = "consumer" // 只运行 submission consumer
+	RoleAll      = "all"      // 在同一进程中同时运行两者
+)
+
+// defaultShutdownTimeout 是未配置 shutdown_timeout_seconds 时的优雅退出等待时间
+const defaultShutdownTimeout = 30 * time.Second
+
+// Run 按指定角色初始化依赖并运行，直到收到 SIGINT / SIGTERM 后优雅退出
+func Run(configPath, role string) {
+	if role != RoleAPI && role != RoleConsumer && role != RoleAll {
+		log.Fatalf("Invalid role %q, must be one of: api, consumer, all", role)
+	}
+
+	// 1. 加载配置
+	config.Init(configPath)
+	// 进程内队列无法在进程之间传递消息，API 和 consumer 必须运行在同一进程中
+	if queue.Driver() == queue.DriverMemory && role != RoleAll {
+		log.Fatalf("Queue driver %q can only be used with role %q", queue.DriverMemory, RoleAll)
+	}
+
+	// 2. 初始化数据库连接
+	db.InitMySQL()
+
+	// 3. 初始化 Redis 连接；不使用 Redis 队列且未配置地址时跳过
+	if queue.Driver() == queue.DriverRedis || config.Cfg.Redis.Addr != "" {
+		redis.InitRedis()
+	}
+
+	// 4. 自动迁移数据库表结构
+	err := db.DB.AutoMigrate(&model.User{}, &model.Form{}, &model.FormVersion{}, &model.Submission{}, &model.AnswerGrade{}, &model.UploadedFile{})
+	if err != nil {
+		log.Fatalf("Failed to auto migrate database: %v", err)
+	}
+
+	// 5. 初始化提交消息队列
+	submissionQueue, err := queue.New(db.DB)
+	if err != nil {
+		log.Fatalf("Failed to create submission queue: %v", err)
+	}
+	if err := submissionQueue.Setup(context.Background()); err != nil {
+		log.Fatalf("Failed to set up submission queue: %v", err)
+	}
+
+	// 收到 SIGINT / SIGTERM 时取消 ctx，触发优雅退出
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// 6. 启动 consumer Goroutine
+	var consumerDone <-chan struct{}
+	if role != RoleAPI {
+		consumerDone = consumer.StartSubmissionConsumer(ctx, submissionQueue)
+	}
+
+	// 7. 初始化上传文件存储并启动未使用文件的清理，设置并启动 Gin API 服务
+	var srv *http.Server
+	if role != RoleConsumer {
+		fileStorage, err := storage.New(ctx)
+		if err != nil {
+			log.Fatalf("Failed to create file storage: %v", err)
+		}
+		service.StartUploadCleanup(ctx, repository.NewUploadedFileRepository(db.DB), fileStorage)
+		srv = &http.Server{
+			Addr:    config.Cfg.App.Port,
+			Handler: api.SetupRouter(db.DB, submissionQueue, fileStorage),
+		}
+		go func() {
+			log.Printf("API Server is running on http://localhost%s", config.Cfg.App.Port)
+			if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				log.Fatalf("Failed to run API server: %v", err)
+			}
+		}()
+	}
+
+	// 8. 等待退出信号，然后依次关闭 API 服务、consumer 和底层连接
+	<-ctx.Done()
+	stop()
+	log.Printf("Shutdown signal received, stopping %s role...", role)
+
+	timeout := defaultShutdownTimeout
+	if config.Cfg.App.ShutdownTimeoutSeconds > 0 {
+		timeout = time.Duration(config.Cfg.App.ShutdownTimeoutSeconds) * time.Second
+	}
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	if srv != nil {
+		if err := srv.Shutdown(shutdownCtx); err != nil {
+			log.Printf("API server forced to shut down: %v", err)
+		}
+	}
+
+	if consumerDone != nil {
+		select {
+		case <-consumerDone:
+		case <-shutdownCtx.Done():
+			log.Println("Timed out waiting for consumer to finish its current batch.")
+		}
+	}
+
+	if err := redis.Close(); err != nil {
+		log.Printf("Failed to close Redis connection: %v", err)
+	}
+	if err := db.Close(); err != nil {
+		log.Printf("Failed to close database connection: %v", err)
+	}
+	log.Println("Process exited.")
+}
@@ -4,78 +4,432 @@ package consumer
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"log"
+	"os"
+	"questflow/internal/queue"
 	"questflow/internal/repository"
 	"questflow/internal/service"
 	"questflow/pkg/config"
 	"questflow/pkg/db"
-	redisPkg "questflow/pkg/redis"
+	"questflow/pkg/redis"
+	"sync"
 	"time"
+)
 
-	"github.com/go-redis/redis/v8"
+const (
+	defaultMaxAttempts   = 5
+	defaultRetryInterval = 30 * time.Second
+	defaultClaimIdle     = 5 * time.Minute
+	defaultClaimInterval = time.Minute
+	defaultConcurrency   = 4
+	defaultBatchSize     = 50
+	defaultBatchWait     = 200 * time.Millisecond
+	pendingBatchSize     = 100
+	// quotaReconcileInterval 是校准配额计数器的间隔；每次校准都要统计表单的提交数，
+	// 因此只定期校准这段时间内有新提交写入的表单，而不是每批提交后都校准
+	quotaReconcileInterval = 10 * time.Second
+	// readBlockTimeout 让读取循环定期醒来，以便检查需要重试的消息
+	readBlockTimeout = 5 * time.Second
 )
 
+// submissionConsumer 持有 consumer 运行所需的依赖和配置
+type submissionConsumer struct {
+	submissionService service.SubmissionService
+	queue             queue.SubmissionQueue
+	consumerName      string
+	maxAttempts       int64
+	retryInterval     time.Duration
+	claimIdle         time.Duration
+	claimInterval     time.Duration
+	concurrency       int
+	batchSize         int
+	batchWait         time.Duration
+	// jobs 由读取循环写入，由 concurrency 个 worker goroutine 并发处理
+	jobs chan queue.Message
+
+	mu sync.Mutex
+	// lastErrors 记录每条待重试消息最近一次的失败原因，写入死信时使用
+	lastErrors map[string]string
+	// dirtyForms 记录上次校准配额后有新提交写入的表单
+	dirtyForms map[uint]bool
+}
+
 // StartSubmissionConsumer 启动 submission consumer
-func StartSubmissionConsumer() {
+// ctx 被取消后 consumer 不再读取新消息，处理并 ACK 完当前批次后关闭返回的 channel
+// q 必须已经完成 Setup
+func StartSubmissionConsumer(ctx context.Context, q queue.SubmissionQueue) <-chan struct{} {
 	log.Println("Starting submission consumer goroutine...")
 
 	// 依赖注入
 	submissionRepo := repository.NewSubmissionRepository(db.DB)
-	submissionService := service.NewSubmissionService(submissionRepo)
+	formRepo := repository.NewFormRepository(db.DB)
+	versionRepo := repository.NewFormVersionRepository(db.DB)
+	submissionService := service.NewSubmissionService(submissionRepo, formRepo, versionRepo, nil, nil)
 
-	streamKey := config.Cfg.Redis.SubmissionStreamKey
-	groupName := config.Cfg.Redis.SubmissionGroupName
-	consumerName := "consumer-" + time.Now().Format("20060102150405")
-
-	err := redisPkg.RDB.XGroupCreateMkStream(context.Background(), streamKey, groupName, "0").Err()
-	if err != nil && err.Error() != "BUSYGROUP Consumer Group name already exists" {
-		log.Fatalf("Failed to create consumer group: %v", err)
+	c := &submissionConsumer{
+		submissionService: submissionService,
+		queue:             q,
+		consumerName:      resolveConsumerName(),
+		maxAttempts:       defaultMaxAttempts,
+		retryInterval:     defaultRetryInterval,
+		claimIdle:         defaultClaimIdle,
+		claimInterval:     defaultClaimInterval,
+		concurrency:       defaultConcurrency,
+		batchSize:         defaultBatchSize,
+		batchWait:         defaultBatchWait,
+		lastErrors:        make(map[string]string),
+		dirtyForms:        make(map[uint]bool),
+	}
+	if config.Cfg.Consumer.MaxAttempts > 0 {
+		c.maxAttempts = int64(config.Cfg.Consumer.MaxAttempts)
+	}
+	if config.Cfg.Consumer.RetryIntervalSeconds > 0 {
+		c.retryInterval = time.Duration(config.Cfg.Consumer.RetryIntervalSeconds) * time.Second
 	}
-	log.Printf("Consumer group '%s' is ready.", groupName)
+	if config.Cfg.Consumer.ClaimIdleSeconds > 0 {
+		c.claimIdle = time.Duration(config.Cfg.Consumer.ClaimIdleSeconds) * time.Second
+	}
+	if config.Cfg.Consumer.ClaimIntervalSeconds > 0 {
+		c.claimInterval = time.Duration(config.Cfg.Consumer.ClaimIntervalSeconds) * time.Second
+	}
+	if config.Cfg.Consumer.Concurrency > 0 {
+		c.concurrency = config.Cfg.Consumer.Concurrency
+	}
+	if config.Cfg.Consumer.BatchSize > 0 {
+		c.batchSize = config.Cfg.Consumer.BatchSize
+	}
+	if config.Cfg.Consumer.BatchWaitMs > 0 {
+		c.batchWait = time.Duration(config.Cfg.Consumer.BatchWaitMs) * time.Millisecond
+	}
+	c.jobs = make(chan queue.Message)
+	log.Printf("Consumer is ready, queue driver: %s, consumer name: %s, workers: %d", queue.Driver(), c.consumerName, c.concurrency)
 
+	done := make(chan struct{})
 	go func() {
-		for {
-			streams, err := redisPkg.RDB.XReadGroup(context.Background(), &redis.XReadGroupArgs{
-				Group:    groupName,
-				Consumer: consumerName,
-				Streams:  []string{streamKey, ">"},
-				Count:    10,
-				Block:    0,
-			}).Result()
-
-			if err != nil {
-				log.Printf("Error reading from stream: %v", err)
-				time.Sleep(2 * time.Second)
-				continue
+		defer close(done)
+		c.run(ctx)
+		log.Println("Consumer stopped.")
+	}()
+
+	log.Println("Consumer is now listening for messages in the background.")
+	return done
+}
+
+// run 是 consumer 的主循环：读取新消息，定期重试处理失败的消息，并接管其他 consumer 遗留的消息
+// 读取到的消息通过 jobs 分发给 worker 并发处理。
+// ctx 只用于控制读取新消息；处理和 ACK 使用独立的 context，保证退出时已读取的批次能够完整处理
+func (c *submissionConsumer) run(ctx context.Context) {
+	processCtx := context.Background()
+
+	var wg sync.WaitGroup
+	for i := 0; i < c.concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			c.worker(processCtx)
+		}()
+	}
+	// 读取循环退出后关闭 jobs，等待 worker 处理完已分发的消息，再校准最后写入的表单
+	defer func() {
+		close(c.jobs)
+		wg.Wait()
+		c.reconcileQuotas()
+	}()
+
+	// 启动时立即接管一次，尽快处理上次崩溃或重启前遗留的消息
+	c.processPending(processCtx, "", c.claimIdle)
+	lastRetry, lastClaim, lastReconcile := time.Now(), time.Now(), time.Now()
+	for ctx.Err() == nil {
+		if time.Since(lastReconcile) >= quotaReconcileInterval {
+			c.reconcileQuotas()
+			lastReconcile = time.Now()
+		}
+		if time.Since(lastRetry) >= c.retryInterval {
+			c.processPending(processCtx, c.consumerName, c.retryInterval)
+			lastRetry = time.Now()
+		}
+		if time.Since(lastClaim) >= c.claimInterval {
+			c.processPending(processCtx, "", c.claimIdle)
+			lastClaim = time.Now()
+		}
+
+		messages, err := c.queue.Read(ctx, c.consumerName, c.batchSize, readBlockTimeout)
+		if ctx.Err() != nil {
+			continue // 正在退出
+		}
+		if err != nil {
+			log.Printf("Error reading from queue: %v", err)
+			time.Sleep(2 * time.Second)
+			continue
+		}
+
+		for _, message := range messages {
+			c.jobs <- message
+		}
+	}
+}
+
+// worker 从 jobs 中累积消息，凑满 batchSize 条或自第一条起等待 batchWait 后批量处理
+// jobs 被关闭时处理完手上剩余的消息后返回
+func (c *submissionConsumer) worker(ctx context.Context) {
+	batch := make([]queue.Message, 0, c.batchSize)
+	timer := time.NewTimer(c.batchWait)
+	timer.Stop()
+
+	flush := func() {
+		timer.Stop()
+		if len(batch) > 0 {
+			c.handleBatch(ctx, batch)
+			batch = make([]queue.Message, 0, c.batchSize)
+		}
+	}
+
+	for {
+		select {
+		case m, ok := <-c.jobs:
+			if !ok {
+				flush()
+				return
+			}
+			batch = append(batch, m)
+			if len(batch) == 1 {
+				timer.Reset(c.batchWait)
 			}
+			if len(batch) >= c.batchSize {
+				flush()
+			}
+		case <-timer.C:
+			flush()
+		}
+	}
+}
+
+// handleBatch 在一个事务中批量写入一批消息，成功后一次性 ACK 全部消息ID
+// 批量写入失败时回退为逐条处理，避免一条坏数据拖累同批的其他提交
+func (c *submissionConsumer) handleBatch(ctx context.Context, batch []queue.Message) {
+	valid := make([]queue.Message, 0, len(batch))
+	msgs := make([]service.SubmissionMessage, 0, len(batch))
+	for _, m := range batch {
+		subMsg, err := decodeMessage(m)
+		if err != nil {
+			// 无法解析的消息重试也不会成功，直接转入死信队列
+			log.Printf("[Consumer] Poison message %s: %v", m.ID, err)
+			c.deadLetter(ctx, m, err.Error())
+			continue
+		}
+		valid = append(valid, m)
+		msgs = append(msgs, *subMsg)
+	}
+	if len(valid) == 0 {
+		return
+	}
+
+	submissionIDs, err := c.submissionService.ProcessSubmissionBatch(msgs)
+	if err != nil {
+		log.Printf("[Consumer] Failed to process batch of %d messages, falling back to one by one: %v", len(valid), err)
+		for i, m := range valid {
+			c.handleMessage(ctx, m, msgs[i])
+		}
+		return
+	}
+
+	ids := make([]string, 0, len(valid))
+	statuses := make([]redis.SubmissionStatus, 0, len(valid))
+	for i, m := range valid {
+		ids = append(ids, m.ID)
+		statuses = append(statuses, redis.SubmissionStatus{
+			MessageID:    m.ID,
+			Status:       redis.SubmissionStatusPersisted,
+			SubmissionID: submissionIDs[i],
+			Attempts:     m.Deliveries,
+		})
+	}
+	c.ack(ctx, ids...)
+	c.recordStatus(ctx, statuses...)
+	log.Printf("[Consumer] Successfully processed and ACKed %d messages", len(ids))
+
+	formIDs := make([]uint, 0, len(msgs))
+	for _, msg := range msgs {
+		formIDs = append(formIDs, msg.FormID)
+	}
+	c.markDirty(formIDs...)
+}
 
-			for _, stream := range streams {
-				for _, message := range stream.Messages {
-					log.Printf("[Consumer] Processing message ID: %s", message.ID)
-
-					payload, ok := message.Values["payload"].(string)
-					if !ok {
-						log.Printf("[Consumer] Invalid payload for message %s. Skipping.", message.ID)
-						continue
-					}
-
-					var subMsg service.SubmissionMessage
-					if err := json.Unmarshal([]byte(payload), &subMsg); err != nil {
-						log.Printf("[Consumer] Failed to unmarshal message %s: %v. Skipping.", message.ID, err)
-						continue
-					}
-
-					if err := submissionService.ProcessSubmission(subMsg); err != nil {
-						log.Printf("[Consumer] Failed to process message %s: %v", message.ID, err)
-						continue
-					}
-
-					redisPkg.RDB.XAck(context.Background(), streamKey, groupName, message.ID)
-					log.Printf("[Consumer] Successfully processed and ACKed message ID: %s", message.ID)
-				}
+// handleMessage 单独处理一条已解析的消息
+// 成功或转入死信后 ACK；普通失败则保持未确认状态，等待 processPending 重试
+func (c *submissionConsumer) handleMessage(ctx context.Context, m queue.Message, subMsg service.SubmissionMessage) {
+	log.Printf("[Consumer] Processing message ID: %s (attempt %d/%d)", m.ID, m.Deliveries, c.maxAttempts)
+
+	submissionID, err := c.submissionService.ProcessSubmission(subMsg)
+	if err != nil {
+		log.Printf("[Consumer] Failed to process message %s: %v", m.ID, err)
+		// 本次是第 Deliveries 次尝试
+		if c.exhausted(m.Deliveries) {
+			c.deadLetter(ctx, m, err.Error())
+			return
+		}
+		c.mu.Lock()
+		c.lastErrors[m.ID] = err.Error()
+		c.mu.Unlock()
+		c.recordStatus(ctx, redis.SubmissionStatus{
+			MessageID: m.ID,
+			Status:    redis.SubmissionStatusFailed,
+			Attempts:  m.Deliveries,
+		})
+		return
+	}
+
+	c.ack(ctx, m.ID)
+	c.recordStatus(ctx, redis.SubmissionStatus{
+		MessageID:    m.ID,
+		Status:       redis.SubmissionStatusPersisted,
+		SubmissionID: submissionID,
+		Attempts:     m.Deliveries,
+	})
+	log.Printf("[Consumer] Successfully processed and ACKed message ID: %s", m.ID)
+	c.markDirty(subMsg.FormID)
+}
+
+// processPending 认领空闲时间超过 minIdle 的已投递未确认消息并重新处理
+// owner 为本 consumer 名称时用于重试自己失败的消息；为空时扫描所有 consumer，
+// 用于接管已崩溃或已下线的 consumer 遗留的消息，保证已投递但未 ACK 的提交不会丢失。
+// 认领后已经尝试过 maxAttempts 次的消息转入死信队列，其余的交给 worker 重新处理。
+func (c *submissionConsumer) processPending(ctx context.Context, owner string, minIdle time.Duration) {
+	claimed, err := c.queue.Claim(ctx, c.consumerName, owner, minIdle, pendingBatchSize)
+	if err != nil {
+		log.Printf("[Consumer] Failed to claim pending messages: %v", err)
+		// 出错前已认领的消息仍然需要处理
+	}
+
+	for _, m := range claimed {
+		// 认领使投递次数加一，之前已经尝试过 Deliveries-1 次
+		if c.exhausted(m.Deliveries - 1) {
+			c.mu.Lock()
+			reason, ok := c.lastErrors[m.ID]
+			c.mu.Unlock()
+			if !ok {
+				reason = "exceeded max delivery attempts"
 			}
+			c.deadLetter(ctx, m, reason)
+			continue
 		}
-	}()
+		if owner == "" {
+			log.Printf("[Consumer] Claimed idle message %s (attempt %d)", m.ID, m.Deliveries)
+		}
+		c.jobs <- m
+	}
+}
 
-	log.Println("Consumer is now listening for messages in the background.")
+// exhausted 报告尝试过 attempts 次的消息是否已用完重试次数，应转入死信队列。
+// handleMessage 和 processPending 使用同一规则，每条消息最多处理 maxAttempts 次
+func (c *submissionConsumer) exhausted(attempts int64) bool {
+	return attempts >= c.maxAttempts
+}
+
+// resolveConsumerName 生成稳定的 consumer 名称
+// 优先使用配置中的名称，否则使用 "主机名-实例ID"，保证进程重启后仍能认领自己名下的消息
+func resolveConsumerName() string {
+	if config.Cfg.Consumer.Name != "" {
+		return config.Cfg.Consumer.Name
+	}
+	hostname, err := os.Hostname()
+	if err != nil || hostname == "" {
+		hostname = "consumer"
+	}
+	if config.Cfg.Consumer.InstanceID == "" {
+		return hostname
+	}
+	return hostname + "-" + config.Cfg.Consumer.InstanceID
+}
+
+// deadLetter 将消息连同失败原因写入死信队列，并确认原消息，同时归还消息占用的配额
+// 写入死信失败时不会确认，消息会继续保持未确认状态，保证不丢数据
+func (c *submissionConsumer) deadLetter(ctx context.Context, m queue.Message, reason string) {
+	// 尽量解析出表单ID和占用的配额，方便表单所有者在 API 中查看
+	var meta service.SubmissionMessage
+	_ = json.Unmarshal(m.Payload, &meta)
+
+	dlqID, err := c.queue.DeadLetter(ctx, &queue.DeadLetter{
+		OriginalID: m.ID,
+		FormID:     meta.FormID,
+		Payload:    string(m.Payload),
+		Reason:     reason,
+		Attempts:   m.Deliveries,
+		FailedAt:   time.Now(),
+	})
+	if err != nil {
+		log.Printf("[Consumer] Failed to dead-letter message %s: %v", m.ID, err)
+		return
+	}
+
+	c.forget(m.ID)
+	c.submissionService.ReleaseQuotas(meta)
+	c.recordStatus(ctx, redis.SubmissionStatus{
+		MessageID: m.ID,
+		Status:    redis.SubmissionStatusDeadLettered,
+		Attempts:  m.Deliveries,
+	})
+	log.Printf("[Consumer] Message %s moved to dead-letter queue as %s: %s", m.ID, dlqID, reason)
+}
+
+// markDirty 记录有新提交写入的表单，由 reconcileQuotas 定期校准
+func (c *submissionConsumer) markDirty(formIDs ...uint) {
+	c.mu.Lock()
+	for _, formID := range formIDs {
+		c.dirtyForms[formID] = true
+	}
+	c.mu.Unlock()
+}
+
+// reconcileQuotas 校准上次校准后有新提交写入的表单的配额计数器，并关闭达到最大提交数的表单；
+// 失败只记录日志，表单会在下次有提交写入后再次校准
+func (c *submissionConsumer) reconcileQuotas() {
+	c.mu.Lock()
+	dirty := c.dirtyForms
+	c.dirtyForms = make(map[uint]bool)
+	c.mu.Unlock()
+
+	for formID := range dirty {
+		if err := c.submissionService.ReconcileQuotas(formID); err != nil {
+			log.Printf("[Consumer] Failed to reconcile quotas for form %d: %v", formID, err)
+		}
+	}
+}
+
+// recordStatus 记录消息的处理结果，供公开的状态查询接口使用；写入失败只记录日志
+func (c *submissionConsumer) recordStatus(ctx context.Context, statuses ...redis.SubmissionStatus) {
+	if err := redis.SetSubmissionStatuses(ctx, statuses...); err != nil {
+		log.Printf("[Consumer] Failed to record submission status: %v", err)
+	}
+}
+
+// ack 确认消息并清理其失败记录
+func (c *submissionConsumer) ack(ctx context.Context, ids ...string) {
+	if err := c.queue.Ack(ctx, ids...); err != nil {
+		log.Printf("[Consumer] Failed to ACK messages %v: %v", ids, err)
+	}
+	c.forget(ids...)
+}
+
+// forget 清理消息的失败记录
+func (c *submissionConsumer) forget(ids ...string) {
+	c.mu.Lock()
+	for _, id := range ids {
+		delete(c.lastErrors, id)
+	}
+	c.mu.Unlock()
+}
+
+// decodeMessage 从队列消息中解析出 SubmissionMessage
+func decodeMessage(m queue.Message) (*service.SubmissionMessage, error) {
+	if len(m.Payload) == 0 {
+		return nil, errors.New("invalid payload")
+	}
+
+	var subMsg service.SubmissionMessage
+	if err := json.Unmarshal(m.Payload, &subMsg); err != nil {
+		return nil, err
+	}
+	return &subMsg, nil
 }
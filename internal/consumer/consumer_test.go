@@ -0,0 +1,128 @@
+package consumer
+
+import (
+	"context"
+	"errors"
+	"questflow/internal/queue"
+	"questflow/internal/service"
+	"testing"
+	"time"
+)
+
+// failingService 是每次写入都失败的 SubmissionService，记录尝试写入的次数
+type failingService struct {
+	service.SubmissionService
+	attempts int
+}
+
+func (s *failingService) ProcessSubmission(msg service.SubmissionMessage) (uint, error) {
+	s.attempts++
+	return 0, errors.New("database unavailable")
+}
+
+func (s *failingService) ReleaseQuotas(msg service.SubmissionMessage) {}
+
+// newTestConsumer 创建使用进程内队列的 consumer，jobs 带缓冲以便在测试中直接取出 processPending 分发的消息
+func newTestConsumer(maxAttempts int64) (*submissionConsumer, *failingService) {
+	svc := &failingService{}
+	return &submissionConsumer{
+		submissionService: svc,
+		queue:             queue.NewMemoryQueue(),
+		consumerName:      "test",
+		maxAttempts:       maxAttempts,
+		jobs:              make(chan queue.Message, pendingBatchSize),
+		lastErrors:        make(map[string]string),
+		dirtyForms:        make(map[uint]bool),
+	}, svc
+}
+
+// retryPending 模拟重试循环：认领自己名下的消息，处理 processPending 分发出来的消息
+func retryPending(ctx context.Context, c *submissionConsumer) {
+	c.processPending(ctx, c.consumerName, 0)
+	for {
+		select {
+		case m := <-c.jobs:
+			c.handleMessage(ctx, m, service.SubmissionMessage{FormID: 1})
+		default:
+			return
+		}
+	}
+}
+
+// 一直失败的消息最多处理 maxAttempts 次，最后一次失败后转入死信队列
+func TestFailedMessageIsDeadLetteredAfterMaxAttempts(t *testing.T) {
+	for _, maxAttempts := range []int64{1, 2, 3, 5} {
+		ctx := context.Background()
+		c, svc := newTestConsumer(maxAttempts)
+		if _, err := c.queue.Publish(ctx, []byte(`{"form_id": 1}`)); err != nil {
+			t.Fatal(err)
+		}
+		messages, err := c.queue.Read(ctx, c.consumerName, 1, time.Millisecond)
+		if err != nil || len(messages) != 1 {
+			t.Fatalf("Read = %v, %v", messages, err)
+		}
+		c.handleMessage(ctx, messages[0], service.SubmissionMessage{FormID: 1})
+		for i := 0; i < int(maxAttempts)+2; i++ {
+			retryPending(ctx, c)
+		}
+
+		if svc.attempts != int(maxAttempts) {
+			t.Errorf("maxAttempts %d: processed %d times", maxAttempts, svc.attempts)
+		}
+		dead, err := c.queue.ListDeadLetters(ctx, 1, "", 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(dead.Items) != 1 || dead.Items[0].Attempts != maxAttempts || dead.Items[0].Reason != "database unavailable" {
+			t.Errorf("maxAttempts %d: dead letters = %+v", maxAttempts, dead.Items)
+		}
+	}
+}
+
+// 处理过程中崩溃（没有记录结果）的消息同样在尝试 maxAttempts 次后转入死信队列
+func TestClaimedMessageIsDeadLetteredAfterMaxAttempts(t *testing.T) {
+	tests := []struct {
+		maxAttempts   int64
+		crashes       int // 投递后没有处理就被重新认领的次数
+		wantProcessed int
+		wantDead      bool
+	}{
+		{maxAttempts: 3, crashes: 0, wantProcessed: 3, wantDead: true},
+		{maxAttempts: 3, crashes: 1, wantProcessed: 2, wantDead: true},
+		{maxAttempts: 3, crashes: 2, wantProcessed: 1, wantDead: true},
+		{maxAttempts: 3, crashes: 3, wantProcessed: 0, wantDead: true},
+	}
+	for _, tt := range tests {
+		ctx := context.Background()
+		c, svc := newTestConsumer(tt.maxAttempts)
+		if _, err := c.queue.Publish(ctx, []byte(`{"form_id": 1}`)); err != nil {
+			t.Fatal(err)
+		}
+		messages, err := c.queue.Read(ctx, c.consumerName, 1, time.Millisecond)
+		if err != nil || len(messages) != 1 {
+			t.Fatalf("Read = %v, %v", messages, err)
+		}
+		// 前 crashes 次投递没有处理完：第一次是 Read，之后每次重新认领后丢弃分发出来的消息
+		for i := 1; i < tt.crashes; i++ {
+			c.processPending(ctx, c.consumerName, 0)
+			for len(c.jobs) > 0 {
+				<-c.jobs
+			}
+		}
+		if tt.crashes == 0 {
+			c.handleMessage(ctx, messages[0], service.SubmissionMessage{FormID: 1})
+		}
+		for i := 0; i < int(tt.maxAttempts)+2; i++ {
+			retryPending(ctx, c)
+		}
+
+		dead, err := c.queue.ListDeadLetters(ctx, 1, "", 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if svc.attempts != tt.wantProcessed || (len(dead.Items) == 1) != tt.wantDead {
+			t.Errorf("crashes %d: processed %d times, %d dead letters; want %d, dead %v",
+				tt.crashes, svc.attempts, len(dead.Items), tt.wantProcessed, tt.wantDead)
+		}
+	}
+}
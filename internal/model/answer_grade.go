@@ -0,0 +1,22 @@
+// Package model 定义了与数据库表对应的 GORM 模型
+package model
+
+import "time"
+
+// AnswerGrade 对应于数据库中的 `answer_grades` 表，记录阅卷人对单个答案的人工评分
+type AnswerGrade struct {
+	ID           uint   `gorm:"primarykey"`
+	SubmissionID uint   `gorm:"not null;uniqueIndex:idx_submission_question"`
+	QuestionID   string `gorm:"type:varchar(64);not null;uniqueIndex:idx_submission_question"`
+	FormID       uint   `gorm:"not null;index"` // 冗余存储，便于按表单统计阅卷进度
+	Points       int    `gorm:"not null"`
+	Feedback     string `gorm:"type:text"`
+	GraderID     uint   `gorm:"not null"` // 阅卷人的用户ID
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+}
+
+// TableName 指定 AnswerGrade 模型对应的数据库表名
+func (AnswerGrade) TableName() string {
+	return "answer_grades"
+}
@@ -22,6 +22,9 @@ type Form struct {
 	UpdatedAt   time.Time
 	DeletedAt   gorm.DeletedAt `gorm:"index"`
 
+	// PublishedVersionID 指向最近一次发布时保存的版本快照，从未发布过时为空
+	PublishedVersionID *uint `gorm:"null"`
+
 	// 定义关联关系
 	Creator User `gorm:"foreignKey:CreatorID"`
 }
@@ -0,0 +1,25 @@
+// Package model 定义了与数据库表对应的 GORM 模型
+package model
+
+import (
+	"time"
+
+	"gorm.io/datatypes"
+)
+
+// FormVersion 对应于数据库中的 `form_versions` 表
+// 表单每次发布时保存一份不可变的快照，提交记录通过 FormVersionID 固定到作答时的版本
+type FormVersion struct {
+	ID          uint           `gorm:"primarykey"`
+	FormID      uint           `gorm:"not null;uniqueIndex:idx_form_version"`
+	Version     int            `gorm:"not null;uniqueIndex:idx_form_version"` // 表单内从 1 开始递增的版本号
+	Title       string         `gorm:"type:varchar(255);not null"`
+	Description string         `gorm:"type:text"`
+	Definition  datatypes.JSON `gorm:"not null"`
+	CreatedAt   time.Time
+}
+
+// TableName 指定 FormVersion 模型对应的数据库表名
+func (FormVersion) TableName() string {
+	return "form_versions"
+}
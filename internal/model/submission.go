@@ -2,6 +2,7 @@
 package model
 
 import (
+	"encoding/json"
 	"time"
 
 	"gorm.io/datatypes"
@@ -10,14 +11,19 @@ import (
 // Submission 对应于数据库中的 `submissions` 表
 type Submission struct {
 	ID              uint           `gorm:"primarykey"`
-	FormID          uint           `gorm:"not null"`
-	SubmitterID     *uint          `gorm:"null"`     // 提交者ID, 允许匿名
-	Data            datatypes.JSON `gorm:"not null"` // 用户提交的答案数据
-	RawScore        *int           `gorm:"null"`     // 原始得分
-	MaxScore        *int           `gorm:"null"`     // 总分
-	DurationSeconds *uint          `gorm:"null"`     // 答题用时
+	FormID          uint           `gorm:"not null;uniqueIndex:idx_form_idempotency_key"`
+	FormVersionID   *uint          `gorm:"null;index"` // 作答时表单的版本，为空表示表单当时还没有版本快照
+	SubmitterID     *uint          `gorm:"null"`       // 提交者ID, 允许匿名
+	Data            datatypes.JSON `gorm:"not null"`   // 用户提交的答案数据
+	RawScore        *int           `gorm:"null"`       // 原始得分
+	MaxScore        *int           `gorm:"null"`       // 总分
+	DurationSeconds *uint          `gorm:"null"`       // 答题用时
 	ClientIP        string         `gorm:"type:varchar(45)"`
 	UserAgent       string         `gorm:"type:text"`
+	IdempotencyKey  *string        `gorm:"type:varchar(64);uniqueIndex:idx_form_idempotency_key"` // 客户端提供的幂等键，防止重试产生重复记录
+	ServedQuestions datatypes.JSON `gorm:"null"`                                                  // 随机抽题时本次答题看到的问题ID列表
+	Overtime        bool           `gorm:"not null;default:false"`                                // 是否在限时加宽限时间之后才提交
+	HiddenFields    datatypes.JSON `gorm:"null"`                                                  // 隐藏字段的值，例如来自 URL 的 source、uid 等参数
 	CreatedAt       time.Time
 
 	// 定义关联关系
@@ -25,6 +31,18 @@ type Submission struct {
 	Submitter User `gorm:"foreignKey:SubmitterID"`
 }
 
+// ServedQuestionIDs 返回随机抽题时答题者看到的问题ID，未随机抽题时返回 nil
+func (s *Submission) ServedQuestionIDs() []string {
+	if len(s.ServedQuestions) == 0 {
+		return nil
+	}
+	var questionIDs []string
+	if err := json.Unmarshal(s.ServedQuestions, &questionIDs); err != nil {
+		return nil
+	}
+	return questionIDs
+}
+
 // TableName 指定 Submission 模型对应的数据库表名
 func (Submission) TableName() string {
 	return "submissions"
@@ -0,0 +1,31 @@
+// Package model 定义了与数据库表对应的 GORM 模型
+package model
+
+import "time"
+
+// 提交 outbox 消息的状态
+const (
+	OutboxStatusPending    uint8 = 0 // 待投递
+	OutboxStatusDelivered  uint8 = 1 // 已投递给 consumer，等待确认
+	OutboxStatusDeadLetter uint8 = 2 // 多次处理失败，已转入死信
+)
+
+// SubmissionOutbox 对应于数据库中的 `submission_outbox` 表
+// 队列驱动为 mysql 时，提交消息先写入该表，再由 consumer 轮询处理，处理成功后删除
+type SubmissionOutbox struct {
+	ID          uint64     `gorm:"primarykey"`
+	Payload     []byte     `gorm:"type:mediumblob;not null"`
+	Status      uint8      `gorm:"type:tinyint unsigned;not null;default:0;index:idx_outbox_status_delivered"`
+	Consumer    string     `gorm:"type:varchar(255);default:''"`
+	Deliveries  int64      `gorm:"not null;default:0"`
+	DeliveredAt *time.Time `gorm:"null;index:idx_outbox_status_delivered"`
+	FormID      uint       `gorm:"not null;default:0"` // 仅死信记录使用
+	Reason      string     `gorm:"type:text"`          // 仅死信记录使用
+	FailedAt    *time.Time `gorm:"null"`               // 仅死信记录使用
+	CreatedAt   time.Time
+}
+
+// TableName 指定 SubmissionOutbox 模型对应的数据库表名
+func (SubmissionOutbox) TableName() string {
+	return "submission_outbox"
+}
@@ -0,0 +1,26 @@
+// Package model 定义了与数据库表对应的 GORM 模型
+package model
+
+import "time"
+
+// UploadedFile 对应于数据库中的 `uploaded_files` 表
+// 文件上传题的文件在提交之前预先上传，提交的答案中只包含文件令牌（Token）。
+// 提交写入数据库时文件绑定到该提交，每个文件只能被一次提交使用；超过保留时间仍未使用的文件会被清理
+type UploadedFile struct {
+	ID          uint   `gorm:"primarykey"`
+	Token       string `gorm:"type:varchar(64);not null;uniqueIndex"`
+	FormID      uint   `gorm:"not null;index"`
+	QuestionID  string `gorm:"type:varchar(64);not null"`
+	Name        string `gorm:"type:varchar(255);not null"` // 上传时的原始文件名
+	ContentType string `gorm:"type:varchar(255);not null"` // 根据文件内容检测出的 MIME 类型
+	Size        int64  `gorm:"not null"`
+	StorageKey  string `gorm:"type:varchar(255);not null"` // 文件在存储中的 key
+	CreatedAt   time.Time
+
+	SubmissionID *uint `gorm:"index"` // 使用该文件的提交，为 nil 表示尚未被提交使用
+}
+
+// TableName 指定 UploadedFile 模型对应的数据库表名
+func (UploadedFile) TableName() string {
+	return "uploaded_files"
+}
@@ -0,0 +1,243 @@
+// Package question 定义了表单题型的元数据结构以及可插拔的题型注册表
+package question
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+func init() {
+	Register(singleChoiceType{name: "single_choice"})
+	Register(singleChoiceType{name: "judgment"}) // 判断题本质上是只有两个选项的单选题
+	Register(singleChoiceType{name: "dropdown"}) // 下拉选择题只是展示方式不同，答案与单选题相同
+	Register(multiChoiceType{})
+}
+
+// --- 单选题 / 判断题：答案是一个选项ID字符串 ---
+
+type singleChoiceType struct {
+	name string
+}
+
+func (t singleChoiceType) Name() string { return t.name }
+
+func (t singleChoiceType) ValidateAnswer(q *Question, raw json.RawMessage) error {
+	var optID string
+	if err := json.Unmarshal(raw, &optID); err != nil {
+		return errors.New("answer must be a string")
+	}
+	if !q.HasOption(optID) {
+		return fmt.Errorf("option %q does not exist", optID)
+	}
+	return nil
+}
+
+func (t singleChoiceType) NewAggregator(q *Question) Aggregator {
+	return newOptionCounter(q)
+}
+
+func (t singleChoiceType) FormatAnswer(q *Question, raw json.RawMessage) interface{} {
+	var optID string
+	if err := json.Unmarshal(raw, &optID); err != nil {
+		return string(raw)
+	}
+	if text, ok := q.OptionText(optID); ok {
+		return text
+	}
+	return optID // 回退显示ID
+}
+
+func (t singleChoiceType) Grade(q *Question, raw json.RawMessage) int {
+	var optID, correct string
+	if json.Unmarshal(raw, &optID) != nil || json.Unmarshal(q.CorrectAnswer, &correct) != nil {
+		return 0
+	}
+	if optID == correct {
+		return q.Score
+	}
+	return q.applyPenalty(0, 1)
+}
+
+func (t singleChoiceType) BuildFilter(jsonPath string, cond FilterCondition) (string, []interface{}) {
+	return buildScalarFilter(jsonPath, cond)
+}
+
+// --- 多选题：答案是选项ID数组 ---
+
+type multiChoiceType struct{}
+
+func (multiChoiceType) Name() string { return "multi_choice" }
+
+func (multiChoiceType) ValidateAnswer(q *Question, raw json.RawMessage) error {
+	var optIDs []string
+	if err := json.Unmarshal(raw, &optIDs); err != nil {
+		return errors.New("answer must be an array of strings")
+	}
+	seen := make(map[string]bool, len(optIDs))
+	for _, optID := range optIDs {
+		if !q.HasOption(optID) {
+			return fmt.Errorf("option %q does not exist", optID)
+		}
+		if seen[optID] {
+			return fmt.Errorf("option %q is selected more than once", optID)
+		}
+		seen[optID] = true
+	}
+	return nil
+}
+
+func (multiChoiceType) NewAggregator(q *Question) Aggregator {
+	return newOptionCounter(q)
+}
+
+func (multiChoiceType) FormatAnswer(q *Question, raw json.RawMessage) interface{} {
+	var optIDs []string
+	if err := json.Unmarshal(raw, &optIDs); err != nil {
+		return string(raw)
+	}
+	texts := make([]string, 0, len(optIDs))
+	for _, optID := range optIDs {
+		if text, ok := q.OptionText(optID); ok {
+			texts = append(texts, text)
+		}
+	}
+	return strings.Join(texts, ", ")
+}
+
+func (multiChoiceType) Grade(q *Question, raw json.RawMessage) int {
+	var selected, correct []string
+	if json.Unmarshal(raw, &selected) != nil || json.Unmarshal(q.CorrectAnswer, &correct) != nil || len(correct) == 0 {
+		return 0
+	}
+	correctSet := make(map[string]bool, len(correct))
+	for _, optID := range correct {
+		correctSet[optID] = true
+	}
+	hits, wrong := 0, 0
+	for _, optID := range selected {
+		if correctSet[optID] {
+			hits++
+		} else {
+			wrong++
+		}
+	}
+
+	mode := ScoringAllOrNothing
+	if q.Scoring != nil && q.Scoring.Mode != "" {
+		mode = q.Scoring.Mode
+	}
+	switch mode {
+	case ScoringPartial:
+		if wrong > 0 {
+			return q.applyPenalty(0, wrong)
+		}
+		return q.Score * hits / len(correctSet)
+	case ScoringPerOption:
+		return q.applyPenalty(q.Score*hits/len(correctSet), wrong)
+	default:
+		if hits == len(correctSet) && wrong == 0 {
+			return q.Score
+		}
+		// 全对才得分的模式下，答错整体只扣一次
+		if wrong > 0 {
+			return q.applyPenalty(0, 1)
+		}
+		return 0
+	}
+}
+
+// PrefillAnswer 把逗号分隔的选项ID转换为答案
+func (multiChoiceType) PrefillAnswer(q *Question, value string) (json.RawMessage, error) {
+	return prefillList(value)
+}
+
+func (multiChoiceType) BuildFilter(jsonPath string, cond FilterCondition) (string, []interface{}) {
+	if len(cond.Value) == 0 {
+		return "", nil
+	}
+	// 构造一个 JSON 数组用于查询, e.g., '["val1", "val2"]'
+	placeholders := make([]string, 0, len(cond.Value))
+	args := make([]interface{}, 0, len(cond.Value)+1)
+	for _, v := range cond.Value {
+		placeholders = append(placeholders, "?")
+		args = append(args, v)
+	}
+	jsonArrayForQuery := fmt.Sprintf("CAST(JSON_ARRAY(%s) AS JSON)", strings.Join(placeholders, ","))
+
+	switch cond.Operator {
+	case "contains": // 包含 cond.Value 中的任意一个
+		return fmt.Sprintf("JSON_OVERLAPS(data->'%s', %s)", jsonPath, jsonArrayForQuery), args
+	case "not_contains": // 不包含 cond.Value 中的任何一个
+		return fmt.Sprintf("NOT JSON_OVERLAPS(data->'%s', %s)", jsonPath, jsonArrayForQuery), args
+	case "equals": // 完全匹配（忽略顺序）
+		// 检查两个数组长度是否相等 并且 数据库中的数组完全包含查询数组的所有元素
+		clause := fmt.Sprintf("JSON_LENGTH(data->'%s') = ? AND JSON_CONTAINS(data->'%s', %s)", jsonPath, jsonPath, jsonArrayForQuery)
+		return clause, append([]interface{}{len(cond.Value)}, args...)
+	}
+	return "", nil
+}
+
+// optionCounter 统计每个选项被选择的次数，适用于所有基于选项的题型
+type optionCounter struct {
+	q      *Question
+	counts map[string]int
+}
+
+func newOptionCounter(q *Question) *optionCounter {
+	return &optionCounter{q: q, counts: make(map[string]int)}
+}
+
+func (a *optionCounter) Add(raw json.RawMessage) {
+	// 单选题的答案是 string，多选题的答案是 []string
+	var optID string
+	if err := json.Unmarshal(raw, &optID); err == nil {
+		a.counts[optID]++
+		return
+	}
+	var optIDs []string
+	if err := json.Unmarshal(raw, &optIDs); err == nil {
+		for _, id := range optIDs {
+			a.counts[id]++
+		}
+	}
+}
+
+func (a *optionCounter) Merge(other Aggregator) {
+	o, ok := other.(*optionCounter)
+	if !ok {
+		return
+	}
+	for id, n := range o.counts {
+		a.counts[id] += n
+	}
+	a.q = a.q.mergeOptions(o.q)
+}
+
+func (a *optionCounter) Fill(stat *QuestionStat) {
+	stat.OptionStats = make([]OptionStat, 0, len(a.q.Options))
+	for _, opt := range a.q.Options {
+		stat.OptionStats = append(stat.OptionStats, OptionStat{
+			Text:  opt.Text,
+			Count: a.counts[opt.ID],
+		})
+	}
+}
+
+// buildScalarFilter 为答案是单个字符串的题型生成 equals / not_equals 条件
+func buildScalarFilter(jsonPath string, cond FilterCondition) (string, []interface{}) {
+	if len(cond.Value) == 0 {
+		return "", nil
+	}
+	value := cond.Value[0] // 单值
+	switch cond.Operator {
+	case "equals":
+		// JSON_EXTRACT 返回带引号的字符串, JSON_UNQUOTE 去掉引号
+		return fmt.Sprintf("JSON_UNQUOTE(data->'%s') = ?", jsonPath), []interface{}{value}
+	case "not_equals":
+		// 检查值不等于或者该键不存在
+		return fmt.Sprintf("(data->'%s' IS NULL OR JSON_UNQUOTE(data->'%s') != ?)", jsonPath, jsonPath), []interface{}{value}
+	}
+	return "", nil
+}
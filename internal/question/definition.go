@@ -0,0 +1,186 @@
+// Package question 定义了表单题型的元数据结构以及可插拔的题型注册表
+package question
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// Option 是选择类题目中的单个选项
+type Option struct {
+	ID   string `json:"id"`
+	Text string `json:"text"`
+}
+
+// Question 是表单定义中的单个问题
+type Question struct {
+	ID        string   `json:"id"`
+	Type      string   `json:"type"`
+	Title     string   `json:"title"`
+	Required  bool     `json:"required"`
+	MinLength int      `json:"minLength"` // 仅用于填空题，0 表示不限制
+	MaxLength int      `json:"maxLength"` // 仅用于填空题，0 表示使用默认上限
+	Options   []Option `json:"options"`
+
+	// 以下字段用于量表类题型和数字题：rating 使用 max，slider 和 number 使用 min/max/step
+	Min  *float64 `json:"min,omitempty"`
+	Max  *float64 `json:"max,omitempty"`
+	Step float64  `json:"step,omitempty"`
+
+	// 以下字段用于矩阵题：每一行在同一组列中作答
+	Rows     []Option `json:"rows,omitempty"`
+	Columns  []Option `json:"columns,omitempty"`
+	Multiple bool     `json:"multiple,omitempty"` // 每一行是否可以选择多列
+
+	// 以下字段用于排序题
+	TopK int `json:"topK,omitempty"` // 只需排列前 topK 个选项，0 表示对所有选项排序
+
+	// 以下字段用于带格式的输入题型，为空表示不限制
+	Pattern string `json:"pattern,omitempty"` // 答案需要完整匹配的正则表达式，用于填空、邮箱和电话题
+	MinDate string `json:"minDate,omitempty"` // 日期题的最早日期，格式为 2006-01-02
+	MaxDate string `json:"maxDate,omitempty"` // 日期题的最晚日期
+	MinTime string `json:"minTime,omitempty"` // 时间题的最早时间，格式为 15:04 或 15:04:05
+	MaxTime string `json:"maxTime,omitempty"` // 时间题的最晚时间
+
+	// 以下字段用于文件上传题
+	MaxSize  int64    `json:"maxSize,omitempty"`  // 单个文件的大小上限（字节），0 表示使用默认上限
+	MaxFiles int      `json:"maxFiles,omitempty"` // 最多上传的文件数，0 表示只能上传一个
+	Accept   []string `json:"accept,omitempty"`   // 允许的 MIME 类型，支持 "image/*" 形式的通配，为空时不限制
+
+	// 以下字段用于考试判分
+	Score         int             `json:"score,omitempty"`          // 题目分值，0 表示不计分
+	CorrectAnswer json.RawMessage `json:"correct_answer,omitempty"` // 标准答案，格式与提交的答案相同
+	Scoring       *ScoringRule    `json:"scoring,omitempty"`        // 判分规则，为空时按完全正确才得分处理
+
+	// 以下字段用于 URL 预填
+	Prefill string `json:"prefill,omitempty"` // 打开表单时用 URL 中该查询参数的值预填答案
+
+	// 以下字段用于条件逻辑
+	VisibleIf *Condition `json:"visibleIf,omitempty"` // 显示条件，为空时总是显示
+	Jumps     []JumpRule `json:"jumps,omitempty"`     // 作答后的跳转规则，按顺序取第一条满足条件的规则
+}
+
+// 表单类型，对应 settings.type
+const (
+	FormTypeSurvey = "survey"
+	FormTypeExam   = "exam"
+)
+
+// Settings 是表单级别的设置
+type Settings struct {
+	Type           string `json:"type"`           // 表单类型，为空时视为问卷
+	TimeLimit      int    `json:"timeLimit"`      // 限时（秒），0 表示不限时
+	ShuffleOptions bool   `json:"shuffleOptions"` // 是否为每次答题打乱选项顺序
+	MaxResponses   int    `json:"maxResponses"`   // 最多接受的提交数，达到后表单自动关闭，0 表示不限制
+}
+
+// Definition 对应 Form.Definition 字段中的 JSON 结构
+type Definition struct {
+	Settings  Settings   `json:"settings"`
+	Questions []Question `json:"questions"`
+	Pools     []Pool     `json:"pools,omitempty"` // 随机抽题的题库
+	Pages     []Page     `json:"pages,omitempty"` // 分页（分节），为空时所有问题在同一页
+
+	HiddenFields []HiddenField `json:"hiddenFields,omitempty"` // 从 URL 查询参数取值的隐藏字段
+	Quotas       []Quota       `json:"quotas,omitempty"`       // 按选项限制提交数的配额
+}
+
+// ParseDefinition 将 Form.Definition 的原始 JSON 解析为 Definition
+func ParseDefinition(raw []byte) (*Definition, error) {
+	var def Definition
+	if err := json.Unmarshal(raw, &def); err != nil {
+		return nil, err
+	}
+	def.orderByPages()
+	return &def, nil
+}
+
+// Validate 检查保存表单时的定义：问题ID不能重复；问题ID、矩阵行ID和隐藏字段名会拼接进按答案筛选的 SQL，
+// 必须满足 ValidKey
+func (d *Definition) Validate() error {
+	seen := make(map[string]bool, len(d.Questions))
+	for _, q := range d.Questions {
+		if !ValidKey(q.ID) {
+			return fmt.Errorf("question id %q is invalid", q.ID)
+		}
+		if seen[q.ID] {
+			return fmt.Errorf("question id %q is used more than once", q.ID)
+		}
+		seen[q.ID] = true
+		for _, row := range q.Rows {
+			if !ValidKey(row.ID) {
+				return fmt.Errorf("question %q: row id %q is invalid", q.ID, row.ID)
+			}
+		}
+	}
+	for _, field := range d.HiddenFields {
+		if !ValidKey(field.Name) {
+			return fmt.Errorf("hidden field name %q is invalid", field.Name)
+		}
+	}
+	return nil
+}
+
+// Randomized 报告表单是否需要按每次答题随机出题或打乱选项，此时需要先开始答题
+func (d *Definition) Randomized() bool {
+	return len(d.Pools) > 0 || d.Settings.ShuffleOptions
+}
+
+// RequiresAttempt 报告填写页是否必须先开始答题，再携带答题令牌获取题目：
+// 限时表单需要从开始答题时计时，随机出题的表单需要按答题ID抽题
+func (d *Definition) RequiresAttempt() bool {
+	return d.Settings.TimeLimit > 0 || d.Randomized()
+}
+
+// QuestionByID 按问题ID查找问题，找不到时返回 nil
+func (d *Definition) QuestionByID(id string) *Question {
+	for i := range d.Questions {
+		if d.Questions[i].ID == id {
+			return &d.Questions[i]
+		}
+	}
+	return nil
+}
+
+// HasOption 判断问题中是否存在指定的选项ID
+func (q *Question) HasOption(optID string) bool {
+	_, ok := q.OptionText(optID)
+	return ok
+}
+
+// OptionText 返回指定选项ID对应的文本
+func (q *Question) OptionText(optID string) (string, bool) {
+	for _, opt := range q.Options {
+		if opt.ID == optID {
+			return opt.Text, true
+		}
+	}
+	return "", false
+}
+
+// mergeOptions 返回 q 的副本，在末尾追加只在 other 中出现的选项、矩阵行和矩阵列，用于合并跨版本的统计
+func (q *Question) mergeOptions(other *Question) *Question {
+	merged := *q
+	merged.Options = appendMissingOptions(q.Options, other.Options)
+	merged.Rows = appendMissingOptions(q.Rows, other.Rows)
+	merged.Columns = appendMissingOptions(q.Columns, other.Columns)
+	return &merged
+}
+
+// appendMissingOptions 返回 options 的副本，并按顺序追加 others 中 ID 不在 options 里的选项
+func appendMissingOptions(options, others []Option) []Option {
+	result := append([]Option(nil), options...)
+	for _, opt := range others {
+		found := false
+		for _, existing := range result {
+			if existing.ID == opt.ID {
+				found = true
+				break
+			}
+		}
+		if !found {
+			result = append(result, opt)
+		}
+	}
+	return result
+}
@@ -0,0 +1,29 @@
+package question
+
+import "testing"
+
+func TestDefinitionValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		raw     string
+		wantErr string
+	}{
+		{"valid", `{"questions": [{"id": "q-1.a", "type": "text_input"}, {"id": "年龄", "type": "text_input"}], "hiddenFields": [{"name": "utm_source"}]}`, ""},
+		{"empty id", `{"questions": [{"id": "", "type": "text_input"}]}`, `question id "" is invalid`},
+		{"quote in id", `{"questions": [{"id": "q') OR 1=1 -- ", "type": "text_input"}]}`, `question id "q') OR 1=1 -- " is invalid`},
+		{"duplicate id", `{"questions": [{"id": "q", "type": "text_input"}, {"id": "q", "type": "number"}]}`, `question id "q" is used more than once`},
+		{"matrix row", `{"questions": [{"id": "m", "type": "matrix", "rows": [{"id": "r\"1"}], "columns": [{"id": "c"}]}]}`, `question "m": row id "r\"1" is invalid`},
+		{"hidden field", `{"hiddenFields": [{"name": "uid\\"}]}`, `hidden field name "uid\\" is invalid`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := mustParse(t, tt.raw).Validate()
+			if tt.wantErr == "" && err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if tt.wantErr != "" && (err == nil || err.Error() != tt.wantErr) {
+				t.Fatalf("error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
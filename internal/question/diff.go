@@ -0,0 +1,147 @@
+// Package question 定义了表单题型的元数据结构以及可插拔的题型注册表
+package question
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+)
+
+// FieldChange 是一个字段在两个版本之间的变化，字段在某一版本中不存在时对应的值为 null
+type FieldChange struct {
+	Field string      `json:"field"`
+	From  interface{} `json:"from"`
+	To    interface{} `json:"to"`
+}
+
+// QuestionChange 描述了一个被新增、删除或修改的问题
+type QuestionChange struct {
+	QuestionID string        `json:"question_id"`
+	Title      string        `json:"title"`
+	Changes    []FieldChange `json:"changes,omitempty"` // 仅在修改的问题中返回
+}
+
+// DefinitionDiff 是两个表单定义之间的差异
+type DefinitionDiff struct {
+	Changes      []FieldChange    `json:"changes"` // 表单级别的变化：settings 中的各项以及 pools 等其他顶层字段
+	Added        []QuestionChange `json:"added"`
+	Removed      []QuestionChange `json:"removed"`
+	Modified     []QuestionChange `json:"modified"`
+	OrderChanged bool             `json:"order_changed"` // 两个版本都有的问题之间的顺序是否发生了变化
+}
+
+// Diff 比较两个表单定义的原始 JSON
+// 比较直接在 JSON 对象上进行，因此题型新增的字段不需要修改这里也能被识别
+func Diff(from, to []byte) (*DefinitionDiff, error) {
+	var fromDef, toDef map[string]interface{}
+	if err := json.Unmarshal(from, &fromDef); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(to, &toDef); err != nil {
+		return nil, err
+	}
+
+	diff := &DefinitionDiff{
+		Changes:  make([]FieldChange, 0),
+		Added:    make([]QuestionChange, 0),
+		Removed:  make([]QuestionChange, 0),
+		Modified: make([]QuestionChange, 0),
+	}
+
+	// 1. 表单级别的字段
+	fromSettings, _ := fromDef["settings"].(map[string]interface{})
+	toSettings, _ := toDef["settings"].(map[string]interface{})
+	for _, c := range diffObjects(fromSettings, toSettings) {
+		c.Field = "settings." + c.Field
+		diff.Changes = append(diff.Changes, c)
+	}
+	delete(fromDef, "settings")
+	delete(toDef, "settings")
+	fromQuestions := questionObjects(fromDef["questions"])
+	toQuestions := questionObjects(toDef["questions"])
+	delete(fromDef, "questions")
+	delete(toDef, "questions")
+	diff.Changes = append(diff.Changes, diffObjects(fromDef, toDef)...)
+
+	// 2. 按问题ID匹配两个版本的问题
+	fromIndex := make(map[string]map[string]interface{}, len(fromQuestions))
+	for _, q := range fromQuestions {
+		fromIndex[questionField(q, "id")] = q
+	}
+	toIndex := make(map[string]bool, len(toQuestions))
+	var fromOrder, toOrder []string
+	for _, q := range toQuestions {
+		id := questionField(q, "id")
+		toIndex[id] = true
+		old, ok := fromIndex[id]
+		if !ok {
+			diff.Added = append(diff.Added, QuestionChange{QuestionID: id, Title: questionField(q, "title")})
+			continue
+		}
+		toOrder = append(toOrder, id)
+		if changes := diffObjects(old, q); len(changes) > 0 {
+			diff.Modified = append(diff.Modified, QuestionChange{QuestionID: id, Title: questionField(q, "title"), Changes: changes})
+		}
+	}
+	for _, q := range fromQuestions {
+		id := questionField(q, "id")
+		if !toIndex[id] {
+			diff.Removed = append(diff.Removed, QuestionChange{QuestionID: id, Title: questionField(q, "title")})
+			continue
+		}
+		fromOrder = append(fromOrder, id)
+	}
+	diff.OrderChanged = !reflect.DeepEqual(fromOrder, toOrder)
+	return diff, nil
+}
+
+// EqualJSON 判断两段 JSON 在语义上是否相同，忽略对象键的顺序和空白
+func EqualJSON(a, b []byte) bool {
+	var va, vb interface{}
+	if json.Unmarshal(a, &va) != nil || json.Unmarshal(b, &vb) != nil {
+		return false
+	}
+	return reflect.DeepEqual(va, vb)
+}
+
+// diffObjects 逐个字段比较两个 JSON 对象，按字段名排序返回变化
+func diffObjects(from, to map[string]interface{}) []FieldChange {
+	keys := make(map[string]bool, len(from)+len(to))
+	for k := range from {
+		keys[k] = true
+	}
+	for k := range to {
+		keys[k] = true
+	}
+	sorted := make([]string, 0, len(keys))
+	for k := range keys {
+		sorted = append(sorted, k)
+	}
+	sort.Strings(sorted)
+
+	changes := make([]FieldChange, 0)
+	for _, k := range sorted {
+		if !reflect.DeepEqual(from[k], to[k]) {
+			changes = append(changes, FieldChange{Field: k, From: from[k], To: to[k]})
+		}
+	}
+	return changes
+}
+
+// questionObjects 从解析后的 questions 数组中取出每个问题对象，忽略格式不正确的元素
+func questionObjects(v interface{}) []map[string]interface{} {
+	items, _ := v.([]interface{})
+	questions := make([]map[string]interface{}, 0, len(items))
+	for _, item := range items {
+		if q, ok := item.(map[string]interface{}); ok {
+			questions = append(questions, q)
+		}
+	}
+	return questions
+}
+
+// questionField 读取问题对象中的字符串字段
+func questionField(q map[string]interface{}, key string) string {
+	s, _ := q[key].(string)
+	return s
+}
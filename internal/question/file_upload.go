@@ -0,0 +1,162 @@
+// Package question 定义了表单题型的元数据结构以及可插拔的题型注册表
+package question
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// 文件上传题未配置 maxSize / maxFiles 时的默认限制
+const (
+	defaultMaxFileSize  = 10 << 20 // 10 MB
+	defaultMaxFileCount = 1
+)
+
+func init() {
+	Register(fileUploadType{})
+}
+
+// FileRef 是导出时文件上传题单元格的值，导出服务据此查找文件名并生成下载链接
+type FileRef struct {
+	Token string
+}
+
+// fileUploadType 是文件上传题。文件通过预上传接口上传并换取文件令牌，答案是文件令牌数组；
+// 令牌是否存在、是否属于该问题由提交服务查询数据库后校验
+type fileUploadType struct{}
+
+func (fileUploadType) Name() string { return "file_upload" }
+
+func (fileUploadType) ValidateAnswer(q *Question, raw json.RawMessage) error {
+	tokens, err := FileTokens(raw)
+	if err != nil {
+		return err
+	}
+	if len(tokens) > q.FileCountLimit() {
+		return fmt.Errorf("at most %d files can be uploaded", q.FileCountLimit())
+	}
+	seen := make(map[string]bool, len(tokens))
+	for _, token := range tokens {
+		if token == "" {
+			return errors.New("file token must not be empty")
+		}
+		if seen[token] {
+			return fmt.Errorf("file %q is submitted more than once", token)
+		}
+		seen[token] = true
+	}
+	return nil
+}
+
+func (fileUploadType) NewAggregator(q *Question) Aggregator {
+	return &fileCounter{}
+}
+
+func (fileUploadType) FormatAnswer(q *Question, raw json.RawMessage) interface{} {
+	tokens, err := FileTokens(raw)
+	if err != nil {
+		return string(raw)
+	}
+	return strings.Join(tokens, ", ")
+}
+
+// ExportColumns 为每个可上传的文件占用一列，只能上传一个文件时只占一列
+func (fileUploadType) ExportColumns(q *Question) []ExportColumn {
+	limit := q.FileCountLimit()
+	if limit == 1 {
+		return []ExportColumn{{Key: "1"}}
+	}
+	columns := make([]ExportColumn, limit)
+	for i := range columns {
+		columns[i] = ExportColumn{Key: fmt.Sprint(i + 1), Title: fmt.Sprintf("文件%d", i+1)}
+	}
+	return columns
+}
+
+func (fileUploadType) FormatColumns(q *Question, raw json.RawMessage) map[string]interface{} {
+	tokens, err := FileTokens(raw)
+	if err != nil {
+		return map[string]interface{}{"1": string(raw)}
+	}
+	values := make(map[string]interface{}, len(tokens))
+	for i, token := range tokens {
+		values[fmt.Sprint(i+1)] = FileRef{Token: token}
+	}
+	return values
+}
+
+// BuildFilter 不支持按文件筛选
+func (fileUploadType) BuildFilter(jsonPath string, cond FilterCondition) (string, []interface{}) {
+	return "", nil
+}
+
+// FileTokens 解析文件上传题的答案，返回其中的文件令牌
+func FileTokens(raw json.RawMessage) ([]string, error) {
+	var tokens []string
+	if err := json.Unmarshal(raw, &tokens); err != nil {
+		return nil, errors.New("answer must be an array of file tokens")
+	}
+	return tokens, nil
+}
+
+// FileSizeLimit 返回文件上传题中单个文件的大小上限（字节）
+func (q *Question) FileSizeLimit() int64 {
+	if q.MaxSize > 0 {
+		return q.MaxSize
+	}
+	return defaultMaxFileSize
+}
+
+// FileCountLimit 返回文件上传题最多可以上传的文件数
+func (q *Question) FileCountLimit() int {
+	if q.MaxFiles > 0 {
+		return q.MaxFiles
+	}
+	return defaultMaxFileCount
+}
+
+// AcceptsContentType 判断文件上传题是否接受给定的 MIME 类型。
+// contentTypes 是检测出的类型及其父类型（例如 docx 的父类型是 application/zip），任意一个匹配即可；
+// accept 中的 "image/*" 匹配所有 image 类型，accept 为空时接受任何类型
+func (q *Question) AcceptsContentType(contentTypes ...string) bool {
+	if len(q.Accept) == 0 {
+		return true
+	}
+	for _, pattern := range q.Accept {
+		pattern = strings.ToLower(strings.TrimSpace(pattern))
+		for _, contentType := range contentTypes {
+			contentType = strings.ToLower(contentType)
+			if prefix, ok := strings.CutSuffix(pattern, "/*"); ok {
+				if strings.HasPrefix(contentType, prefix+"/") {
+					return true
+				}
+			} else if contentType == pattern {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// fileCounter 统计文件上传题中上传的文件总数
+type fileCounter struct {
+	files int
+}
+
+func (a *fileCounter) Add(raw json.RawMessage) {
+	if tokens, err := FileTokens(raw); err == nil {
+		a.files += len(tokens)
+	}
+}
+
+func (a *fileCounter) Merge(other Aggregator) {
+	if o, ok := other.(*fileCounter); ok {
+		a.files += o.files
+	}
+}
+
+func (a *fileCounter) Fill(stat *QuestionStat) {
+	stat.FileCount = a.files
+}
@@ -0,0 +1,19 @@
+// Package question 定义了表单题型的元数据结构以及可插拔的题型注册表
+package question
+
+import "strings"
+
+// FilterCondition 定义了单个筛选条件
+type FilterCondition struct {
+	QuestionID   string   `json:"questionId"`
+	QuestionType string   `json:"questionType"`
+	Row          string   `json:"row,omitempty"`   // 矩阵题中要筛选的行ID
+	Field        string   `json:"field,omitempty"` // 按隐藏字段筛选时的字段名，此时忽略 questionId 和 questionType
+	Operator     string   `json:"operator"`        // "equals", "not_equals", "contains", "not_contains"；数值、日期和时间题型还支持 "gt", "gte", "lt", "lte" 以及需要两个值的 "between"
+	Value        []string `json:"value"`           // 答案值，使用数组以支持多选
+}
+
+// ValidKey 报告问题ID、矩阵行ID或隐藏字段名能否拼接进筛选 SQL 中的 JSON 路径：不能为空，也不能包含引号和反斜杠
+func ValidKey(key string) bool {
+	return key != "" && !strings.ContainsAny(key, `"'\`)
+}
@@ -0,0 +1,116 @@
+// Package question 定义了表单题型的元数据结构以及可插拔的题型注册表
+package question
+
+import (
+	"bytes"
+	"encoding/json"
+)
+
+// 多选题的判分模式
+const (
+	ScoringAllOrNothing = "all_or_nothing" // 与标准答案完全一致才得分（默认）
+	ScoringPartial      = "partial"        // 漏选按选对的比例得分，错选不得分
+	ScoringPerOption    = "per_option"     // 每个选对的选项按比例得分，每个错选的选项扣 penalty 分
+)
+
+// ScoringRule 描述了一道题的判分规则
+type ScoringRule struct {
+	Mode          string `json:"mode"`           // 仅用于多选题，取值见 Scoring* 常量
+	Penalty       int    `json:"penalty"`        // 负分：答错（多选题为每个错选的选项）扣除的分数
+	AllowNegative bool   `json:"allow_negative"` // 是否允许单题得分为负，默认最低为 0
+}
+
+// Grader 是支持按标准答案自动判分的题型额外实现的接口
+type Grader interface {
+	// Grade 返回已作答的答案的得分，只有设置了分值和标准答案的问题才会被调用
+	Grade(q *Question, raw json.RawMessage) int
+}
+
+// Score 是一份答卷的判分结果
+type Score struct {
+	RawScore int
+	MaxScore int
+}
+
+// AutoGradable 报告问题是否参与自动判分：设置了分值和标准答案，且题型支持判分
+func (q *Question) AutoGradable() bool {
+	if q.Score <= 0 || len(q.CorrectAnswer) == 0 {
+		return false
+	}
+	qt, ok := Lookup(q.Type)
+	if !ok {
+		return false
+	}
+	_, ok = qt.(Grader)
+	return ok
+}
+
+// ManuallyGraded 报告问题是否需要人工阅卷：考试中设置了分值但没有标准答案的填空题
+func (d *Definition) ManuallyGraded(q *Question) bool {
+	return d.Settings.Type == FormTypeExam && q.Type == "text_input" && q.Score > 0 && len(q.CorrectAnswer) == 0
+}
+
+// Grade 按标准答案为一份答卷判分，未作答的问题得 0 分
+// 需要人工阅卷的问题只计入总分，得分由阅卷结果另行累加；被条件逻辑隐藏的问题不计入总分。
+// 表单中没有计分的问题时返回 nil
+func (d *Definition) Grade(data []byte) *Score {
+	var answers map[string]json.RawMessage
+	_ = json.Unmarshal(data, &answers)
+
+	visible := d.Visible(answers)
+	var score *Score
+	for i := range d.Questions {
+		q := &d.Questions[i]
+		manual := d.ManuallyGraded(q)
+		if !manual && !q.AutoGradable() {
+			continue
+		}
+		if !visible[q.ID] {
+			continue
+		}
+		if score == nil {
+			score = &Score{}
+		}
+		score.MaxScore += q.Score
+		if manual {
+			continue
+		}
+		score.RawScore += q.AutoGrade(answers[q.ID])
+	}
+	return score
+}
+
+// AutoGrade 按标准答案为单题判分，未作答得 0 分；调用方需先确认 AutoGradable
+func (q *Question) AutoGrade(raw json.RawMessage) int {
+	if isNullAnswer(raw) {
+		return 0
+	}
+	qt, _ := Lookup(q.Type)
+	return qt.(Grader).Grade(q, raw)
+}
+
+// applyPenalty 按判分规则扣除负分，并在不允许负分时把得分限制为不低于 0
+func (q *Question) applyPenalty(points, wrong int) int {
+	if q.Scoring == nil {
+		return max(points, 0)
+	}
+	points -= q.Scoring.Penalty * wrong
+	if !q.Scoring.AllowNegative && points < 0 {
+		return 0
+	}
+	return points
+}
+
+// isNullAnswer 判断答案是否为 JSON null
+func isNullAnswer(raw json.RawMessage) bool {
+	return len(raw) == 0 || string(raw) == "null"
+}
+
+// IsEmptyAnswer 判断答案是否为空（null、空字符串、空数组或空对象）
+func IsEmptyAnswer(raw json.RawMessage) bool {
+	switch string(bytes.TrimSpace(raw)) {
+	case "", "null", `""`, "[]", "{}":
+		return true
+	}
+	return false
+}
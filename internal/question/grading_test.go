@@ -0,0 +1,98 @@
+package question
+
+import (
+	"fmt"
+	"testing"
+)
+
+// mustParse 解析测试用的表单定义
+func mustParse(t *testing.T, raw string) *Definition {
+	t.Helper()
+	def, err := ParseDefinition([]byte(raw))
+	if err != nil {
+		t.Fatalf("ParseDefinition: %v", err)
+	}
+	return def
+}
+
+func TestMultiChoiceGrade(t *testing.T) {
+	tests := []struct {
+		name    string
+		scoring string
+		answer  string
+		want    int
+	}{
+		{"all or nothing, exact", ``, `["a","b"]`, 6},
+		{"all or nothing, missing one", ``, `["a"]`, 0},
+		{"all or nothing, penalty once", `{"penalty": 2, "allow_negative": true}`, `["a","c","d"]`, -2},
+		{"partial, missing one", `{"mode": "partial"}`, `["a"]`, 3},
+		{"partial, wrong option", `{"mode": "partial"}`, `["a","c"]`, 0},
+		{"partial, all correct", `{"mode": "partial"}`, `["b","a"]`, 6},
+		{"per option, hit and miss", `{"mode": "per_option", "penalty": 1}`, `["a","c"]`, 2},
+		{"per option, floored at zero", `{"mode": "per_option", "penalty": 5}`, `["a","c"]`, 0},
+		{"per option, negative allowed", `{"mode": "per_option", "penalty": 2, "allow_negative": true}`, `["c","d"]`, -4},
+		{"unanswered", ``, `null`, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			scoring := ""
+			if tt.scoring != "" {
+				scoring = `, "scoring": ` + tt.scoring
+			}
+			def := mustParse(t, fmt.Sprintf(`{"questions": [{"id": "q", "type": "multi_choice", "score": 6,
+				"options": [{"id": "a"}, {"id": "b"}, {"id": "c"}, {"id": "d"}],
+				"correct_answer": ["a","b"]%s}]}`, scoring))
+			score := def.Grade([]byte(`{"q": ` + tt.answer + `}`))
+			if score == nil {
+				t.Fatal("Grade returned nil")
+			}
+			if score.RawScore != tt.want {
+				t.Errorf("Grade(%s) = %d, want %d", tt.answer, score.RawScore, tt.want)
+			}
+		})
+	}
+}
+
+func TestDefinitionGrade(t *testing.T) {
+	def := mustParse(t, `{
+		"settings": {"type": "exam"},
+		"questions": [
+			{"id": "q1", "type": "single_choice", "score": 2, "options": [{"id": "a"}, {"id": "b"}], "correct_answer": "a"},
+			{"id": "q2", "type": "text_input", "score": 3, "correct_answer": " Paris "},
+			{"id": "q3", "type": "text_input", "score": 5},
+			{"id": "q4", "type": "single_choice", "score": 4, "options": [{"id": "a"}, {"id": "b"}], "correct_answer": "b",
+			 "visibleIf": {"question": "q1", "op": "equals", "value": "b"}},
+			{"id": "q5", "type": "text_input"}
+		]
+	}`)
+
+	tests := []struct {
+		name    string
+		answers string
+		raw     int
+		max     int
+	}{
+		{"all correct, follow-up hidden", `{"q1": "a", "q2": "paris", "q3": "essay"}`, 5, 10},
+		{"follow-up shown", `{"q1": "b", "q2": "rome", "q4": "b"}`, 4, 14},
+		{"unanswered", `{}`, 0, 10},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			score := def.Grade([]byte(tt.answers))
+			if score == nil {
+				t.Fatal("Grade returned nil")
+			}
+			if score.RawScore != tt.raw || score.MaxScore != tt.max {
+				t.Errorf("Grade(%s) = %d/%d, want %d/%d", tt.answers, score.RawScore, score.MaxScore, tt.raw, tt.max)
+			}
+		})
+	}
+
+	if !def.ManuallyGraded(&def.Questions[2]) || def.ManuallyGraded(&def.Questions[1]) {
+		t.Error("only the text question without a correct answer should be manually graded")
+	}
+	survey := mustParse(t, `{"questions": [{"id": "q", "type": "text_input"}]}`)
+	if score := survey.Grade([]byte(`{"q": "x"}`)); score != nil {
+		t.Errorf("Grade of a survey = %+v, want nil", score)
+	}
+}
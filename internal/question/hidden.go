@@ -0,0 +1,141 @@
+// Package question 定义了表单题型的元数据结构以及可插拔的题型注册表
+package question
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// MaxHiddenFieldLength 是隐藏字段值的最大长度（按字符计）
+const MaxHiddenFieldLength = 255
+
+// HiddenField 是表单定义中的隐藏字段：填写页不显示，值来自打开表单时 URL 中同名的查询参数，
+// 例如 ?source=newsletter&uid=123，随提交一起保存
+type HiddenField struct {
+	Name    string `json:"name"`              // 查询参数名，也是提交和筛选时使用的字段名
+	Title   string `json:"title,omitempty"`   // 导出和统计时显示的名称，为空时使用 name
+	Default string `json:"default,omitempty"` // URL 中没有该参数时使用的值
+}
+
+// Prefiller 是题型额外实现的接口，用于把 URL 查询参数转换为该题型的答案；
+// 未实现该接口的题型把参数值作为字符串答案
+type Prefiller interface {
+	PrefillAnswer(q *Question, value string) (json.RawMessage, error)
+}
+
+// DisplayTitle 返回隐藏字段在导出和统计中显示的名称
+func (f *HiddenField) DisplayTitle() string {
+	if f.Title != "" {
+		return f.Title
+	}
+	return f.Name
+}
+
+// HiddenFieldByName 按名称查找隐藏字段，找不到时返回 nil
+func (d *Definition) HiddenFieldByName(name string) *HiddenField {
+	for i := range d.HiddenFields {
+		if d.HiddenFields[i].Name == name {
+			return &d.HiddenFields[i]
+		}
+	}
+	return nil
+}
+
+// ResolveHiddenFields 按定义中的隐藏字段从 lookup 中取值，缺少的字段使用默认值，没有默认值时不返回该字段；
+// 超长的值被截断为 MaxHiddenFieldLength 个字符
+func (d *Definition) ResolveHiddenFields(lookup func(name string) (string, bool)) map[string]string {
+	values := make(map[string]string, len(d.HiddenFields))
+	for _, field := range d.HiddenFields {
+		value, ok := lookup(field.Name)
+		if !ok {
+			if field.Default == "" {
+				continue
+			}
+			value = field.Default
+		}
+		if utf8.RuneCountInString(value) > MaxHiddenFieldLength {
+			value = string([]rune(value)[:MaxHiddenFieldLength])
+		}
+		values[field.Name] = value
+	}
+	return values
+}
+
+// Prefill 为配置了 prefill 的问题从 lookup 中取出查询参数并转换为答案，
+// 无法转换或不符合题型要求的值会被忽略，返回问题ID到答案的映射
+func (d *Definition) Prefill(lookup func(name string) (string, bool)) map[string]json.RawMessage {
+	answers := make(map[string]json.RawMessage)
+	for i := range d.Questions {
+		q := &d.Questions[i]
+		if q.Prefill == "" {
+			continue
+		}
+		value, ok := lookup(q.Prefill)
+		if !ok || value == "" {
+			continue
+		}
+		qt, ok := Lookup(q.Type)
+		if !ok {
+			continue
+		}
+		var raw json.RawMessage
+		var err error
+		if prefiller, ok := qt.(Prefiller); ok {
+			raw, err = prefiller.PrefillAnswer(q, value)
+		} else {
+			raw, err = json.Marshal(value)
+		}
+		if err != nil || qt.ValidateAnswer(q, raw) != nil {
+			continue
+		}
+		answers[q.ID] = raw
+	}
+	return answers
+}
+
+// BuildHiddenFieldFilter 为按隐藏字段（cond.Field）筛选的条件生成 SQL 片段：
+// equals / not_equals 精确比较，contains / not_contains 按子串匹配
+func BuildHiddenFieldFilter(cond FilterCondition) (string, []interface{}) {
+	if len(cond.Value) == 0 || !ValidKey(cond.Field) {
+		return "", nil
+	}
+	path := fmt.Sprintf("$.\"%s\"", cond.Field)
+	column := fmt.Sprintf("JSON_UNQUOTE(hidden_fields->'%s')", path)
+	value := cond.Value[0]
+	switch cond.Operator {
+	case "equals":
+		return column + " = ?", []interface{}{value}
+	case "not_equals":
+		return fmt.Sprintf("(hidden_fields->'%s' IS NULL OR %s != ?)", path, column), []interface{}{value}
+	case "contains":
+		return column + " LIKE ?", []interface{}{"%" + escapeLike(value) + "%"}
+	case "not_contains":
+		return fmt.Sprintf("(hidden_fields->'%s' IS NULL OR %s NOT LIKE ?)", path, column), []interface{}{"%" + escapeLike(value) + "%"}
+	}
+	return "", nil
+}
+
+// escapeLike 转义 LIKE 模式中的通配符
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
+
+// prefillNumber 把查询参数解析为数字答案，供答案是数字的题型使用
+func prefillNumber(value string) (json.RawMessage, error) {
+	var number float64
+	if err := json.Unmarshal([]byte(value), &number); err != nil {
+		return nil, err
+	}
+	return json.Marshal(number)
+}
+
+// prefillList 把逗号分隔的查询参数解析为字符串数组答案，供多选题和排序题使用
+func prefillList(value string) (json.RawMessage, error) {
+	items := strings.Split(value, ",")
+	for i := range items {
+		items[i] = strings.TrimSpace(items[i])
+	}
+	return json.Marshal(items)
+}
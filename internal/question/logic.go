@@ -0,0 +1,247 @@
+// Package question 定义了表单题型的元数据结构以及可插拔的题型注册表
+package question
+
+import (
+	"encoding/json"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// 条件中支持的比较运算符
+const (
+	OpEquals      = "equals"
+	OpNotEquals   = "not_equals"
+	OpContains    = "contains"     // 多选题选中了指定选项（value 为数组时需全部选中），或文本包含指定内容
+	OpNotContains = "not_contains" // contains 的否定
+	OpGreater     = "gt"
+	OpGreaterEq   = "gte"
+	OpLess        = "lt"
+	OpLessEq      = "lte"
+	OpAnswered    = "answered"
+	OpNotAnswered = "not_answered"
+)
+
+// JumpToEnd 是跳转规则中表示直接结束答题的目标
+const JumpToEnd = "end"
+
+// Condition 是显示条件或跳转条件，可以是 all/any 组合条件，也可以是针对单个问题答案的比较
+//
+//	{"all": [{"question": "q1", "op": "equals", "value": "o1"}, {"question": "q2", "op": "gt", "value": 3}]}
+type Condition struct {
+	All []Condition `json:"all,omitempty"` // 全部满足 (AND)
+	Any []Condition `json:"any,omitempty"` // 任一满足 (OR)
+
+	Question string          `json:"question,omitempty"`
+	Op       string          `json:"op,omitempty"`
+	Value    json.RawMessage `json:"value,omitempty"`
+}
+
+// JumpRule 是问题上的跳转规则：作答该问题后如果条件满足，跳过中间的问题直接到达目标
+type JumpRule struct {
+	When Condition `json:"when"`
+	To   string    `json:"to"` // 目标问题ID、目标页面ID（跳到该页的第一道题），或 JumpToEnd
+}
+
+// Evaluate 按已显示问题的答案判断条件是否成立；未作答的问题只满足否定类的运算符
+func (c *Condition) Evaluate(answers map[string]json.RawMessage) bool {
+	if len(c.All) > 0 {
+		for i := range c.All {
+			if !c.All[i].Evaluate(answers) {
+				return false
+			}
+		}
+		return true
+	}
+	if len(c.Any) > 0 {
+		for i := range c.Any {
+			if c.Any[i].Evaluate(answers) {
+				return true
+			}
+		}
+		return false
+	}
+
+	raw, answered := answers[c.Question]
+	if !answered || IsEmptyAnswer(raw) {
+		return c.Op == OpNotAnswered || c.Op == OpNotEquals || c.Op == OpNotContains
+	}
+	var answer, value interface{}
+	if json.Unmarshal(raw, &answer) != nil {
+		return false
+	}
+	_ = json.Unmarshal(c.Value, &value)
+
+	switch c.Op {
+	case OpAnswered:
+		return true
+	case OpEquals:
+		return valuesEqual(answer, value)
+	case OpNotEquals:
+		return !valuesEqual(answer, value)
+	case OpContains:
+		return valueContains(answer, value)
+	case OpNotContains:
+		return !valueContains(answer, value)
+	case OpGreater, OpGreaterEq, OpLess, OpLessEq:
+		a, ok1 := toNumber(answer)
+		b, ok2 := toNumber(value)
+		if !ok1 || !ok2 {
+			return false
+		}
+		switch c.Op {
+		case OpGreater:
+			return a > b
+		case OpGreaterEq:
+			return a >= b
+		case OpLess:
+			return a < b
+		default:
+			return a <= b
+		}
+	}
+	return false
+}
+
+// HasLogic 报告表单是否配置了显示条件或跳转规则
+func (d *Definition) HasLogic() bool {
+	for i := range d.Pages {
+		if d.Pages[i].VisibleIf != nil {
+			return true
+		}
+	}
+	for i := range d.Questions {
+		if d.Questions[i].VisibleIf != nil || len(d.Questions[i].Jumps) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// Visible 按定义顺序执行显示条件和跳转规则，返回答题者能看到的问题ID集合
+// 条件只能引用排在前面且已显示的问题，隐藏问题的答案视为未作答；页面的显示条件在进入该页时计算。
+// 只允许向后跳转，目标不存在或位于当前问题之前的跳转规则会被忽略
+func (d *Definition) Visible(answers map[string]json.RawMessage) map[string]bool {
+	position := make(map[string]int, len(d.Questions)+len(d.Pages))
+	for i := range d.Questions {
+		position[d.Questions[i].ID] = i
+	}
+	pageOf := make(map[string]*Page)
+	for i := range d.Pages {
+		page := &d.Pages[i]
+		first := -1
+		for _, qID := range page.QuestionIDs {
+			if pos, ok := position[qID]; ok {
+				pageOf[qID] = page
+				if first < 0 || pos < first {
+					first = pos
+				}
+			}
+		}
+		if first >= 0 {
+			position[page.ID] = first
+		}
+	}
+
+	visible := make(map[string]bool, len(d.Questions))
+	shownAnswers := make(map[string]json.RawMessage, len(answers))
+	pageVisible := make(map[*Page]bool)
+	skipUntil := -1 // 跳转生效时，位置小于 skipUntil 的问题都被跳过
+	for i := range d.Questions {
+		q := &d.Questions[i]
+		if i < skipUntil {
+			continue
+		}
+		if page := pageOf[q.ID]; page != nil && page.VisibleIf != nil {
+			shown, evaluated := pageVisible[page]
+			if !evaluated {
+				shown = page.VisibleIf.Evaluate(shownAnswers)
+				pageVisible[page] = shown
+			}
+			if !shown {
+				continue
+			}
+		}
+		if q.VisibleIf != nil && !q.VisibleIf.Evaluate(shownAnswers) {
+			continue
+		}
+		visible[q.ID] = true
+		if raw, ok := answers[q.ID]; ok {
+			shownAnswers[q.ID] = raw
+		}
+		for _, jump := range q.Jumps {
+			target := len(d.Questions)
+			if jump.To != JumpToEnd {
+				pos, ok := position[jump.To]
+				if !ok || pos <= i {
+					continue
+				}
+				target = pos
+			}
+			if jump.When.Evaluate(shownAnswers) {
+				skipUntil = target
+				break
+			}
+		}
+	}
+	return visible
+}
+
+// valuesEqual 比较答案与条件中的值：数组按集合比较，数字与数字字符串按数值比较
+func valuesEqual(answer, value interface{}) bool {
+	if a, ok := answer.([]interface{}); ok {
+		b, ok := value.([]interface{})
+		if !ok || len(a) != len(b) {
+			return false
+		}
+		for _, v := range b {
+			if !valueContains(a, v) {
+				return false
+			}
+		}
+		return true
+	}
+	if x, ok := toNumber(answer); ok {
+		if y, ok := toNumber(value); ok {
+			return x == y
+		}
+	}
+	return reflect.DeepEqual(answer, value)
+}
+
+// valueContains 判断数组答案是否包含指定的值（value 为数组时需全部包含），或文本答案是否包含指定文本
+func valueContains(answer, value interface{}) bool {
+	switch a := answer.(type) {
+	case []interface{}:
+		if values, ok := value.([]interface{}); ok {
+			for _, v := range values {
+				if !valueContains(a, v) {
+					return false
+				}
+			}
+			return true
+		}
+		for _, item := range a {
+			if valuesEqual(item, value) {
+				return true
+			}
+		}
+	case string:
+		if s, ok := value.(string); ok {
+			return strings.Contains(a, s)
+		}
+	}
+	return false
+}
+
+// toNumber 把 JSON 数字或数字字符串转换为 float64
+func toNumber(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case string:
+		f, err := strconv.ParseFloat(strings.TrimSpace(n), 64)
+		return f, err == nil
+	}
+	return 0, false
+}
@@ -0,0 +1,87 @@
+package question
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+// answersOf 把 JSON 对象形式的答案解析为 Visible 使用的格式
+func answersOf(t *testing.T, raw string) map[string]json.RawMessage {
+	t.Helper()
+	var answers map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(raw), &answers); err != nil {
+		t.Fatalf("invalid answers %s: %v", raw, err)
+	}
+	return answers
+}
+
+func TestVisible(t *testing.T) {
+	def := mustParse(t, `{
+		"questions": [
+			{"id": "q1", "type": "single_choice", "options": [{"id": "yes"}, {"id": "no"}],
+			 "jumps": [{"when": {"question": "q1", "op": "equals", "value": "no"}, "to": "q4"}]},
+			{"id": "q2", "type": "rating", "visibleIf": {"question": "q1", "op": "equals", "value": "yes"}},
+			{"id": "q3", "type": "text_input", "visibleIf": {"all": [
+				{"question": "q2", "op": "gte", "value": 4},
+				{"question": "q1", "op": "answered"}
+			]}},
+			{"id": "q4", "type": "text_input",
+			 "jumps": [{"when": {"question": "q4", "op": "equals", "value": "stop"}, "to": "end"}]},
+			{"id": "q5", "type": "text_input"}
+		]
+	}`)
+
+	tests := []struct {
+		name    string
+		answers string
+		want    []string
+	}{
+		{"no answers", `{}`, []string{"q1", "q4", "q5"}},
+		{"show follow-up", `{"q1": "yes", "q2": 3}`, []string{"q1", "q2", "q4", "q5"}},
+		{"nested all condition", `{"q1": "yes", "q2": 5}`, []string{"q1", "q2", "q3", "q4", "q5"}},
+		{"jump skips questions", `{"q1": "no", "q2": 5, "q3": "x"}`, []string{"q1", "q4", "q5"}},
+		{"jump to end", `{"q1": "no", "q4": "stop"}`, []string{"q1", "q4"}},
+		{"hidden answer does not count", `{"q1": "no", "q2": 5}`, []string{"q1", "q4", "q5"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			visible := def.Visible(answersOf(t, tt.answers))
+			want := make(map[string]bool, len(tt.want))
+			for _, id := range tt.want {
+				want[id] = true
+			}
+			if !reflect.DeepEqual(visible, want) {
+				t.Errorf("Visible(%s) = %v, want %v", tt.answers, visible, want)
+			}
+		})
+	}
+}
+
+func TestVisibleWithPageCondition(t *testing.T) {
+	def := mustParse(t, `{
+		"questions": [
+			{"id": "q1", "type": "single_choice", "options": [{"id": "a"}, {"id": "b"}]},
+			{"id": "q2", "type": "text_input"},
+			{"id": "q3", "type": "text_input"}
+		],
+		"pages": [
+			{"id": "p1", "questionIds": ["q1"]},
+			{"id": "p2", "questionIds": ["q2", "q3"], "visibleIf": {"question": "q1", "op": "not_equals", "value": "b"}}
+		]
+	}`)
+
+	tests := []struct {
+		answers string
+		want    int
+	}{
+		{`{"q1": "a"}`, 3},
+		{`{"q1": "b"}`, 1},
+		{`{}`, 3}, // 未作答时 not_equals 成立
+	}
+	for _, tt := range tests {
+		if got := len(def.Visible(answersOf(t, tt.answers))); got != tt.want {
+			t.Errorf("Visible(%s) shows %d questions, want %d", tt.answers, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,233 @@
+// Package question 定义了表单题型的元数据结构以及可插拔的题型注册表
+package question
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+func init() {
+	Register(matrixType{})
+}
+
+// MatrixCell 是矩阵题统计表中的一格：某一行中某一列被选择的次数
+type MatrixCell struct {
+	ColumnID string `json:"column_id"`
+	Text     string `json:"text"`
+	Count    int    `json:"count"`
+}
+
+// MatrixRowStat 是矩阵题统计表中的一行
+type MatrixRowStat struct {
+	RowID   string       `json:"row_id"`
+	Text    string       `json:"text"`
+	Columns []MatrixCell `json:"columns"`
+}
+
+// matrixType 是矩阵题：每一行都在同一组列中作答。
+// 答案是行ID到列的映射，单选矩阵的值是一个列ID，多选矩阵（multiple 为 true）的值是列ID数组
+type matrixType struct{}
+
+func (matrixType) Name() string { return "matrix" }
+
+func (matrixType) ValidateAnswer(q *Question, raw json.RawMessage) error {
+	var answer map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &answer); err != nil || answer == nil {
+		return errors.New("answer must be an object of row IDs to column IDs")
+	}
+	for rowID, value := range answer {
+		if q.matrixRow(rowID) == nil {
+			return fmt.Errorf("row %q does not exist", rowID)
+		}
+		columns, err := q.matrixColumns(value)
+		if err != nil {
+			return fmt.Errorf("row %q: %v", rowID, err)
+		}
+		seen := make(map[string]bool, len(columns))
+		for _, colID := range columns {
+			if !q.hasMatrixColumn(colID) {
+				return fmt.Errorf("row %q: column %q does not exist", rowID, colID)
+			}
+			if seen[colID] {
+				return fmt.Errorf("row %q: column %q is selected more than once", rowID, colID)
+			}
+			seen[colID] = true
+		}
+	}
+	// 必答的矩阵题要求每一行都作答
+	if q.Required {
+		for _, row := range q.Rows {
+			if IsEmptyAnswer(answer[row.ID]) {
+				return fmt.Errorf("row %q is required", row.ID)
+			}
+		}
+	}
+	return nil
+}
+
+func (matrixType) NewAggregator(q *Question) Aggregator {
+	counts := make(map[string]map[string]int, len(q.Rows))
+	for _, row := range q.Rows {
+		counts[row.ID] = make(map[string]int, len(q.Columns))
+	}
+	return &matrixCounter{q: q, counts: counts}
+}
+
+func (matrixType) FormatAnswer(q *Question, raw json.RawMessage) interface{} {
+	columns := matrixType{}.FormatColumns(q, raw)
+	parts := make([]string, 0, len(q.Rows))
+	for _, row := range q.Rows {
+		if text, ok := columns[row.ID]; ok {
+			parts = append(parts, fmt.Sprintf("%s: %v", row.Text, text))
+		}
+	}
+	return strings.Join(parts, "; ")
+}
+
+// ExportColumns 每一行导出为一列
+func (matrixType) ExportColumns(q *Question) []ExportColumn {
+	columns := make([]ExportColumn, 0, len(q.Rows))
+	for _, row := range q.Rows {
+		columns = append(columns, ExportColumn{Key: row.ID, Title: row.Text})
+	}
+	return columns
+}
+
+// FormatColumns 把每一行选择的列转换为列文本，多选时用逗号连接
+func (matrixType) FormatColumns(q *Question, raw json.RawMessage) map[string]interface{} {
+	var answer map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &answer); err != nil {
+		return map[string]interface{}{}
+	}
+	result := make(map[string]interface{}, len(answer))
+	for rowID, value := range answer {
+		columns, err := q.matrixColumns(value)
+		if err != nil {
+			continue
+		}
+		texts := make([]string, 0, len(columns))
+		for _, colID := range columns {
+			texts = append(texts, q.matrixColumnText(colID))
+		}
+		result[rowID] = strings.Join(texts, ", ")
+	}
+	return result
+}
+
+// BuildFilter 按条件中的 row 筛选矩阵题某一行的答案：
+// equals / not_equals 比较单选矩阵该行选择的列，contains / not_contains 判断该行是否选择了任意一个给定的列
+func (matrixType) BuildFilter(jsonPath string, cond FilterCondition) (string, []interface{}) {
+	if !ValidKey(cond.Row) {
+		return "", nil
+	}
+	rowPath := fmt.Sprintf("%s.\"%s\"", jsonPath, cond.Row)
+	switch cond.Operator {
+	case "contains", "not_contains":
+		return multiChoiceType{}.BuildFilter(rowPath, cond)
+	}
+	return buildScalarFilter(rowPath, cond)
+}
+
+// matrixRow 按ID查找矩阵题的行
+func (q *Question) matrixRow(rowID string) *Option {
+	for i := range q.Rows {
+		if q.Rows[i].ID == rowID {
+			return &q.Rows[i]
+		}
+	}
+	return nil
+}
+
+// hasMatrixColumn 判断矩阵题中是否存在指定的列
+func (q *Question) hasMatrixColumn(colID string) bool {
+	for _, col := range q.Columns {
+		if col.ID == colID {
+			return true
+		}
+	}
+	return false
+}
+
+// matrixColumnText 返回列ID对应的文本，找不到时回退显示ID
+func (q *Question) matrixColumnText(colID string) string {
+	for _, col := range q.Columns {
+		if col.ID == colID {
+			return col.Text
+		}
+	}
+	return colID
+}
+
+// matrixColumns 解析矩阵题一行的答案：单选矩阵是一个列ID，多选矩阵是列ID数组
+func (q *Question) matrixColumns(raw json.RawMessage) ([]string, error) {
+	if isNullAnswer(raw) {
+		return nil, nil
+	}
+	if q.Multiple {
+		var columns []string
+		if err := json.Unmarshal(raw, &columns); err != nil {
+			return nil, errors.New("answer must be an array of column IDs")
+		}
+		return columns, nil
+	}
+	var column string
+	if err := json.Unmarshal(raw, &column); err != nil {
+		return nil, errors.New("answer must be a column ID")
+	}
+	if column == "" {
+		return nil, nil
+	}
+	return []string{column}, nil
+}
+
+// matrixCounter 统计矩阵题每一行中每一列被选择的次数
+type matrixCounter struct {
+	q      *Question
+	counts map[string]map[string]int
+}
+
+func (a *matrixCounter) Add(raw json.RawMessage) {
+	var answer map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &answer); err != nil {
+		return
+	}
+	for rowID, value := range answer {
+		rowCounts, ok := a.counts[rowID]
+		if !ok {
+			continue
+		}
+		columns, _ := a.q.matrixColumns(value)
+		for _, colID := range columns {
+			rowCounts[colID]++
+		}
+	}
+}
+
+func (a *matrixCounter) Merge(other Aggregator) {
+	o, ok := other.(*matrixCounter)
+	if !ok {
+		return
+	}
+	for rowID, rowCounts := range o.counts {
+		if a.counts[rowID] == nil {
+			a.counts[rowID] = make(map[string]int, len(rowCounts))
+		}
+		for colID, n := range rowCounts {
+			a.counts[rowID][colID] += n
+		}
+	}
+	a.q = a.q.mergeOptions(o.q)
+}
+
+func (a *matrixCounter) Fill(stat *QuestionStat) {
+	stat.Matrix = make([]MatrixRowStat, 0, len(a.q.Rows))
+	for _, row := range a.q.Rows {
+		rowStat := MatrixRowStat{RowID: row.ID, Text: row.Text, Columns: make([]MatrixCell, 0, len(a.q.Columns))}
+		for _, col := range a.q.Columns {
+			rowStat.Columns = append(rowStat.Columns, MatrixCell{ColumnID: col.ID, Text: col.Text, Count: a.counts[row.ID][col.ID]})
+		}
+		stat.Matrix = append(stat.Matrix, rowStat)
+	}
+}
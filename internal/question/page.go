@@ -0,0 +1,174 @@
+// Package question 定义了表单题型的元数据结构以及可插拔的题型注册表
+package question
+
+import (
+	"encoding/json"
+	"errors"
+)
+
+// Page 是表单中的一页（分节），按 QuestionIDs 的顺序包含若干问题
+// 页面的顺序决定了问题的展示顺序，没有被任何页面引用的问题排在最后一页之后
+type Page struct {
+	ID          string     `json:"id"`
+	Title       string     `json:"title"`
+	Description string     `json:"description,omitempty"`
+	QuestionIDs []string   `json:"questionIds"`
+	VisibleIf   *Condition `json:"visibleIf,omitempty"` // 页面的显示条件，不满足时整页的问题都被隐藏
+}
+
+// Paged 报告表单是否分页
+func (d *Definition) Paged() bool {
+	return len(d.Pages) > 0
+}
+
+// PageOf 返回问题所在的页面，问题不属于任何页面时返回 nil
+func (d *Definition) PageOf(questionID string) *Page {
+	for i := range d.Pages {
+		for _, qID := range d.Pages[i].QuestionIDs {
+			if qID == questionID {
+				return &d.Pages[i]
+			}
+		}
+	}
+	return nil
+}
+
+// orderByPages 按页面顺序重新排列 Questions，没有被页面引用的问题保持原有顺序排在最后
+// 解析定义时调用，之后的校验、统计和导出都按页面顺序处理问题
+func (d *Definition) orderByPages() {
+	if !d.Paged() {
+		return
+	}
+	ordered := make([]Question, 0, len(d.Questions))
+	placed := make(map[string]bool, len(d.Questions))
+	for _, page := range d.Pages {
+		for _, qID := range page.QuestionIDs {
+			if q := d.QuestionByID(qID); q != nil && !placed[qID] {
+				ordered = append(ordered, *q)
+				placed[qID] = true
+			}
+		}
+	}
+	for _, q := range d.Questions {
+		if !placed[q.ID] {
+			ordered = append(ordered, q)
+		}
+	}
+	d.Questions = ordered
+}
+
+// subsetPages 返回只引用 questionIDs 中问题的页面列表，页内问题按 questionIDs 的顺序排列，
+// 用于随机抽题后的定义
+func (d *Definition) subsetPages(questionIDs []string) []Page {
+	if !d.Paged() {
+		return nil
+	}
+	pages := make([]Page, 0, len(d.Pages))
+	for _, page := range d.Pages {
+		p := page
+		p.QuestionIDs = filterOrdered(questionIDs, page.QuestionIDs)
+		pages = append(pages, p)
+	}
+	return pages
+}
+
+// filterOrdered 返回 ordered 中同时出现在 members 里的元素，保持 ordered 的顺序
+func filterOrdered(ordered, members []string) []string {
+	in := make(map[string]bool, len(members))
+	for _, id := range members {
+		in[id] = true
+	}
+	result := make([]string, 0, len(members))
+	for _, id := range ordered {
+		if in[id] {
+			result = append(result, id)
+		}
+	}
+	return result
+}
+
+// PageProgress 描述按页获取的表单中当前页的位置
+type PageProgress struct {
+	Page        int    `json:"page"` // 从 1 开始的页码
+	TotalPages  int    `json:"total_pages"`
+	PageID      string `json:"page_id"`
+	Title       string `json:"title"`
+	Description string `json:"description,omitempty"`
+}
+
+// ErrPageOutOfRange 表示请求的页码超出了表单的页数
+var ErrPageOutOfRange = errors.New("page out of range")
+
+// SelectPage 从 PublicDefinition 生成的定义 JSON 中只保留第 page 页（从 1 开始）的问题，
+// 没有被任何页面引用的问题随最后一页返回。pages 大纲原样保留，以便填写页显示进度和执行页面跳转。
+// 未分页的表单视为只有一页
+func SelectPage(raw []byte, page int) ([]byte, *PageProgress, error) {
+	var doc map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &doc); err != nil {
+		return nil, nil, err
+	}
+	var pages []Page
+	if len(doc["pages"]) > 0 {
+		if err := json.Unmarshal(doc["pages"], &pages); err != nil {
+			return nil, nil, err
+		}
+	}
+	if len(pages) == 0 {
+		if page != 1 {
+			return nil, nil, ErrPageOutOfRange
+		}
+		return raw, &PageProgress{Page: 1, TotalPages: 1}, nil
+	}
+	if page < 1 || page > len(pages) {
+		return nil, nil, ErrPageOutOfRange
+	}
+	current := pages[page-1]
+
+	var questions []map[string]json.RawMessage
+	if err := json.Unmarshal(doc["questions"], &questions); err != nil {
+		return nil, nil, err
+	}
+	byID := make(map[string]map[string]json.RawMessage, len(questions))
+	for _, q := range questions {
+		var id string
+		_ = json.Unmarshal(q["id"], &id)
+		byID[id] = q
+	}
+	selected := make([]map[string]json.RawMessage, 0, len(current.QuestionIDs))
+	for _, qID := range current.QuestionIDs {
+		if q, ok := byID[qID]; ok {
+			selected = append(selected, q)
+		}
+	}
+	if page == len(pages) {
+		paged := make(map[string]bool)
+		for _, p := range pages {
+			for _, qID := range p.QuestionIDs {
+				paged[qID] = true
+			}
+		}
+		for _, q := range questions {
+			var id string
+			_ = json.Unmarshal(q["id"], &id)
+			if !paged[id] {
+				selected = append(selected, q)
+			}
+		}
+	}
+	questionsJSON, err := json.Marshal(selected)
+	if err != nil {
+		return nil, nil, err
+	}
+	doc["questions"] = questionsJSON
+	result, err := json.Marshal(doc)
+	if err != nil {
+		return nil, nil, err
+	}
+	return result, &PageProgress{
+		Page:        page,
+		TotalPages:  len(pages),
+		PageID:      current.ID,
+		Title:       current.Title,
+		Description: current.Description,
+	}, nil
+}
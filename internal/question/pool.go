@@ -0,0 +1,183 @@
+// Package question 定义了表单题型的元数据结构以及可插拔的题型注册表
+package question
+
+import (
+	"encoding/json"
+	"math/rand"
+)
+
+// Pool 是一个题库：每次答题从 QuestionIDs 中随机抽取 Draw 道题
+// 抽到的题目按随机顺序出现在题库中第一道题在 questions 中的位置
+type Pool struct {
+	ID          string   `json:"id"`
+	Draw        int      `json:"draw"` // 抽取题数，0 或不小于题库大小时全部抽取（只打乱顺序）
+	QuestionIDs []string `json:"questionIds"`
+}
+
+// Draw 按种子为一次答题抽题，并在开启 shuffleOptions 时打乱选项顺序
+// 结果只由 seed 决定，同一次答题多次调用得到相同的题目和顺序。
+// 返回按展示顺序排列的问题ID，以及打乱后的选项ID顺序（questionID -> optionIDs）
+func (d *Definition) Draw(seed int64) ([]string, map[string][]string) {
+	rng := rand.New(rand.NewSource(seed))
+
+	poolOf := make(map[string]int)
+	for i, pool := range d.Pools {
+		for _, qID := range pool.QuestionIDs {
+			poolOf[qID] = i
+		}
+	}
+
+	questionIDs := make([]string, 0, len(d.Questions))
+	drawn := make(map[int]bool)
+	for _, q := range d.Questions {
+		i, inPool := poolOf[q.ID]
+		if !inPool {
+			questionIDs = append(questionIDs, q.ID)
+			continue
+		}
+		if drawn[i] {
+			continue
+		}
+		drawn[i] = true
+
+		// 只从定义中实际存在的问题里抽取
+		candidates := make([]string, 0, len(d.Pools[i].QuestionIDs))
+		for _, qID := range d.Pools[i].QuestionIDs {
+			if d.QuestionByID(qID) != nil && poolOf[qID] == i {
+				candidates = append(candidates, qID)
+			}
+		}
+		n := d.Pools[i].Draw
+		if n <= 0 || n > len(candidates) {
+			n = len(candidates)
+		}
+		for _, k := range rng.Perm(len(candidates))[:n] {
+			questionIDs = append(questionIDs, candidates[k])
+		}
+	}
+
+	var optionOrder map[string][]string
+	if d.Settings.ShuffleOptions {
+		optionOrder = make(map[string][]string)
+		for _, qID := range questionIDs {
+			q := d.QuestionByID(qID)
+			if len(q.Options) < 2 {
+				continue
+			}
+			order := make([]string, 0, len(q.Options))
+			for _, k := range rng.Perm(len(q.Options)) {
+				order = append(order, q.Options[k].ID)
+			}
+			optionOrder[qID] = order
+		}
+	}
+	return questionIDs, optionOrder
+}
+
+// Subset 返回只包含指定问题（按 questionIDs 的顺序）的定义，用于按答题时实际看到的题目校验和判分
+func (d *Definition) Subset(questionIDs []string) *Definition {
+	subset := &Definition{Settings: d.Settings, Questions: make([]Question, 0, len(questionIDs))}
+	for _, qID := range questionIDs {
+		if q := d.QuestionByID(qID); q != nil {
+			subset.Questions = append(subset.Questions, *q)
+		}
+	}
+	subset.Pages = d.subsetPages(questionIDs)
+	subset.HiddenFields = d.HiddenFields
+	subset.Quotas = d.Quotas
+	return subset
+}
+
+// PublicDefinition 生成返回给填写页的表单定义 JSON：去掉标准答案、判分规则和题库配置；
+// questionIDs 不为 nil 时只保留这些问题并按其顺序排列（页面中的问题同样处理），
+// optionOrder 中的问题按给定顺序排列选项。定义中的其他字段原样保留
+func PublicDefinition(raw []byte, questionIDs []string, optionOrder map[string][]string) ([]byte, error) {
+	var doc map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &doc); err != nil {
+		return nil, err
+	}
+	var questions []map[string]json.RawMessage
+	if err := json.Unmarshal(doc["questions"], &questions); err != nil {
+		return nil, err
+	}
+
+	byID := make(map[string]map[string]json.RawMessage, len(questions))
+	order := make([]string, 0, len(questions))
+	for _, q := range questions {
+		var id string
+		_ = json.Unmarshal(q["id"], &id)
+		delete(q, "correct_answer")
+		delete(q, "scoring")
+		byID[id] = q
+		order = append(order, id)
+	}
+	if questionIDs != nil {
+		order = questionIDs
+	}
+
+	served := make([]map[string]json.RawMessage, 0, len(order))
+	for _, id := range order {
+		q, ok := byID[id]
+		if !ok {
+			continue
+		}
+		if optIDs, ok := optionOrder[id]; ok {
+			shuffled, err := reorderOptions(q["options"], optIDs)
+			if err != nil {
+				return nil, err
+			}
+			q["options"] = shuffled
+		}
+		served = append(served, q)
+	}
+
+	questionsJSON, err := json.Marshal(served)
+	if err != nil {
+		return nil, err
+	}
+	doc["questions"] = questionsJSON
+	delete(doc, "pools")
+
+	if questionIDs != nil && len(doc["pages"]) > 0 {
+		var pages []map[string]json.RawMessage
+		if err := json.Unmarshal(doc["pages"], &pages); err != nil {
+			return nil, err
+		}
+		for _, page := range pages {
+			var pageQuestionIDs []string
+			_ = json.Unmarshal(page["questionIds"], &pageQuestionIDs)
+			filtered, err := json.Marshal(filterOrdered(questionIDs, pageQuestionIDs))
+			if err != nil {
+				return nil, err
+			}
+			page["questionIds"] = filtered
+		}
+		pagesJSON, err := json.Marshal(pages)
+		if err != nil {
+			return nil, err
+		}
+		doc["pages"] = pagesJSON
+	}
+	return json.Marshal(doc)
+}
+
+// reorderOptions 按给定的选项ID顺序重新排列原始的选项 JSON 数组
+func reorderOptions(raw json.RawMessage, optIDs []string) (json.RawMessage, error) {
+	var options []map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &options); err != nil {
+		return nil, err
+	}
+	byID := make(map[string]map[string]json.RawMessage, len(options))
+	for _, opt := range options {
+		var id string
+		_ = json.Unmarshal(opt["id"], &id)
+		byID[id] = opt
+	}
+	reordered := make([]map[string]json.RawMessage, 0, len(options))
+	for _, id := range optIDs {
+		if opt, ok := byID[id]; ok {
+			reordered = append(reordered, opt)
+		}
+	}
+	return json.Marshal(reordered)
+}
@@ -0,0 +1,105 @@
+package question
+
+import (
+	"reflect"
+	"testing"
+)
+
+const poolDefinition = `{
+	"settings": {"shuffleOptions": true},
+	"questions": [
+		{"id": "intro", "type": "text_input"},
+		{"id": "a1", "type": "single_choice", "options": [{"id": "o1"}, {"id": "o2"}, {"id": "o3"}]},
+		{"id": "a2", "type": "single_choice", "options": [{"id": "o1"}, {"id": "o2"}]},
+		{"id": "a3", "type": "single_choice", "options": [{"id": "o1"}, {"id": "o2"}]},
+		{"id": "b1", "type": "text_input"},
+		{"id": "b2", "type": "text_input"},
+		{"id": "outro", "type": "text_input"}
+	],
+	"pools": [
+		{"id": "pa", "draw": 2, "questionIds": ["a1", "a2", "a3", "missing"]},
+		{"id": "pb", "draw": 0, "questionIds": ["b1", "b2"]}
+	],
+	"pages": [
+		{"id": "p1", "questionIds": ["intro", "a1", "a2", "a3"]},
+		{"id": "p2", "questionIds": ["b1", "b2", "outro"]}
+	]
+}`
+
+func TestDraw(t *testing.T) {
+	def := mustParse(t, poolDefinition)
+	inPool := map[string]string{"a1": "pa", "a2": "pa", "a3": "pa", "b1": "pb", "b2": "pb"}
+
+	for seed := int64(1); seed <= 20; seed++ {
+		ids, order := def.Draw(seed)
+		if len(ids) != 6 {
+			t.Fatalf("seed %d: drew %v, want 6 questions", seed, ids)
+		}
+		if ids[0] != "intro" || ids[len(ids)-1] != "outro" {
+			t.Errorf("seed %d: questions outside pools moved: %v", seed, ids)
+		}
+		// 抽到的题目出现在题库第一道题的位置，pa 的两道题在 pb 之前
+		wantPools := []string{"", "pa", "pa", "pb", "pb", ""}
+		seen := make(map[string]bool)
+		for i, id := range ids {
+			if inPool[id] != wantPools[i] {
+				t.Errorf("seed %d: position %d is %s, want a question from pool %q", seed, i, id, wantPools[i])
+			}
+			if seen[id] {
+				t.Errorf("seed %d: %s drawn twice", seed, id)
+			}
+			seen[id] = true
+		}
+		for _, qID := range ids {
+			q := def.QuestionByID(qID)
+			if len(q.Options) < 2 {
+				continue
+			}
+			if len(order[qID]) != len(q.Options) {
+				t.Errorf("seed %d: option order of %s = %v", seed, qID, order[qID])
+			}
+		}
+
+		again, againOrder := def.Draw(seed)
+		if !reflect.DeepEqual(ids, again) || !reflect.DeepEqual(order, againOrder) {
+			t.Errorf("seed %d: Draw is not deterministic", seed)
+		}
+	}
+}
+
+func TestSubset(t *testing.T) {
+	def := mustParse(t, poolDefinition)
+
+	tests := []struct {
+		name      string
+		ids       []string
+		wantIDs   []string
+		wantPages [][]string
+	}{
+		{"keeps the given order", []string{"a3", "intro", "b2"}, []string{"a3", "intro", "b2"}, [][]string{{"a3", "intro"}, {"b2"}}},
+		{"ignores unknown questions", []string{"intro", "missing"}, []string{"intro"}, [][]string{{"intro"}, {}}},
+		{"empty", []string{}, []string{}, [][]string{{}, {}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			subset := def.Subset(tt.ids)
+			got := make([]string, 0, len(subset.Questions))
+			for _, q := range subset.Questions {
+				got = append(got, q.ID)
+			}
+			if !reflect.DeepEqual(got, tt.wantIDs) {
+				t.Errorf("questions = %v, want %v", got, tt.wantIDs)
+			}
+			pages := make([][]string, 0, len(subset.Pages))
+			for _, page := range subset.Pages {
+				pages = append(pages, page.QuestionIDs)
+			}
+			if !reflect.DeepEqual(pages, tt.wantPages) {
+				t.Errorf("pages = %v, want %v", pages, tt.wantPages)
+			}
+			if len(subset.Pools) != 0 {
+				t.Errorf("subset keeps pools %v", subset.Pools)
+			}
+		})
+	}
+}
@@ -0,0 +1,72 @@
+// Package question 定义了表单题型的元数据结构以及可插拔的题型注册表
+package question
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// Quota 是按选项限制提交数的配额：选择了某个问题中指定选项的提交最多接受 limit 份，
+// 例如年龄段选择 "18-25 岁" 的提交最多 200 份。单选、下拉和多选题都可以设置配额
+type Quota struct {
+	ID         string `json:"id"`
+	Title      string `json:"title,omitempty"` // 统计中显示的名称，为空时使用问题和选项的文本
+	QuestionID string `json:"questionId"`
+	OptionID   string `json:"optionId"`
+	Limit      int    `json:"limit"` // 0 表示不限制
+}
+
+// DisplayTitle 返回配额在统计中显示的名称
+func (qt *Quota) DisplayTitle(def *Definition) string {
+	if qt.Title != "" {
+		return qt.Title
+	}
+	q := def.QuestionByID(qt.QuestionID)
+	if q == nil {
+		return qt.ID
+	}
+	if text, ok := q.OptionText(qt.OptionID); ok {
+		return fmt.Sprintf("%s: %s", q.Title, text)
+	}
+	return q.Title
+}
+
+// Matches 判断答案是否计入该配额：单选题的答案等于该选项，或多选题的答案包含该选项
+func (qt *Quota) Matches(answers map[string]json.RawMessage) bool {
+	raw, ok := answers[qt.QuestionID]
+	if !ok {
+		return false
+	}
+	var optID string
+	if err := json.Unmarshal(raw, &optID); err == nil {
+		return optID == qt.OptionID
+	}
+	var optIDs []string
+	if err := json.Unmarshal(raw, &optIDs); err != nil {
+		return false
+	}
+	for _, id := range optIDs {
+		if id == qt.OptionID {
+			return true
+		}
+	}
+	return false
+}
+
+// MatchedQuotas 返回一次提交计入的所有配额，不限制数量（limit 为 0）的配额不返回
+func (d *Definition) MatchedQuotas(data []byte) []Quota {
+	if len(d.Quotas) == 0 {
+		return nil
+	}
+	var answers map[string]json.RawMessage
+	if err := json.Unmarshal(data, &answers); err != nil {
+		return nil
+	}
+	var matched []Quota
+	for _, qt := range d.Quotas {
+		if qt.Limit > 0 && qt.Matches(answers) {
+			matched = append(matched, qt)
+		}
+	}
+	return matched
+}
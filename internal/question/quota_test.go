@@ -0,0 +1,63 @@
+package question
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMatchedQuotas(t *testing.T) {
+	def := mustParse(t, `{
+		"questions": [
+			{"id": "age", "type": "single_choice", "options": [{"id": "young"}, {"id": "old"}]},
+			{"id": "tags", "type": "multi_choice", "options": [{"id": "x"}, {"id": "y"}]}
+		],
+		"quotas": [
+			{"id": "q-young", "questionId": "age", "optionId": "young", "limit": 10},
+			{"id": "q-old", "questionId": "age", "optionId": "old", "limit": 0},
+			{"id": "q-x", "questionId": "tags", "optionId": "x", "limit": 5},
+			{"id": "q-y", "questionId": "tags", "optionId": "y", "limit": 5}
+		]
+	}`)
+
+	tests := []struct {
+		name string
+		data string
+		want []string
+	}{
+		{"single choice", `{"age": "young"}`, []string{"q-young"}},
+		{"unlimited quota is skipped", `{"age": "old"}`, nil},
+		{"multi choice", `{"age": "young", "tags": ["y", "x"]}`, []string{"q-young", "q-x", "q-y"}},
+		{"unanswered", `{"tags": []}`, nil},
+		{"wrong answer type", `{"age": 1, "tags": {"x": true}}`, nil},
+		{"invalid data", `not json`, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, qt := range def.MatchedQuotas([]byte(tt.data)) {
+				got = append(got, qt.ID)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("MatchedQuotas(%s) = %v, want %v", tt.data, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestQuotaDisplayTitle(t *testing.T) {
+	def := mustParse(t, `{"questions": [{"id": "age", "title": "年龄", "type": "single_choice", "options": [{"id": "young", "text": "18-25 岁"}]}]}`)
+	tests := []struct {
+		quota Quota
+		want  string
+	}{
+		{Quota{ID: "a", Title: "青年", QuestionID: "age", OptionID: "young"}, "青年"},
+		{Quota{ID: "a", QuestionID: "age", OptionID: "young"}, "年龄: 18-25 岁"},
+		{Quota{ID: "a", QuestionID: "age", OptionID: "gone"}, "年龄"},
+		{Quota{ID: "a", QuestionID: "gone", OptionID: "young"}, "a"},
+	}
+	for _, tt := range tests {
+		if got := tt.quota.DisplayTitle(def); got != tt.want {
+			t.Errorf("DisplayTitle(%+v) = %q, want %q", tt.quota, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,183 @@
+// Package question 定义了表单题型的元数据结构以及可插拔的题型注册表
+package question
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+func init() {
+	Register(rankingType{})
+}
+
+// RankingOptionStat 是排序题中单个选项的统计结果
+type RankingOptionStat struct {
+	OptionID     string  `json:"option_id"`
+	Text         string  `json:"text"`
+	RankedCount  int     `json:"ranked_count"`  // 把该选项排进名次的提交数
+	AverageRank  float64 `json:"average_rank"`  // 在排进名次的提交中的平均名次，第一名为 1
+	FirstChoices int     `json:"first_choices"` // 排在第一位的次数
+	BordaScore   int     `json:"borda_score"`   // Borda 计分：n 个选项时第 1 名得 n 分，第 n 名得 1 分，未排名得 0 分
+}
+
+// rankingType 是排序题，答案是按名次从高到低排列的选项ID数组。
+// topK 为 0 时要求对所有选项排序，否则只需要选出并排列前 topK 个选项
+type rankingType struct{}
+
+func (rankingType) Name() string { return "ranking" }
+
+func (rankingType) ValidateAnswer(q *Question, raw json.RawMessage) error {
+	var optIDs []string
+	if err := json.Unmarshal(raw, &optIDs); err != nil {
+		return errors.New("answer must be an array of option IDs")
+	}
+	if want := q.rankPositions(); len(optIDs) != want {
+		if want == len(q.Options) {
+			return fmt.Errorf("all %d options must be ranked", want)
+		}
+		return fmt.Errorf("exactly %d options must be ranked", want)
+	}
+	seen := make(map[string]bool, len(optIDs))
+	for _, optID := range optIDs {
+		if !q.HasOption(optID) {
+			return fmt.Errorf("option %q does not exist", optID)
+		}
+		if seen[optID] {
+			return fmt.Errorf("option %q is ranked more than once", optID)
+		}
+		seen[optID] = true
+	}
+	return nil
+}
+
+func (rankingType) NewAggregator(q *Question) Aggregator {
+	return &rankingCollector{q: q, rankSums: make(map[string]int), counts: make(map[string]int), firsts: make(map[string]int), borda: make(map[string]int)}
+}
+
+// FormatAnswer 按名次用 " > " 连接选项文本
+func (rankingType) FormatAnswer(q *Question, raw json.RawMessage) interface{} {
+	var optIDs []string
+	if err := json.Unmarshal(raw, &optIDs); err != nil {
+		return string(raw)
+	}
+	texts := make([]string, 0, len(optIDs))
+	for _, optID := range optIDs {
+		texts = append(texts, q.rankingOptionText(optID))
+	}
+	return strings.Join(texts, " > ")
+}
+
+// ExportColumns 每个名次导出为一列
+func (rankingType) ExportColumns(q *Question) []ExportColumn {
+	positions := q.rankPositions()
+	columns := make([]ExportColumn, positions)
+	for i := range columns {
+		columns[i] = ExportColumn{Key: fmt.Sprint(i + 1), Title: fmt.Sprintf("第%d位", i+1)}
+	}
+	return columns
+}
+
+func (rankingType) FormatColumns(q *Question, raw json.RawMessage) map[string]interface{} {
+	var optIDs []string
+	if err := json.Unmarshal(raw, &optIDs); err != nil {
+		return map[string]interface{}{}
+	}
+	values := make(map[string]interface{}, len(optIDs))
+	for i, optID := range optIDs {
+		values[fmt.Sprint(i+1)] = q.rankingOptionText(optID)
+	}
+	return values
+}
+
+// BuildFilter 支持 equals / not_equals 比较排在第一位的选项，
+// contains / not_contains 判断是否把任意一个给定的选项排进了名次
+func (rankingType) BuildFilter(jsonPath string, cond FilterCondition) (string, []interface{}) {
+	switch cond.Operator {
+	case "contains", "not_contains":
+		return multiChoiceType{}.BuildFilter(jsonPath, cond)
+	}
+	return buildScalarFilter(jsonPath+"[0]", cond)
+}
+
+// PrefillAnswer 把按名次排列、逗号分隔的选项ID转换为答案
+func (rankingType) PrefillAnswer(q *Question, value string) (json.RawMessage, error) {
+	return prefillList(value)
+}
+
+// rankPositions 返回排序题需要排列的名次数
+func (q *Question) rankPositions() int {
+	if q.TopK > 0 && q.TopK < len(q.Options) {
+		return q.TopK
+	}
+	return len(q.Options)
+}
+
+// rankingOptionText 返回选项文本，找不到时回退显示ID
+func (q *Question) rankingOptionText(optID string) string {
+	if text, ok := q.OptionText(optID); ok {
+		return text
+	}
+	return optID
+}
+
+// rankingCollector 累积排序题每个选项的名次、第一名次数和 Borda 分数
+type rankingCollector struct {
+	q        *Question
+	rankSums map[string]int
+	counts   map[string]int
+	firsts   map[string]int
+	borda    map[string]int
+}
+
+func (a *rankingCollector) Add(raw json.RawMessage) {
+	var optIDs []string
+	if err := json.Unmarshal(raw, &optIDs); err != nil {
+		return
+	}
+	n := len(a.q.Options)
+	for i, optID := range optIDs {
+		if !a.q.HasOption(optID) {
+			continue
+		}
+		a.rankSums[optID] += i + 1
+		a.counts[optID]++
+		a.borda[optID] += n - i
+		if i == 0 {
+			a.firsts[optID]++
+		}
+	}
+}
+
+// Merge 累加另一个版本的名次和分数；每个版本的 Borda 分数按该版本的选项总数计算
+func (a *rankingCollector) Merge(other Aggregator) {
+	o, ok := other.(*rankingCollector)
+	if !ok {
+		return
+	}
+	for optID, n := range o.counts {
+		a.rankSums[optID] += o.rankSums[optID]
+		a.counts[optID] += n
+		a.firsts[optID] += o.firsts[optID]
+		a.borda[optID] += o.borda[optID]
+	}
+	a.q = a.q.mergeOptions(o.q)
+}
+
+func (a *rankingCollector) Fill(stat *QuestionStat) {
+	stat.Ranking = make([]RankingOptionStat, 0, len(a.q.Options))
+	for _, opt := range a.q.Options {
+		optStat := RankingOptionStat{
+			OptionID:     opt.ID,
+			Text:         opt.Text,
+			RankedCount:  a.counts[opt.ID],
+			FirstChoices: a.firsts[opt.ID],
+			BordaScore:   a.borda[opt.ID],
+		}
+		if optStat.RankedCount > 0 {
+			optStat.AverageRank = float64(a.rankSums[opt.ID]) / float64(optStat.RankedCount)
+		}
+		stat.Ranking = append(stat.Ranking, optStat)
+	}
+}
@@ -0,0 +1,69 @@
+// Package question 定义了表单题型的元数据结构以及可插拔的题型注册表
+package question
+
+import (
+	"encoding/json"
+	"sync"
+)
+
+// QuestionType 描述了一种题型在后端需要具备的全部能力
+// 新增题型时只需实现该接口并在 init 中调用 Register 即可
+type QuestionType interface {
+	// Name 返回题型标识，对应定义中的 type 字段
+	Name() string
+	// ValidateAnswer 校验一个非空答案是否符合该题型的要求
+	ValidateAnswer(q *Question, raw json.RawMessage) error
+	// NewAggregator 为统计接口创建该问题的聚合器
+	NewAggregator(q *Question) Aggregator
+	// FormatAnswer 将答案转换为写入 Excel 单元格的值
+	FormatAnswer(q *Question, raw json.RawMessage) interface{}
+	// BuildFilter 为筛选条件生成 SQL 片段及其参数，返回空字符串表示忽略该条件
+	BuildFilter(jsonPath string, cond FilterCondition) (string, []interface{})
+}
+
+// ExportColumn 是导出时问题占用的一列，Key 用于把答案的各部分对应到列上
+type ExportColumn struct {
+	Key   string
+	Title string
+}
+
+// MultiColumnExporter 是导出时需要占用多列的题型额外实现的接口，例如矩阵题每一行占一列
+type MultiColumnExporter interface {
+	// ExportColumns 返回问题占用的列，Title 只需描述该列本身，导出时会加上题目标题作为前缀；Title 为空的列只显示题目标题
+	ExportColumns(q *Question) []ExportColumn
+	// FormatColumns 将答案转换为列 Key 到单元格值的映射
+	FormatColumns(q *Question, raw json.RawMessage) map[string]interface{}
+}
+
+// Aggregator 逐条累积某个问题的答案，最终生成该问题的统计结果
+type Aggregator interface {
+	Add(raw json.RawMessage)
+	Fill(stat *QuestionStat)
+	// Merge 在所有 Add 之后合并同一问题在另一个表单版本上的聚合结果，用于跨版本统计；
+	// 合并后 Fill 仍以本聚合器的问题定义为准，只在 other 的版本中出现过的选项追加在末尾。
+	// other 不是同一题型创建的聚合器时忽略
+	Merge(other Aggregator)
+}
+
+var (
+	registryMu sync.RWMutex
+	registry   = make(map[string]QuestionType)
+)
+
+// Register 注册一个题型，重复注册同名题型会 panic
+func Register(t QuestionType) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	if _, exists := registry[t.Name()]; exists {
+		panic("question: type registered twice: " + t.Name())
+	}
+	registry[t.Name()] = t
+}
+
+// Lookup 按名称查找已注册的题型
+func Lookup(name string) (QuestionType, bool) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	t, ok := registry[name]
+	return t, ok
+}
@@ -0,0 +1,284 @@
+// Package question 定义了表单题型的元数据结构以及可插拔的题型注册表
+package question
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+)
+
+// 量表类题型的默认配置
+const (
+	defaultRatingMax  = 5   // 评分题默认 5 星
+	defaultLikertMax  = 5   // 没有配置选项的李克特量表默认使用 5 级
+	defaultSliderMin  = 0   // 滑块题默认最小值
+	defaultSliderMax  = 100 // 滑块题默认最大值
+	defaultSliderStep = 1   // 滑块题默认步长
+)
+
+// NPS 的分组界限：0-6 为贬损者，7-8 为被动者，9-10 为推荐者
+const (
+	npsDetractorMax = 6
+	npsPromoterMin  = 9
+)
+
+func init() {
+	Register(scaleType{name: "rating"})
+	Register(scaleType{name: "nps"})
+	Register(scaleType{name: "slider"})
+	Register(scaleType{name: "likert"})
+}
+
+// ValueCount 是数值分布中的一项
+type ValueCount struct {
+	Value float64 `json:"value"`
+	Label string  `json:"label,omitempty"` // 李克特量表中该分值对应的选项文本
+	Count int     `json:"count"`
+}
+
+// NumericStats 是量表类题型的统计结果
+type NumericStats struct {
+	Count        int          `json:"count"`
+	Mean         float64      `json:"mean"`
+	Median       float64      `json:"median"`
+	Min          float64      `json:"min"`
+	Max          float64      `json:"max"`
+	Distribution []ValueCount `json:"distribution"`
+}
+
+// NPSStats 是净推荐值的统计结果，Score 的取值范围为 -100 到 100
+type NPSStats struct {
+	Promoters  int     `json:"promoters"`
+	Passives   int     `json:"passives"`
+	Detractors int     `json:"detractors"`
+	Score      float64 `json:"score"`
+}
+
+// scaleType 是答案为一个数字的量表类题型：
+//   - rating: 1 到 max（默认 5）的整数星级
+//   - nps: 0 到 10 的整数
+//   - slider: min 到 max 之间、按 step 取值的数字
+//   - likert: 1 到选项个数的整数，options 按从低到高的顺序给出每个分值的文本；没有选项时为 1 到 5 的整数
+type scaleType struct {
+	name string
+}
+
+func (t scaleType) Name() string { return t.name }
+
+// bounds 返回问题允许的取值范围和步长
+func (t scaleType) bounds(q *Question) (lo, hi, step float64) {
+	switch t.name {
+	case "rating":
+		return 1, floatOr(q.Max, defaultRatingMax), 1
+	case "nps":
+		return 0, 10, 1
+	case "likert":
+		return 1, float64(likertMax(q)), 1
+	default:
+		step = q.Step
+		if step <= 0 {
+			step = defaultSliderStep
+		}
+		return floatOr(q.Min, defaultSliderMin), floatOr(q.Max, defaultSliderMax), step
+	}
+}
+
+func (t scaleType) ValidateAnswer(q *Question, raw json.RawMessage) error {
+	var value float64
+	if err := json.Unmarshal(raw, &value); err != nil {
+		return errors.New("answer must be a number")
+	}
+	lo, hi, step := t.bounds(q)
+	if value < lo || value > hi {
+		return fmt.Errorf("answer must be between %s and %s", formatNumber(lo), formatNumber(hi))
+	}
+	// 按步长取值，允许浮点误差
+	steps := (value - lo) / step
+	if math.Abs(steps-math.Round(steps)) > 1e-9 {
+		return fmt.Errorf("answer must be a multiple of %s from %s", formatNumber(step), formatNumber(lo))
+	}
+	return nil
+}
+
+func (t scaleType) NewAggregator(q *Question) Aggregator {
+	return &numericCollector{q: q, nps: t.name == "nps", likert: t.name == "likert"}
+}
+
+// FormatAnswer 返回数字，写入 Excel 时是数值单元格
+func (t scaleType) FormatAnswer(q *Question, raw json.RawMessage) interface{} {
+	var value float64
+	if err := json.Unmarshal(raw, &value); err != nil {
+		return string(raw)
+	}
+	return value
+}
+
+func (t scaleType) BuildFilter(jsonPath string, cond FilterCondition) (string, []interface{}) {
+	return buildNumericFilter(jsonPath, cond)
+}
+
+func (t scaleType) PrefillAnswer(q *Question, value string) (json.RawMessage, error) {
+	return prefillNumber(value)
+}
+
+// numericCollector 收集量表类题型的数值答案
+type numericCollector struct {
+	q      *Question
+	nps    bool
+	likert bool
+	values []float64
+}
+
+func (a *numericCollector) Add(raw json.RawMessage) {
+	var value float64
+	if err := json.Unmarshal(raw, &value); err == nil {
+		a.values = append(a.values, value)
+	}
+}
+
+// Merge 合并另一个版本的数值答案；李克特量表的选项按位置对应分值，
+// 旧版本的选项更多时把多出的分值文本追加在末尾
+func (a *numericCollector) Merge(other Aggregator) {
+	o, ok := other.(*numericCollector)
+	if !ok {
+		return
+	}
+	a.values = append(a.values, o.values...)
+	if a.likert && len(o.q.Options) > len(a.q.Options) {
+		q := *a.q
+		q.Options = append(append([]Option(nil), a.q.Options...), o.q.Options[len(a.q.Options):]...)
+		a.q = &q
+	}
+}
+
+func (a *numericCollector) Fill(stat *QuestionStat) {
+	stats := &NumericStats{Count: len(a.values), Distribution: make([]ValueCount, 0)}
+	stat.NumericStats = stats
+	if a.likert {
+		// 李克特量表的每个分值都出现在分布中，即使没有人选择
+		for i := 0; i < likertMax(a.q); i++ {
+			vc := ValueCount{Value: float64(i + 1)}
+			if i < len(a.q.Options) {
+				vc.Label = a.q.Options[i].Text
+			}
+			stats.Distribution = append(stats.Distribution, vc)
+		}
+	}
+	if a.nps {
+		stat.NPS = &NPSStats{}
+	}
+	if len(a.values) == 0 {
+		return
+	}
+
+	sorted := append([]float64(nil), a.values...)
+	sort.Float64s(sorted)
+	sum := 0.0
+	for _, v := range sorted {
+		sum += v
+	}
+	stats.Mean = sum / float64(len(sorted))
+	stats.Min = sorted[0]
+	stats.Max = sorted[len(sorted)-1]
+	mid := len(sorted) / 2
+	if len(sorted)%2 == 1 {
+		stats.Median = sorted[mid]
+	} else {
+		stats.Median = (sorted[mid-1] + sorted[mid]) / 2
+	}
+
+	index := make(map[float64]int, len(stats.Distribution))
+	for i, vc := range stats.Distribution {
+		index[vc.Value] = i
+	}
+	for _, v := range sorted {
+		i, ok := index[v]
+		if !ok {
+			i = len(stats.Distribution)
+			index[v] = i
+			stats.Distribution = append(stats.Distribution, ValueCount{Value: v})
+		}
+		stats.Distribution[i].Count++
+	}
+
+	if a.nps {
+		for _, v := range sorted {
+			switch {
+			case v <= npsDetractorMax:
+				stat.NPS.Detractors++
+			case v >= npsPromoterMin:
+				stat.NPS.Promoters++
+			default:
+				stat.NPS.Passives++
+			}
+		}
+		stat.NPS.Score = float64(stat.NPS.Promoters-stat.NPS.Detractors) * 100 / float64(len(sorted))
+	}
+}
+
+// buildNumericFilter 为答案是数字的题型生成数值比较条件，值无法解析为数字时忽略该条件
+func buildNumericFilter(jsonPath string, cond FilterCondition) (string, []interface{}) {
+	if len(cond.Value) == 0 {
+		return "", nil
+	}
+	values := make([]interface{}, len(cond.Value))
+	for i, v := range cond.Value {
+		value, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return "", nil
+		}
+		values[i] = value
+	}
+	column := fmt.Sprintf("CAST(data->'%s' AS DECIMAL(20,6))", jsonPath)
+	return buildComparison(jsonPath, column, cond.Operator, values)
+}
+
+// buildComparison 生成 column 与 values 的比较条件，values 至少包含一个值；
+// between 需要两个值，表示闭区间 [values[0], values[1]]
+func buildComparison(jsonPath, column, operator string, values []interface{}) (string, []interface{}) {
+	value := values[0]
+	switch operator {
+	case "equals":
+		return column + " = ?", []interface{}{value}
+	case "not_equals":
+		return fmt.Sprintf("(data->'%s' IS NULL OR %s != ?)", jsonPath, column), []interface{}{value}
+	case "gt":
+		return column + " > ?", []interface{}{value}
+	case "gte":
+		return column + " >= ?", []interface{}{value}
+	case "lt":
+		return column + " < ?", []interface{}{value}
+	case "lte":
+		return column + " <= ?", []interface{}{value}
+	case "between":
+		if len(values) < 2 {
+			return "", nil
+		}
+		return column + " BETWEEN ? AND ?", []interface{}{value, values[1]}
+	}
+	return "", nil
+}
+
+// likertMax 返回李克特量表的最高分值，即选项个数，没有配置选项时使用默认的 5 级量表
+func likertMax(q *Question) int {
+	if len(q.Options) == 0 {
+		return defaultLikertMax
+	}
+	return len(q.Options)
+}
+
+// floatOr 返回 *v，v 为 nil 时返回默认值
+func floatOr(v *float64, def float64) float64 {
+	if v == nil {
+		return def
+	}
+	return *v
+}
+
+// formatNumber 把数字格式化为不带多余小数位的字符串
+func formatNumber(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
@@ -0,0 +1,27 @@
+// Package question 定义了表单题型的元数据结构以及可插拔的题型注册表
+package question
+
+// OptionStat 存储单个选项的统计
+type OptionStat struct {
+	Text  string `json:"text"`
+	Count int    `json:"count"`
+}
+
+// QuestionStat 存储单个问题的统计结果
+type QuestionStat struct {
+	QuestionID    string          `json:"question_id"`
+	QuestionType  string          `json:"question_type"`
+	Title         string          `json:"title"`
+	PageID        string          `json:"page_id,omitempty"`       // 分页表单中问题所在的页面
+	OptionStats   []OptionStat    `json:"option_stats,omitempty"`  // 用于选择题
+	TextAnswers   []string        `json:"text_answers,omitempty"`  // 用于填空题
+	NumericStats  *NumericStats   `json:"numeric_stats,omitempty"` // 用于量表类题型
+	NPS           *NPSStats       `json:"nps,omitempty"`           // 仅用于 NPS 题
+	Matrix        []MatrixRowStat `json:"matrix,omitempty"`        // 用于矩阵题：行×列的选择次数
+	FileCount     int             `json:"file_count,omitempty"`    // 用于文件上传题：上传的文件总数
+	ServedCount   *int            `json:"served_count,omitempty"`  // 随机抽题时抽到该题的提交数
+	ShownCount    int             `json:"shown_count"`             // 实际看到该题的提交数（排除未抽到和被条件逻辑隐藏的情况），作为统计的分母
+	AnsweredCount int             `json:"answered_count"`          // 作答了该题的提交数
+
+	Ranking []RankingOptionStat `json:"ranking,omitempty"` // 用于排序题：每个选项的平均名次、第一名次数和 Borda 分数
+}
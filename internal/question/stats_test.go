@@ -0,0 +1,177 @@
+package question
+
+import (
+	"encoding/json"
+	"math"
+	"reflect"
+	"testing"
+)
+
+// aggregate 把答案依次交给题型的聚合器，返回统计结果
+func aggregate(t *testing.T, q *Question, answers ...string) *QuestionStat {
+	t.Helper()
+	qt, ok := Lookup(q.Type)
+	if !ok {
+		t.Fatalf("question type %q is not registered", q.Type)
+	}
+	agg := qt.NewAggregator(q)
+	for _, raw := range answers {
+		agg.Add(json.RawMessage(raw))
+	}
+	stat := &QuestionStat{}
+	agg.Fill(stat)
+	return stat
+}
+
+func TestScaleStatistics(t *testing.T) {
+	tests := []struct {
+		name     string
+		question string
+		answers  []string
+		want     NumericStats
+		nps      *NPSStats
+	}{
+		{
+			name:     "rating",
+			question: `{"id": "q", "type": "rating"}`,
+			answers:  []string{"5", "3", "4", "4", `"bad"`},
+			want: NumericStats{Count: 4, Mean: 4, Median: 4, Min: 3, Max: 5,
+				Distribution: []ValueCount{{Value: 3, Count: 1}, {Value: 4, Count: 2}, {Value: 5, Count: 1}}},
+		},
+		{
+			name:     "likert lists every option",
+			question: `{"id": "q", "type": "likert", "options": [{"id": "1", "text": "差"}, {"id": "2", "text": "中"}, {"id": "3", "text": "好"}]}`,
+			answers:  []string{"3", "1"},
+			want: NumericStats{Count: 2, Mean: 2, Median: 2, Min: 1, Max: 3,
+				Distribution: []ValueCount{{Value: 1, Label: "差", Count: 1}, {Value: 2, Label: "中"}, {Value: 3, Label: "好", Count: 1}}},
+		},
+		{
+			name:     "likert without options uses the default scale",
+			question: `{"id": "q", "type": "likert"}`,
+			answers:  []string{"5", "2"},
+			want: NumericStats{Count: 2, Mean: 3.5, Median: 3.5, Min: 2, Max: 5,
+				Distribution: []ValueCount{{Value: 1}, {Value: 2, Count: 1}, {Value: 3}, {Value: 4}, {Value: 5, Count: 1}}},
+		},
+		{
+			name:     "nps",
+			question: `{"id": "q", "type": "nps"}`,
+			answers:  []string{"10", "9", "8", "6", "0"},
+			want: NumericStats{Count: 5, Mean: 6.6, Median: 8, Min: 0, Max: 10,
+				Distribution: []ValueCount{{Value: 0, Count: 1}, {Value: 6, Count: 1}, {Value: 8, Count: 1}, {Value: 9, Count: 1}, {Value: 10, Count: 1}}},
+			nps: &NPSStats{Promoters: 2, Passives: 1, Detractors: 2, Score: 0},
+		},
+		{
+			name:     "no answers",
+			question: `{"id": "q", "type": "nps"}`,
+			want:     NumericStats{Distribution: []ValueCount{}},
+			nps:      &NPSStats{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var q Question
+			if err := json.Unmarshal([]byte(tt.question), &q); err != nil {
+				t.Fatal(err)
+			}
+			stat := aggregate(t, &q, tt.answers...)
+			got := *stat.NumericStats
+			if math.Abs(got.Mean-tt.want.Mean) < 1e-9 {
+				got.Mean = tt.want.Mean
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("NumericStats = %+v, want %+v", got, tt.want)
+			}
+			if !reflect.DeepEqual(stat.NPS, tt.nps) {
+				t.Errorf("NPS = %+v, want %+v", stat.NPS, tt.nps)
+			}
+		})
+	}
+}
+
+// 没有配置选项的李克特量表按默认的 5 级量表校验答案
+func TestLikertWithoutOptions(t *testing.T) {
+	qt, _ := Lookup("likert")
+	q := &Question{ID: "q", Type: "likert"}
+	for _, answer := range []string{"1", "3", "5"} {
+		if err := qt.ValidateAnswer(q, json.RawMessage(answer)); err != nil {
+			t.Errorf("answer %s: %v", answer, err)
+		}
+	}
+	for _, answer := range []string{"0", "6", "2.5"} {
+		if err := qt.ValidateAnswer(q, json.RawMessage(answer)); err == nil {
+			t.Errorf("answer %s was accepted", answer)
+		}
+	}
+}
+
+func TestRankingStatistics(t *testing.T) {
+	var q Question
+	if err := json.Unmarshal([]byte(`{"id": "q", "type": "ranking", "topK": 2,
+		"options": [{"id": "a", "text": "A"}, {"id": "b", "text": "B"}, {"id": "c", "text": "C"}]}`), &q); err != nil {
+		t.Fatal(err)
+	}
+	stat := aggregate(t, &q, `["a","b"]`, `["b","a"]`, `["a","c"]`, `["x","c"]`, `"invalid"`)
+
+	// Borda 分数按选项总数计算：第一名 3 分、第二名 2 分；未知选项不计分，但仍占用它所在的名次
+	want := []RankingOptionStat{
+		{OptionID: "a", Text: "A", RankedCount: 3, FirstChoices: 2, BordaScore: 8, AverageRank: 4.0 / 3},
+		{OptionID: "b", Text: "B", RankedCount: 2, FirstChoices: 1, BordaScore: 5, AverageRank: 1.5},
+		{OptionID: "c", Text: "C", RankedCount: 2, FirstChoices: 0, BordaScore: 4, AverageRank: 2},
+	}
+	if !reflect.DeepEqual(stat.Ranking, want) {
+		t.Errorf("Ranking = %+v, want %+v", stat.Ranking, want)
+	}
+}
+
+// 跨版本统计时旧版本的聚合结果合并到当前版本上，只在旧版本中出现的选项和分值追加在末尾
+func TestMergeAggregators(t *testing.T) {
+	parse := func(raw string) *Question {
+		var q Question
+		if err := json.Unmarshal([]byte(raw), &q); err != nil {
+			t.Fatal(err)
+		}
+		return &q
+	}
+	merge := func(current, old *Question, currentAnswers, oldAnswers []string) *QuestionStat {
+		qt, _ := Lookup(current.Type)
+		merged := qt.NewAggregator(current)
+		for _, answers := range []struct {
+			q   *Question
+			raw []string
+		}{{current, currentAnswers}, {old, oldAnswers}} {
+			agg := qt.NewAggregator(answers.q)
+			for _, raw := range answers.raw {
+				agg.Add(json.RawMessage(raw))
+			}
+			merged.Merge(agg)
+		}
+		stat := &QuestionStat{}
+		merged.Fill(stat)
+		return stat
+	}
+
+	t.Run("ranking", func(t *testing.T) {
+		current := parse(`{"id": "q", "type": "ranking", "options": [{"id": "a", "text": "A"}, {"id": "b", "text": "B"}]}`)
+		old := parse(`{"id": "q", "type": "ranking", "options": [{"id": "a", "text": "A"}, {"id": "c", "text": "C"}, {"id": "b", "text": "B"}]}`)
+		stat := merge(current, old, []string{`["b","a"]`}, []string{`["c","a","b"]`})
+		// 每个版本的 Borda 分数按该版本的选项总数计算
+		want := []RankingOptionStat{
+			{OptionID: "a", Text: "A", RankedCount: 2, FirstChoices: 0, BordaScore: 3, AverageRank: 2},
+			{OptionID: "b", Text: "B", RankedCount: 2, FirstChoices: 1, BordaScore: 3, AverageRank: 2},
+			{OptionID: "c", Text: "C", RankedCount: 1, FirstChoices: 1, BordaScore: 3, AverageRank: 1},
+		}
+		if !reflect.DeepEqual(stat.Ranking, want) {
+			t.Errorf("Ranking = %+v, want %+v", stat.Ranking, want)
+		}
+	})
+
+	t.Run("likert", func(t *testing.T) {
+		current := parse(`{"id": "q", "type": "likert", "options": [{"id": "1", "text": "Low"}, {"id": "2", "text": "High"}]}`)
+		old := parse(`{"id": "q", "type": "likert", "options": [{"id": "1", "text": "Low"}, {"id": "2", "text": "Mid"}, {"id": "3", "text": "High"}]}`)
+		stat := merge(current, old, []string{`2`}, []string{`3`, `1`})
+		want := []ValueCount{{Value: 1, Label: "Low", Count: 1}, {Value: 2, Label: "High", Count: 1}, {Value: 3, Label: "High", Count: 1}}
+		if stat.NumericStats.Count != 3 || !reflect.DeepEqual(stat.NumericStats.Distribution, want) {
+			t.Errorf("NumericStats = %+v, want distribution %+v", stat.NumericStats, want)
+		}
+	})
+}
@@ -0,0 +1,91 @@
+// Package question 定义了表单题型的元数据结构以及可插拔的题型注册表
+package question
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// defaultTextMaxLength 是填空题未配置 maxLength 时的默认长度上限（按字符计）
+const defaultTextMaxLength = 2000
+
+func init() {
+	Register(textInputType{})
+}
+
+// textInputType 是填空题，答案是一个字符串
+type textInputType struct{}
+
+func (textInputType) Name() string { return "text_input" }
+
+func (textInputType) ValidateAnswer(q *Question, raw json.RawMessage) error {
+	var text string
+	if err := json.Unmarshal(raw, &text); err != nil {
+		return errors.New("answer must be a string")
+	}
+	maxLength := q.MaxLength
+	if maxLength <= 0 {
+		maxLength = defaultTextMaxLength
+	}
+	length := utf8.RuneCountInString(text)
+	if length > maxLength {
+		return fmt.Errorf("answer must be at most %d characters", maxLength)
+	}
+	if q.MinLength > 0 && length < q.MinLength {
+		return fmt.Errorf("answer must be at least %d characters", q.MinLength)
+	}
+	return q.matchPattern(text)
+}
+
+func (textInputType) NewAggregator(q *Question) Aggregator {
+	return &textCollector{}
+}
+
+func (textInputType) FormatAnswer(q *Question, raw json.RawMessage) interface{} {
+	var text string
+	if err := json.Unmarshal(raw, &text); err != nil {
+		return string(raw)
+	}
+	return text
+}
+
+// Grade 忽略首尾空白和大小写，与标准答案比较
+func (textInputType) Grade(q *Question, raw json.RawMessage) int {
+	var text, correct string
+	if json.Unmarshal(raw, &text) != nil || json.Unmarshal(q.CorrectAnswer, &correct) != nil {
+		return 0
+	}
+	if strings.EqualFold(strings.TrimSpace(text), strings.TrimSpace(correct)) {
+		return q.Score
+	}
+	return q.applyPenalty(0, 1)
+}
+
+func (textInputType) BuildFilter(jsonPath string, cond FilterCondition) (string, []interface{}) {
+	return buildScalarFilter(jsonPath, cond)
+}
+
+// textCollector 收集填空题的所有文本答案
+type textCollector struct {
+	answers []string
+}
+
+func (a *textCollector) Add(raw json.RawMessage) {
+	var text string
+	if err := json.Unmarshal(raw, &text); err == nil {
+		a.answers = append(a.answers, text)
+	}
+}
+
+func (a *textCollector) Merge(other Aggregator) {
+	if o, ok := other.(*textCollector); ok {
+		a.answers = append(a.answers, o.answers...)
+	}
+}
+
+func (a *textCollector) Fill(stat *QuestionStat) {
+	stat.TextAnswers = a.answers
+}
@@ -0,0 +1,300 @@
+// Package question 定义了表单题型的元数据结构以及可插拔的题型注册表
+package question
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/mail"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// 日期题和时间题答案的格式
+const (
+	DateLayout        = "2006-01-02"
+	TimeLayout        = "15:04"
+	TimeLayoutSeconds = "15:04:05"
+)
+
+// 邮箱和电话的长度限制：邮箱地址最长 254 个字符，E.164 电话号码最多 15 位数字
+const (
+	maxEmailLength  = 254
+	minPhoneDigits  = 6
+	maxPhoneDigits  = 15
+	phoneCharacters = "+0123456789 -().（）"
+)
+
+func init() {
+	Register(numberType{})
+	Register(dateType{})
+	Register(timeType{})
+	Register(formattedTextType{name: "email", check: checkEmail})
+	Register(formattedTextType{name: "phone", check: checkPhone})
+}
+
+// TimeOfDay 是时间题导出时的单元格值：自零点起的时长，导出服务据此写入时间单元格
+type TimeOfDay time.Duration
+
+// --- 数字题：答案是一个数字，可以用 min / max 限制范围，用 step 限制精度 ---
+
+type numberType struct{}
+
+func (numberType) Name() string { return "number" }
+
+func (numberType) ValidateAnswer(q *Question, raw json.RawMessage) error {
+	var value float64
+	if err := json.Unmarshal(raw, &value); err != nil {
+		return errors.New("answer must be a number")
+	}
+	if q.Min != nil && value < *q.Min {
+		return fmt.Errorf("answer must be at least %s", formatNumber(*q.Min))
+	}
+	if q.Max != nil && value > *q.Max {
+		return fmt.Errorf("answer must be at most %s", formatNumber(*q.Max))
+	}
+	// 配置了步长时按步长取值，起点为 min（未配置时为 0），允许浮点误差
+	if q.Step > 0 {
+		base := floatOr(q.Min, 0)
+		steps := (value - base) / q.Step
+		if math.Abs(steps-math.Round(steps)) > 1e-9 {
+			return fmt.Errorf("answer must be a multiple of %s from %s", formatNumber(q.Step), formatNumber(base))
+		}
+	}
+	return nil
+}
+
+func (numberType) NewAggregator(q *Question) Aggregator {
+	return &numericCollector{q: q}
+}
+
+// FormatAnswer 返回数字，写入 Excel 时是数值单元格
+func (numberType) FormatAnswer(q *Question, raw json.RawMessage) interface{} {
+	var value float64
+	if err := json.Unmarshal(raw, &value); err != nil {
+		return string(raw)
+	}
+	return value
+}
+
+func (numberType) BuildFilter(jsonPath string, cond FilterCondition) (string, []interface{}) {
+	return buildNumericFilter(jsonPath, cond)
+}
+
+func (numberType) PrefillAnswer(q *Question, value string) (json.RawMessage, error) {
+	return prefillNumber(value)
+}
+
+// --- 日期题：答案是 "2006-01-02" 格式的字符串，可以用 minDate / maxDate 限制范围 ---
+
+type dateType struct{}
+
+func (dateType) Name() string { return "date" }
+
+func (dateType) ValidateAnswer(q *Question, raw json.RawMessage) error {
+	var text string
+	if err := json.Unmarshal(raw, &text); err != nil {
+		return errors.New("answer must be a string")
+	}
+	value, err := time.Parse(DateLayout, text)
+	if err != nil {
+		return errors.New("answer must be a date in YYYY-MM-DD format")
+	}
+	if minDate, err := time.Parse(DateLayout, q.MinDate); err == nil && value.Before(minDate) {
+		return fmt.Errorf("answer must be on or after %s", q.MinDate)
+	}
+	if maxDate, err := time.Parse(DateLayout, q.MaxDate); err == nil && value.After(maxDate) {
+		return fmt.Errorf("answer must be on or before %s", q.MaxDate)
+	}
+	return nil
+}
+
+func (dateType) NewAggregator(q *Question) Aggregator {
+	return &textCollector{}
+}
+
+// FormatAnswer 返回 time.Time，写入 Excel 时是日期单元格
+func (dateType) FormatAnswer(q *Question, raw json.RawMessage) interface{} {
+	var text string
+	if err := json.Unmarshal(raw, &text); err != nil {
+		return string(raw)
+	}
+	value, err := time.Parse(DateLayout, text)
+	if err != nil {
+		return text
+	}
+	return value
+}
+
+func (dateType) BuildFilter(jsonPath string, cond FilterCondition) (string, []interface{}) {
+	return buildTemporalFilter(jsonPath, "DATE", cond, func(s string) bool {
+		_, err := time.Parse(DateLayout, s)
+		return err == nil
+	})
+}
+
+// --- 时间题：答案是 "15:04" 或 "15:04:05" 格式的字符串，可以用 minTime / maxTime 限制范围 ---
+
+type timeType struct{}
+
+func (timeType) Name() string { return "time" }
+
+func (timeType) ValidateAnswer(q *Question, raw json.RawMessage) error {
+	var text string
+	if err := json.Unmarshal(raw, &text); err != nil {
+		return errors.New("answer must be a string")
+	}
+	value, ok := parseTimeOfDay(text)
+	if !ok {
+		return errors.New("answer must be a time in HH:MM or HH:MM:SS format")
+	}
+	if minTime, ok := parseTimeOfDay(q.MinTime); ok && value < minTime {
+		return fmt.Errorf("answer must be at or after %s", q.MinTime)
+	}
+	if maxTime, ok := parseTimeOfDay(q.MaxTime); ok && value > maxTime {
+		return fmt.Errorf("answer must be at or before %s", q.MaxTime)
+	}
+	return nil
+}
+
+func (timeType) NewAggregator(q *Question) Aggregator {
+	return &textCollector{}
+}
+
+// FormatAnswer 返回 TimeOfDay，写入 Excel 时是时间单元格
+func (timeType) FormatAnswer(q *Question, raw json.RawMessage) interface{} {
+	var text string
+	if err := json.Unmarshal(raw, &text); err != nil {
+		return string(raw)
+	}
+	value, ok := parseTimeOfDay(text)
+	if !ok {
+		return text
+	}
+	return value
+}
+
+func (timeType) BuildFilter(jsonPath string, cond FilterCondition) (string, []interface{}) {
+	return buildTemporalFilter(jsonPath, "TIME", cond, func(s string) bool {
+		_, ok := parseTimeOfDay(s)
+		return ok
+	})
+}
+
+// parseTimeOfDay 解析 "15:04" 或 "15:04:05" 格式的时间
+func parseTimeOfDay(text string) (TimeOfDay, bool) {
+	for _, layout := range []string{TimeLayout, TimeLayoutSeconds} {
+		if t, err := time.Parse(layout, text); err == nil {
+			return TimeOfDay(time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute + time.Duration(t.Second())*time.Second), true
+		}
+	}
+	return 0, false
+}
+
+// --- 邮箱题 / 电话题：答案是一个字符串，先检查格式，再按 pattern 和长度限制校验 ---
+
+type formattedTextType struct {
+	name  string
+	check func(text string) error
+}
+
+func (t formattedTextType) Name() string { return t.name }
+
+func (t formattedTextType) ValidateAnswer(q *Question, raw json.RawMessage) error {
+	var text string
+	if err := json.Unmarshal(raw, &text); err != nil {
+		return errors.New("answer must be a string")
+	}
+	if err := t.check(text); err != nil {
+		return err
+	}
+	return q.matchPattern(text)
+}
+
+func (t formattedTextType) NewAggregator(q *Question) Aggregator {
+	return &textCollector{}
+}
+
+func (t formattedTextType) FormatAnswer(q *Question, raw json.RawMessage) interface{} {
+	var text string
+	if err := json.Unmarshal(raw, &text); err != nil {
+		return string(raw)
+	}
+	return text
+}
+
+func (t formattedTextType) BuildFilter(jsonPath string, cond FilterCondition) (string, []interface{}) {
+	return buildScalarFilter(jsonPath, cond)
+}
+
+// checkEmail 要求答案是不带显示名的单个邮箱地址，例如 user@example.com
+func checkEmail(text string) error {
+	if utf8.RuneCountInString(text) > maxEmailLength {
+		return fmt.Errorf("answer must be at most %d characters", maxEmailLength)
+	}
+	addr, err := mail.ParseAddress(text)
+	if err != nil || addr.Address != text || !strings.Contains(text[strings.LastIndex(text, "@")+1:], ".") {
+		return errors.New("answer must be a valid email address")
+	}
+	return nil
+}
+
+// checkPhone 要求答案只包含数字、开头的 "+" 以及空格、"-"、括号等分隔符，数字位数在 6 到 15 之间
+func checkPhone(text string) error {
+	digits := 0
+	for i, r := range text {
+		if !strings.ContainsRune(phoneCharacters, r) || (r == '+' && i != 0) {
+			return errors.New("answer must be a valid phone number")
+		}
+		if r >= '0' && r <= '9' {
+			digits++
+		}
+	}
+	if digits < minPhoneDigits || digits > maxPhoneDigits {
+		return fmt.Errorf("phone number must have %d to %d digits", minPhoneDigits, maxPhoneDigits)
+	}
+	return nil
+}
+
+// patternCache 缓存编译后的 pattern，同一个表单的每次提交都会用到相同的正则表达式
+var patternCache sync.Map // map[string]*regexp.Regexp
+
+// matchPattern 检查答案是否完整匹配问题配置的 pattern，未配置时总是通过
+func (q *Question) matchPattern(text string) error {
+	if q.Pattern == "" {
+		return nil
+	}
+	re, ok := patternCache.Load(q.Pattern)
+	if !ok {
+		compiled, err := regexp.Compile(`^(?:` + q.Pattern + `)$`)
+		if err != nil {
+			return errors.New("question has an invalid pattern")
+		}
+		re, _ = patternCache.LoadOrStore(q.Pattern, compiled)
+	}
+	if !re.(*regexp.Regexp).MatchString(text) {
+		return errors.New("answer does not match the required format")
+	}
+	return nil
+}
+
+// buildTemporalFilter 为日期题和时间题生成比较条件，sqlType 是 CAST 的目标类型（DATE 或 TIME），
+// 任意一个值不符合格式时忽略该条件
+func buildTemporalFilter(jsonPath, sqlType string, cond FilterCondition, valid func(string) bool) (string, []interface{}) {
+	if len(cond.Value) == 0 {
+		return "", nil
+	}
+	values := make([]interface{}, len(cond.Value))
+	for i, v := range cond.Value {
+		if !valid(v) {
+			return "", nil
+		}
+		values[i] = v
+	}
+	column := fmt.Sprintf("CAST(JSON_UNQUOTE(data->'%s') AS %s)", jsonPath, sqlType)
+	return buildComparison(jsonPath, column, cond.Operator, values)
+}
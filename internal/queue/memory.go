@@ -0,0 +1,208 @@
+// Package queue 定义了提交消息队列的抽象，以及 Redis Streams、进程内和 MySQL outbox 三种实现
+package queue
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// memoryQueue 是进程内的队列实现，适用于测试和 API 与 consumer 同进程运行的小型部署
+// 消息只保存在内存中，进程退出后未处理的消息会丢失
+type memoryQueue struct {
+	mu          sync.Mutex
+	seq         uint64
+	ready       []string                // 尚未投递的新消息ID，按发布顺序排列
+	entries     map[string]*memoryEntry // 所有未确认的消息
+	deadLetters []DeadLetter            // 按写入顺序排列
+	notify      chan struct{}           // 有新消息发布时发出通知，唤醒阻塞中的 Read
+}
+
+// memoryEntry 记录单条消息的投递状态
+type memoryEntry struct {
+	payload     []byte
+	consumer    string
+	deliveries  int64
+	deliveredAt time.Time
+}
+
+// NewMemoryQueue 创建一个进程内队列
+func NewMemoryQueue() SubmissionQueue {
+	return &memoryQueue{
+		entries: make(map[string]*memoryEntry),
+		notify:  make(chan struct{}, 1),
+	}
+}
+
+// Setup 进程内队列无需初始化
+func (q *memoryQueue) Setup(ctx context.Context) error {
+	return nil
+}
+
+// Publish 将消息加入待投递列表
+func (q *memoryQueue) Publish(ctx context.Context, payload []byte) (string, error) {
+	q.mu.Lock()
+	id := q.nextID()
+	q.entries[id] = &memoryEntry{payload: append([]byte(nil), payload...)}
+	q.ready = append(q.ready, id)
+	q.mu.Unlock()
+
+	select {
+	case q.notify <- struct{}{}:
+	default:
+	}
+	return id, nil
+}
+
+// Read 取出最多 count 条新消息，没有新消息时等待通知、超时或 ctx 取消
+func (q *memoryQueue) Read(ctx context.Context, consumer string, count int, block time.Duration) ([]Message, error) {
+	timer := time.NewTimer(block)
+	defer timer.Stop()
+
+	for {
+		q.mu.Lock()
+		if len(q.ready) > 0 {
+			n := min(count, len(q.ready))
+			messages := make([]Message, 0, n)
+			for _, id := range q.ready[:n] {
+				messages = append(messages, q.deliver(id, consumer))
+			}
+			q.ready = q.ready[n:]
+			q.mu.Unlock()
+			return messages, nil
+		}
+		q.mu.Unlock()
+
+		select {
+		case <-q.notify:
+		case <-timer.C:
+			return nil, nil
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// Claim 认领空闲超过 minIdle 的已投递未确认消息
+func (q *memoryQueue) Claim(ctx context.Context, consumer, owner string, minIdle time.Duration, count int) ([]Message, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	var messages []Message
+	for id, e := range q.entries {
+		if len(messages) >= count {
+			break
+		}
+		if e.deliveries == 0 || time.Since(e.deliveredAt) < minIdle {
+			continue
+		}
+		if owner != "" && e.consumer != owner {
+			continue
+		}
+		messages = append(messages, q.deliver(id, consumer))
+	}
+	return messages, nil
+}
+
+// Ack 删除已确认的消息
+func (q *memoryQueue) Ack(ctx context.Context, ids ...string) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for _, id := range ids {
+		delete(q.entries, id)
+	}
+	return nil
+}
+
+// DeadLetter 将消息移入死信列表
+func (q *memoryQueue) DeadLetter(ctx context.Context, dl *DeadLetter) (string, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	record := *dl
+	record.ID = q.nextID()
+	q.deadLetters = append(q.deadLetters, record)
+	delete(q.entries, dl.OriginalID)
+	return record.ID, nil
+}
+
+// ListDeadLetters 按时间倒序分页返回表单下的死信，游标是上一页最后一条死信的ID
+func (q *memoryQueue) ListDeadLetters(ctx context.Context, formID uint, cursor string, count int) (*DeadLetterPage, error) {
+	before := uint64(math.MaxUint64)
+	if cursor != "" {
+		seq, ok := memorySeq(cursor)
+		if !ok {
+			return nil, ErrInvalidCursor
+		}
+		before = seq
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	page := &DeadLetterPage{Items: make([]DeadLetter, 0, count)}
+	for i := len(q.deadLetters) - 1; i >= 0; i-- {
+		dl := q.deadLetters[i]
+		if seq, _ := memorySeq(dl.ID); seq >= before || dl.FormID != formID {
+			continue
+		}
+		if len(page.Items) == count {
+			page.NextCursor = page.Items[count-1].ID
+			break
+		}
+		page.Items = append(page.Items, dl)
+	}
+	return page, nil
+}
+
+// GetDeadLetter 按 ID 获取单条死信
+func (q *memoryQueue) GetDeadLetter(ctx context.Context, id string) (*DeadLetter, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for _, dl := range q.deadLetters {
+		if dl.ID == id {
+			record := dl
+			return &record, nil
+		}
+	}
+	return nil, ErrDeadLetterNotFound
+}
+
+// DeleteDeadLetter 删除一条死信
+func (q *memoryQueue) DeleteDeadLetter(ctx context.Context, id string) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for i, dl := range q.deadLetters {
+		if dl.ID == id {
+			q.deadLetters = append(q.deadLetters[:i], q.deadLetters[i+1:]...)
+			return nil
+		}
+	}
+	return ErrDeadLetterNotFound
+}
+
+// deliver 将消息标记为投递给 consumer，调用方需持有锁
+func (q *memoryQueue) deliver(id, consumer string) Message {
+	e := q.entries[id]
+	e.consumer = consumer
+	e.deliveries++
+	e.deliveredAt = time.Now()
+	return Message{ID: id, Payload: e.payload, Deliveries: e.deliveries}
+}
+
+// nextID 生成与 Redis Stream 格式相同的 "毫秒时间戳-序号" 消息ID，调用方需持有锁
+func (q *memoryQueue) nextID() string {
+	q.seq++
+	return fmt.Sprintf("%d-%d", time.Now().UnixMilli(), q.seq)
+}
+
+// memorySeq 解析 nextID 生成的消息ID中的序号，序号随写入顺序递增
+func memorySeq(id string) (uint64, bool) {
+	ms, seq, found := strings.Cut(id, "-")
+	if !found || !streamIDPattern.MatchString(ms) {
+		return 0, false
+	}
+	n, err := strconv.ParseUint(seq, 10, 64)
+	return n, err == nil
+}
@@ -0,0 +1,77 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestMemoryRoundTrip(t *testing.T) {
+	testRoundTrip(t, NewMemoryQueue())
+}
+
+// 翻页时只返回指定表单的死信，按时间倒序排列，直到 NextCursor 为空
+func TestMemoryDeadLetterPages(t *testing.T) {
+	ctx := context.Background()
+	q := NewMemoryQueue()
+	var want []string
+	for i := 0; i < 5; i++ {
+		for _, formID := range []uint{1, 2} {
+			id, err := q.DeadLetter(ctx, &DeadLetter{FormID: formID})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if formID == 1 {
+				want = append([]string{id}, want...)
+			}
+		}
+	}
+
+	var got []string
+	cursor := ""
+	for pages := 0; ; pages++ {
+		if pages > len(want) {
+			t.Fatal("pagination does not terminate")
+		}
+		page, err := q.ListDeadLetters(ctx, 1, cursor, 2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(page.Items) > 2 {
+			t.Fatalf("page has %d items, want at most 2", len(page.Items))
+		}
+		for _, dl := range page.Items {
+			got = append(got, dl.ID)
+		}
+		if page.NextCursor == "" {
+			break
+		}
+		cursor = page.NextCursor
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("paged dead letters = %v, want %v", got, want)
+	}
+
+	if _, err := q.ListDeadLetters(ctx, 1, "not-a-cursor", 2); !errors.Is(err, ErrInvalidCursor) {
+		t.Errorf("invalid cursor: error = %v, want ErrInvalidCursor", err)
+	}
+}
+
+// 删除不存在的死信与 Redis 驱动一样返回 ErrDeadLetterNotFound
+func TestMemoryDeleteDeadLetter(t *testing.T) {
+	ctx := context.Background()
+	q := NewMemoryQueue()
+	id, err := q.DeadLetter(ctx, &DeadLetter{FormID: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := q.DeleteDeadLetter(ctx, id); err != nil {
+		t.Fatalf("first delete: %v", err)
+	}
+	for _, id := range []string{id, "1-1", "not-an-id"} {
+		if err := q.DeleteDeadLetter(ctx, id); !errors.Is(err, ErrDeadLetterNotFound) {
+			t.Errorf("delete %q: error = %v, want ErrDeadLetterNotFound", id, err)
+		}
+	}
+}
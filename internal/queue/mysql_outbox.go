@@ -0,0 +1,255 @@
+// Package queue 定义了提交消息队列的抽象，以及 Redis Streams、进程内和 MySQL outbox 三种实现
+package queue
+
+import (
+	"context"
+	"errors"
+	"questflow/internal/model"
+	"strconv"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// outboxPollInterval 是没有新消息时轮询 outbox 表的间隔
+const outboxPollInterval = 500 * time.Millisecond
+
+// mysqlOutboxQueue 基于 MySQL outbox 表实现 SubmissionQueue
+// 多个 consumer 之间通过 SELECT ... FOR UPDATE SKIP LOCKED 避免重复投递（需要 MySQL 8.0+）
+type mysqlOutboxQueue struct {
+	db *gorm.DB
+}
+
+// NewMySQLOutboxQueue 创建一个基于 MySQL outbox 表的队列
+func NewMySQLOutboxQueue(db *gorm.DB) SubmissionQueue {
+	return &mysqlOutboxQueue{db: db}
+}
+
+// Setup 创建 outbox 表
+func (q *mysqlOutboxQueue) Setup(ctx context.Context) error {
+	return q.db.WithContext(ctx).AutoMigrate(&model.SubmissionOutbox{})
+}
+
+// Publish 向 outbox 表插入一条待投递的消息
+func (q *mysqlOutboxQueue) Publish(ctx context.Context, payload []byte) (string, error) {
+	row := &model.SubmissionOutbox{Payload: payload, Status: model.OutboxStatusPending}
+	if err := q.db.WithContext(ctx).Create(row).Error; err != nil {
+		return "", err
+	}
+	return strconv.FormatUint(row.ID, 10), nil
+}
+
+// Read 轮询读取待投递的消息，直到读到消息、超时或 ctx 取消
+func (q *mysqlOutboxQueue) Read(ctx context.Context, consumer string, count int, block time.Duration) ([]Message, error) {
+	deadline := time.Now().Add(block)
+	for {
+		messages, err := q.deliver(ctx, consumer, count, pendingScope)
+		if err != nil || len(messages) > 0 {
+			return messages, err
+		}
+		if time.Now().After(deadline) {
+			return nil, nil
+		}
+
+		select {
+		case <-time.After(outboxPollInterval):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// Claim 认领投递后空闲超过 minIdle 仍未确认的消息
+func (q *mysqlOutboxQueue) Claim(ctx context.Context, consumer, owner string, minIdle time.Duration, count int) ([]Message, error) {
+	return q.deliver(ctx, consumer, count, claimScope(owner, minIdle))
+}
+
+// pendingScope 选择待投递的新消息
+func pendingScope(tx *gorm.DB) *gorm.DB {
+	return tx.Where("status = ?", model.OutboxStatusPending)
+}
+
+// claimScope 选择投递后空闲超过 minIdle 仍未确认的消息，owner 不为空时只选择该 consumer 名下的消息
+func claimScope(owner string, minIdle time.Duration) func(tx *gorm.DB) *gorm.DB {
+	return func(tx *gorm.DB) *gorm.DB {
+		tx = tx.Where("status = ? AND delivered_at <= ?", model.OutboxStatusDelivered, time.Now().Add(-minIdle))
+		if owner != "" {
+			tx = tx.Where("consumer = ?", owner)
+		}
+		return tx
+	}
+}
+
+// Ack 删除已处理完成的消息
+func (q *mysqlOutboxQueue) Ack(ctx context.Context, ids ...string) error {
+	rowIDs := parseOutboxIDs(ids)
+	if len(rowIDs) == 0 {
+		return nil
+	}
+	return q.db.WithContext(ctx).
+		Where("id IN ? AND status = ?", rowIDs, model.OutboxStatusDelivered).
+		Delete(&model.SubmissionOutbox{}).Error
+}
+
+// DeadLetter 将原消息标记为死信，死信ID与原消息ID相同
+func (q *mysqlOutboxQueue) DeadLetter(ctx context.Context, dl *DeadLetter) (string, error) {
+	rowID, err := strconv.ParseUint(dl.OriginalID, 10, 64)
+	if err != nil {
+		return "", err
+	}
+	err = q.db.WithContext(ctx).Model(&model.SubmissionOutbox{}).
+		Where("id = ?", rowID).
+		Updates(map[string]interface{}{
+			"status":     model.OutboxStatusDeadLetter,
+			"form_id":    dl.FormID,
+			"reason":     dl.Reason,
+			"deliveries": dl.Attempts,
+			"failed_at":  dl.FailedAt,
+		}).Error
+	if err != nil {
+		return "", err
+	}
+	return dl.OriginalID, nil
+}
+
+// ListDeadLetters 按时间倒序分页返回表单下的死信，游标是上一页最后一条死信的ID
+func (q *mysqlOutboxQueue) ListDeadLetters(ctx context.Context, formID uint, cursor string, count int) (*DeadLetterPage, error) {
+	query := q.db.WithContext(ctx).
+		Where("status = ? AND form_id = ?", model.OutboxStatusDeadLetter, formID)
+	if cursor != "" {
+		rowID, err := strconv.ParseUint(cursor, 10, 64)
+		if err != nil {
+			return nil, ErrInvalidCursor
+		}
+		query = query.Where("id < ?", rowID)
+	}
+	// 多取一条用于判断是否还有下一页
+	var rows []model.SubmissionOutbox
+	if err := query.Order("id desc").Limit(count + 1).Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	page := &DeadLetterPage{Items: make([]DeadLetter, 0, count)}
+	for i, row := range rows {
+		if i == count {
+			page.NextCursor = page.Items[count-1].ID
+			break
+		}
+		page.Items = append(page.Items, outboxToDeadLetter(row))
+	}
+	return page, nil
+}
+
+// GetDeadLetter 按 ID 获取单条死信
+func (q *mysqlOutboxQueue) GetDeadLetter(ctx context.Context, id string) (*DeadLetter, error) {
+	rowID, err := strconv.ParseUint(id, 10, 64)
+	if err != nil {
+		return nil, ErrDeadLetterNotFound
+	}
+	var row model.SubmissionOutbox
+	err = q.db.WithContext(ctx).
+		Where("id = ? AND status = ?", rowID, model.OutboxStatusDeadLetter).
+		First(&row).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrDeadLetterNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	dl := outboxToDeadLetter(row)
+	return &dl, nil
+}
+
+// DeleteDeadLetter 删除一条死信
+func (q *mysqlOutboxQueue) DeleteDeadLetter(ctx context.Context, id string) error {
+	rowID, err := strconv.ParseUint(id, 10, 64)
+	if err != nil {
+		return ErrDeadLetterNotFound
+	}
+	result := q.db.WithContext(ctx).
+		Where("id = ? AND status = ?", rowID, model.OutboxStatusDeadLetter).
+		Delete(&model.SubmissionOutbox{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrDeadLetterNotFound
+	}
+	return nil
+}
+
+// deliver 在一个事务中锁定满足 scope 条件的消息，并将其投递给 consumer
+func (q *mysqlOutboxQueue) deliver(ctx context.Context, consumer string, count int, scope func(tx *gorm.DB) *gorm.DB) ([]Message, error) {
+	var messages []Message
+	err := q.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var rows []model.SubmissionOutbox
+		err := lockRows(tx, count, scope).Find(&rows).Error
+		if err != nil || len(rows) == 0 {
+			return err
+		}
+
+		rowIDs := make([]uint64, 0, len(rows))
+		for _, row := range rows {
+			rowIDs = append(rowIDs, row.ID)
+		}
+		err = tx.Model(&model.SubmissionOutbox{}).
+			Where("id IN ?", rowIDs).
+			Updates(map[string]interface{}{
+				"status":       model.OutboxStatusDelivered,
+				"consumer":     consumer,
+				"deliveries":   gorm.Expr("deliveries + 1"),
+				"delivered_at": time.Now(),
+			}).Error
+		if err != nil {
+			return err
+		}
+
+		for _, row := range rows {
+			messages = append(messages, Message{
+				ID:         strconv.FormatUint(row.ID, 10),
+				Payload:    row.Payload,
+				Deliveries: row.Deliveries + 1,
+			})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return messages, nil
+}
+
+// lockRows 按 ID 顺序锁定满足 scope 条件的最多 count 条消息，跳过已被其他 consumer 的事务锁定的行
+func lockRows(tx *gorm.DB, count int, scope func(tx *gorm.DB) *gorm.DB) *gorm.DB {
+	return scope(tx).
+		Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+		Order("id").
+		Limit(count)
+}
+
+// parseOutboxIDs 将字符串消息ID转换为 outbox 表主键，忽略无法解析的ID
+func parseOutboxIDs(ids []string) []uint64 {
+	rowIDs := make([]uint64, 0, len(ids))
+	for _, id := range ids {
+		if rowID, err := strconv.ParseUint(id, 10, 64); err == nil {
+			rowIDs = append(rowIDs, rowID)
+		}
+	}
+	return rowIDs
+}
+
+// outboxToDeadLetter 将 outbox 记录转换为 DeadLetter
+func outboxToDeadLetter(row model.SubmissionOutbox) DeadLetter {
+	dl := DeadLetter{
+		ID:         strconv.FormatUint(row.ID, 10),
+		OriginalID: strconv.FormatUint(row.ID, 10),
+		FormID:     row.FormID,
+		Payload:    string(row.Payload),
+		Reason:     row.Reason,
+		Attempts:   row.Deliveries,
+	}
+	if row.FailedAt != nil {
+		dl.FailedAt = *row.FailedAt
+	}
+	return dl
+}
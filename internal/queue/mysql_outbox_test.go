@@ -0,0 +1,66 @@
+package queue
+
+import (
+	"os"
+	"questflow/internal/model"
+	"strings"
+	"testing"
+	"time"
+
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+)
+
+// 认领消息的查询必须使用 FOR UPDATE SKIP LOCKED，多个 consumer 才不会重复投递同一条消息
+func TestOutboxClaimSkipsLockedRows(t *testing.T) {
+	db, err := gorm.Open(mysql.New(mysql.Config{DSN: "user:pass@tcp(127.0.0.1:1)/questflow", SkipInitializeWithVersion: true}),
+		&gorm.Config{DisableAutomaticPing: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		scope func(tx *gorm.DB) *gorm.DB
+		want  []string
+	}{
+		{"read", pendingScope, []string{"status = 0"}},
+		{"claim", claimScope("", time.Minute), []string{"status = 1 AND delivered_at <= "}},
+		{"claim from owner", claimScope("c1", time.Minute), []string{"status = 1 AND delivered_at <= ", "consumer = 'c1'"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sql := db.ToSQL(func(tx *gorm.DB) *gorm.DB {
+				var rows []model.SubmissionOutbox
+				return lockRows(tx, 5, tt.scope).Find(&rows)
+			})
+			for _, want := range append(tt.want, "ORDER BY id LIMIT 5 FOR UPDATE SKIP LOCKED") {
+				if !strings.Contains(sql, want) {
+					t.Errorf("query %q does not contain %q", sql, want)
+				}
+			}
+		})
+	}
+}
+
+// 连接 QUESTFLOW_TEST_MYSQL_DSN 指定的测试库运行完整流程，会清空 submission_outbox 表
+func TestOutboxRoundTrip(t *testing.T) {
+	dsn := os.Getenv("QUESTFLOW_TEST_MYSQL_DSN")
+	if dsn == "" {
+		t.Skip("QUESTFLOW_TEST_MYSQL_DSN is not set")
+	}
+	db, err := gorm.Open(mysql.Open(dsn), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	q := NewMySQLOutboxQueue(db)
+	cleanup := func() {
+		db.Session(&gorm.Session{AllowGlobalUpdate: true}).Delete(&model.SubmissionOutbox{})
+	}
+	if err := db.AutoMigrate(&model.SubmissionOutbox{}); err != nil {
+		t.Fatal(err)
+	}
+	cleanup()
+	t.Cleanup(cleanup)
+	testRoundTrip(t, q)
+}
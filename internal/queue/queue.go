@@ -0,0 +1,97 @@
+// Package queue 定义了提交消息队列的抽象，以及 Redis Streams、进程内和 MySQL outbox 三种实现
+package queue
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"questflow/pkg/config"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 队列驱动名称，对应配置中的 queue.driver
+const (
+	DriverRedis  = "redis"
+	DriverMemory = "memory"
+	DriverMySQL  = "mysql"
+)
+
+// ErrDeadLetterNotFound 表示指定 ID 的死信消息不存在
+var ErrDeadLetterNotFound = errors.New("dead letter not found")
+
+// ErrInvalidCursor 表示分页查询死信时传入的游标格式不正确
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// Message 是从队列中取出的一条待处理消息
+type Message struct {
+	ID         string
+	Payload    []byte
+	Deliveries int64 // 包含本次在内的投递次数
+}
+
+// DeadLetter 是死信队列中的一条记录
+type DeadLetter struct {
+	ID         string    `json:"id"`          // 死信队列中的消息ID
+	OriginalID string    `json:"original_id"` // 原始队列中的消息ID
+	FormID     uint      `json:"form_id"`     // 无法解析出表单时为 0
+	Payload    string    `json:"payload"`
+	Reason     string    `json:"reason"`
+	Attempts   int64     `json:"attempts"`
+	FailedAt   time.Time `json:"failed_at"`
+}
+
+// DeadLetterPage 是按时间倒序分页查询死信的一页结果
+type DeadLetterPage struct {
+	Items      []DeadLetter `json:"items"`
+	NextCursor string       `json:"next_cursor,omitempty"` // 查询下一页时传入的游标，为空表示没有更多死信
+}
+
+// SubmissionQueue 是提交消息队列的抽象
+// 消息被读取后进入"已投递未确认"状态，直到被 Ack 或转入死信队列；
+// 崩溃或处理失败的消息可以通过 Claim 重新认领。
+type SubmissionQueue interface {
+	// Setup 创建队列依赖的底层资源（消费组、数据表等），可重复调用
+	Setup(ctx context.Context) error
+	// Publish 发布一条消息，返回消息ID
+	Publish(ctx context.Context, payload []byte) (string, error)
+	// Read 以 consumer 的身份读取最多 count 条新消息，没有新消息时最多阻塞 block
+	Read(ctx context.Context, consumer string, count int, block time.Duration) ([]Message, error)
+	// Claim 将空闲超过 minIdle 的已投递未确认消息认领到 consumer 名下，投递次数加一；
+	// owner 不为空时只认领该 consumer 名下的消息
+	Claim(ctx context.Context, consumer, owner string, minIdle time.Duration, count int) ([]Message, error)
+	// Ack 确认消息已处理完成
+	Ack(ctx context.Context, ids ...string) error
+	// DeadLetter 将消息写入死信队列并确认原消息，返回死信ID
+	DeadLetter(ctx context.Context, dl *DeadLetter) (string, error)
+	// ListDeadLetters 按时间倒序返回表单 formID 下排在 cursor 之后的最多 count 条死信，cursor 为空时从最新的死信开始；
+	// cursor 格式不正确时返回 ErrInvalidCursor
+	ListDeadLetters(ctx context.Context, formID uint, cursor string, count int) (*DeadLetterPage, error)
+	// GetDeadLetter 按 ID 获取死信，不存在时返回 ErrDeadLetterNotFound
+	GetDeadLetter(ctx context.Context, id string) (*DeadLetter, error)
+	// DeleteDeadLetter 删除一条死信，不存在时返回 ErrDeadLetterNotFound
+	DeleteDeadLetter(ctx context.Context, id string) error
+}
+
+// New 根据配置中的 queue.driver 创建对应的队列实现，未配置时默认使用 Redis Streams
+func New(db *gorm.DB) (SubmissionQueue, error) {
+	switch Driver() {
+	case DriverRedis:
+		return NewRedisStreamQueue(), nil
+	case DriverMemory:
+		return NewMemoryQueue(), nil
+	case DriverMySQL:
+		return NewMySQLOutboxQueue(db), nil
+	default:
+		return nil, fmt.Errorf("unknown queue driver %q", config.Cfg.Queue.Driver)
+	}
+}
+
+// Driver 返回当前配置的队列驱动名称
+func Driver() string {
+	if config.Cfg.Queue.Driver == "" {
+		return DriverRedis
+	}
+	return config.Cfg.Queue.Driver
+}
@@ -0,0 +1,123 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"testing"
+	"time"
+)
+
+// testRoundTrip 在一个空队列上检查发布、读取、认领、确认和死信的完整流程，三种驱动共用
+func testRoundTrip(t *testing.T, q SubmissionQueue) {
+	t.Helper()
+	ctx := context.Background()
+	if err := q.Setup(ctx); err != nil {
+		t.Fatalf("Setup: %v", err)
+	}
+
+	published := make(map[string]string)
+	for _, payload := range []string{`{"form_id": 7, "n": 1}`, `{"form_id": 7, "n": 2}`} {
+		id, err := q.Publish(ctx, []byte(payload))
+		if err != nil {
+			t.Fatalf("Publish: %v", err)
+		}
+		published[id] = payload
+	}
+
+	// 新消息只会被读取一次
+	read, err := q.Read(ctx, "c1", 10, 10*time.Millisecond)
+	if err != nil {
+		t.Fatalf("Read: %v", err)
+	}
+	checkMessages(t, "Read", read, published, 1)
+	if again, err := q.Read(ctx, "c1", 10, 10*time.Millisecond); err != nil || len(again) != 0 {
+		t.Fatalf("second Read = %v, %v; want no messages", again, err)
+	}
+
+	// c2 认领 c1 名下未确认的消息，之后 c1 名下不再有消息
+	claimed, err := q.Claim(ctx, "c2", "c1", 0, 10)
+	if err != nil {
+		t.Fatalf("Claim: %v", err)
+	}
+	checkMessages(t, "Claim from c1", claimed, published, 2)
+	if again, err := q.Claim(ctx, "c3", "c1", 0, 10); err != nil || len(again) != 0 {
+		t.Fatalf("Claim from c1 after c2 claimed = %v, %v; want no messages", again, err)
+	}
+	if idle, err := q.Claim(ctx, "c3", "", time.Hour, 10); err != nil || len(idle) != 0 {
+		t.Fatalf("Claim with minIdle 1h = %v, %v; want no messages", idle, err)
+	}
+
+	// 确认后的消息不再被认领
+	ids := sortedIDs(claimed)
+	acked, failed := ids[0], ids[1]
+	if err := q.Ack(ctx, acked); err != nil {
+		t.Fatalf("Ack: %v", err)
+	}
+	remaining, err := q.Claim(ctx, "c3", "", 0, 10)
+	if err != nil {
+		t.Fatalf("Claim after Ack: %v", err)
+	}
+	checkMessages(t, "Claim after Ack", remaining, map[string]string{failed: published[failed]}, 3)
+
+	// 转入死信后原消息被确认，死信可以查询和删除
+	failedAt := time.Unix(time.Now().Unix(), 0)
+	dlID, err := q.DeadLetter(ctx, &DeadLetter{OriginalID: failed, FormID: 7, Payload: published[failed], Reason: "boom", Attempts: 3, FailedAt: failedAt})
+	if err != nil {
+		t.Fatalf("DeadLetter: %v", err)
+	}
+	if rest, err := q.Claim(ctx, "c3", "", 0, 10); err != nil || len(rest) != 0 {
+		t.Fatalf("Claim after DeadLetter = %v, %v; want no messages", rest, err)
+	}
+	page, err := q.ListDeadLetters(ctx, 7, "", 10)
+	if err != nil {
+		t.Fatalf("ListDeadLetters: %v", err)
+	}
+	if len(page.Items) != 1 || page.NextCursor != "" {
+		t.Fatalf("ListDeadLetters = %+v, want one dead letter", page)
+	}
+	dl := page.Items[0]
+	if dl.ID != dlID || dl.OriginalID != failed || dl.FormID != 7 || dl.Payload != published[failed] ||
+		dl.Reason != "boom" || dl.Attempts != 3 || !dl.FailedAt.Equal(failedAt) {
+		t.Errorf("dead letter = %+v", dl)
+	}
+	if other, err := q.ListDeadLetters(ctx, 8, "", 10); err != nil || len(other.Items) != 0 {
+		t.Errorf("dead letters of another form = %+v, %v", other, err)
+	}
+	if got, err := q.GetDeadLetter(ctx, dlID); err != nil || got.ID != dlID {
+		t.Errorf("GetDeadLetter = %+v, %v", got, err)
+	}
+	if err := q.DeleteDeadLetter(ctx, dlID); err != nil {
+		t.Fatalf("DeleteDeadLetter: %v", err)
+	}
+	if _, err := q.GetDeadLetter(ctx, dlID); !errors.Is(err, ErrDeadLetterNotFound) {
+		t.Errorf("GetDeadLetter after delete: error = %v, want ErrDeadLetterNotFound", err)
+	}
+	if err := q.DeleteDeadLetter(ctx, dlID); !errors.Is(err, ErrDeadLetterNotFound) {
+		t.Errorf("second DeleteDeadLetter: error = %v, want ErrDeadLetterNotFound", err)
+	}
+}
+
+// checkMessages 检查取到的消息正好是 want 中的消息，且投递次数都是 deliveries
+func checkMessages(t *testing.T, step string, got []Message, want map[string]string, deliveries int64) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Fatalf("%s: got %d messages, want %d", step, len(got), len(want))
+	}
+	for _, m := range got {
+		payload, ok := want[m.ID]
+		if !ok || string(m.Payload) != payload || m.Deliveries != deliveries {
+			t.Errorf("%s: message %s with payload %s delivered %d times, want %d", step, m.ID, m.Payload, m.Deliveries, deliveries)
+		}
+	}
+}
+
+// sortedIDs 返回按 ID 排序的消息ID，进程内队列认领的顺序不固定
+func sortedIDs(messages []Message) []string {
+	ids := make([]string, 0, len(messages))
+	for _, m := range messages {
+		ids = append(ids, m.ID)
+	}
+	sort.Strings(ids)
+	return ids
+}
@@ -0,0 +1,262 @@
+// Package queue 定义了提交消息队列的抽象，以及 Redis Streams、进程内和 MySQL outbox 三种实现
+package queue
+
+import (
+	"context"
+	"errors"
+	"questflow/pkg/config"
+	redisPkg "questflow/pkg/redis"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// streamIDPattern 匹配 stream 消息ID，形如 "1700000000000-0"，序号部分可以省略
+var streamIDPattern = regexp.MustCompile(`^[0-9]+(-[0-9]+)?$`)
+
+// 死信 stream 中混有所有表单的死信，分页时按批读取并筛选出指定表单的死信。
+// 单次查询最多扫描 deadLetterScanLimit 条，未凑满一页时返回已扫描到的位置作为游标，由调用方继续翻页
+const (
+	deadLetterScanBatch = 100
+	deadLetterScanLimit = 1000
+)
+
+// redisStreamQueue 基于 Redis Streams 和消费组实现 SubmissionQueue
+type redisStreamQueue struct {
+	streamKey     string
+	groupName     string
+	deadLetterKey string
+}
+
+// NewRedisStreamQueue 创建一个基于 Redis Streams 的队列
+func NewRedisStreamQueue() SubmissionQueue {
+	return &redisStreamQueue{
+		streamKey:     config.Cfg.Redis.SubmissionStreamKey,
+		groupName:     config.Cfg.Redis.SubmissionGroupName,
+		deadLetterKey: config.Cfg.Redis.SubmissionDeadLetterStreamKey,
+	}
+}
+
+// Setup 创建消费组（stream 不存在时一并创建）
+func (q *redisStreamQueue) Setup(ctx context.Context) error {
+	err := redisPkg.RDB.XGroupCreateMkStream(ctx, q.streamKey, q.groupName, "0").Err()
+	if err != nil && err.Error() != "BUSYGROUP Consumer Group name already exists" {
+		return err
+	}
+	return nil
+}
+
+// Publish 向 submission stream 发送消息
+func (q *redisStreamQueue) Publish(ctx context.Context, payload []byte) (string, error) {
+	return redisPkg.RDB.XAdd(ctx, &redis.XAddArgs{
+		Stream: q.streamKey,
+		Values: map[string]interface{}{"payload": payload},
+	}).Result()
+}
+
+// Read 使用 XREADGROUP 读取新消息
+func (q *redisStreamQueue) Read(ctx context.Context, consumer string, count int, block time.Duration) ([]Message, error) {
+	streams, err := redisPkg.RDB.XReadGroup(ctx, &redis.XReadGroupArgs{
+		Group:    q.groupName,
+		Consumer: consumer,
+		Streams:  []string{q.streamKey, ">"},
+		Count:    int64(count),
+		Block:    block,
+	}).Result()
+	if errors.Is(err, redis.Nil) {
+		return nil, nil // 超时内没有新消息
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var messages []Message
+	for _, stream := range streams {
+		for _, m := range stream.Messages {
+			// 通过 ">" 读取到的新消息是第一次投递
+			messages = append(messages, toMessage(m, 1))
+		}
+	}
+	return messages, nil
+}
+
+// Claim 通过 XPENDING + XCLAIM 认领空闲消息
+// 这里没有使用 XAUTOCLAIM，因为当前版本的客户端无法解析 Redis 7 的返回格式
+func (q *redisStreamQueue) Claim(ctx context.Context, consumer, owner string, minIdle time.Duration, count int) ([]Message, error) {
+	pending, err := redisPkg.RDB.XPendingExt(ctx, &redis.XPendingExtArgs{
+		Stream:   q.streamKey,
+		Group:    q.groupName,
+		Idle:     minIdle,
+		Start:    "-",
+		End:      "+",
+		Count:    int64(count),
+		Consumer: owner,
+	}).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	var messages []Message
+	for _, p := range pending {
+		// XCLAIM 会把投递次数加一并重置空闲时间；
+		// 若其他 consumer 已抢先认领，MinIdle 条件不再满足，这里返回空结果
+		claimed, err := redisPkg.RDB.XClaim(ctx, &redis.XClaimArgs{
+			Stream:   q.streamKey,
+			Group:    q.groupName,
+			Consumer: consumer,
+			MinIdle:  minIdle,
+			Messages: []string{p.ID},
+		}).Result()
+		if err != nil {
+			return messages, err
+		}
+		for _, m := range claimed {
+			if m.Values == nil {
+				// 消息已从 stream 中删除，只需要清理 pending 记录
+				_ = q.Ack(ctx, m.ID)
+				continue
+			}
+			messages = append(messages, toMessage(m, p.RetryCount+1))
+		}
+	}
+	return messages, nil
+}
+
+// Ack 确认消息
+func (q *redisStreamQueue) Ack(ctx context.Context, ids ...string) error {
+	return redisPkg.RDB.XAck(ctx, q.streamKey, q.groupName, ids...).Err()
+}
+
+// DeadLetter 将消息写入死信 stream，然后 ACK 原消息
+// 死信 stream 不做长度裁剪：死信中保存的是尚未写入数据库的提交，只能由表单所有者重新投递或删除
+func (q *redisStreamQueue) DeadLetter(ctx context.Context, dl *DeadLetter) (string, error) {
+	id, err := redisPkg.RDB.XAdd(ctx, &redis.XAddArgs{
+		Stream: q.deadLetterKey,
+		Values: map[string]interface{}{
+			"original_id": dl.OriginalID,
+			"form_id":     dl.FormID,
+			"payload":     dl.Payload,
+			"reason":      dl.Reason,
+			"attempts":    dl.Attempts,
+			"failed_at":   dl.FailedAt.Unix(),
+		},
+	}).Result()
+	if err != nil {
+		return "", err
+	}
+	return id, q.Ack(ctx, dl.OriginalID)
+}
+
+// ListDeadLetters 使用 XREVRANGE ... COUNT 从游标处向前按批读取死信 stream，游标是上一页最后扫描到的消息ID
+func (q *redisStreamQueue) ListDeadLetters(ctx context.Context, formID uint, cursor string, count int) (*DeadLetterPage, error) {
+	end := "+"
+	if cursor != "" {
+		if !streamIDPattern.MatchString(cursor) {
+			return nil, ErrInvalidCursor
+		}
+		end = cursor
+	}
+	page := &DeadLetterPage{Items: make([]DeadLetter, 0, count)}
+	for scanned := 0; scanned < deadLetterScanLimit; {
+		// XREVRANGE 的区间包含 end 本身，end 是游标或上一批的最后一条，已经处理过，需要跳过
+		messages, err := redisPkg.RDB.XRevRangeN(ctx, q.deadLetterKey, end, "-", deadLetterScanBatch).Result()
+		if isInvalidStreamID(err) {
+			return nil, ErrInvalidCursor
+		}
+		if err != nil {
+			return nil, err
+		}
+		for _, m := range messages {
+			if m.ID == end {
+				continue
+			}
+			scanned++
+			end = m.ID
+			dl := parseDeadLetter(m)
+			if dl.FormID != formID {
+				continue
+			}
+			page.Items = append(page.Items, dl)
+			if len(page.Items) == count {
+				page.NextCursor = m.ID
+				return page, nil
+			}
+		}
+		if len(messages) < deadLetterScanBatch {
+			return page, nil
+		}
+	}
+	page.NextCursor = end
+	return page, nil
+}
+
+// GetDeadLetter 按 ID 获取单条死信，格式不正确的 ID 视为不存在
+func (q *redisStreamQueue) GetDeadLetter(ctx context.Context, id string) (*DeadLetter, error) {
+	if !streamIDPattern.MatchString(id) {
+		return nil, ErrDeadLetterNotFound
+	}
+	messages, err := redisPkg.RDB.XRangeN(ctx, q.deadLetterKey, id, id, 1).Result()
+	if isInvalidStreamID(err) {
+		return nil, ErrDeadLetterNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(messages) == 0 {
+		return nil, ErrDeadLetterNotFound
+	}
+	dl := parseDeadLetter(messages[0])
+	return &dl, nil
+}
+
+// DeleteDeadLetter 从死信 stream 中删除一条消息
+func (q *redisStreamQueue) DeleteDeadLetter(ctx context.Context, id string) error {
+	if !streamIDPattern.MatchString(id) {
+		return ErrDeadLetterNotFound
+	}
+	deleted, err := redisPkg.RDB.XDel(ctx, q.deadLetterKey, id).Result()
+	if isInvalidStreamID(err) {
+		return ErrDeadLetterNotFound
+	}
+	if err != nil {
+		return err
+	}
+	if deleted == 0 {
+		return ErrDeadLetterNotFound
+	}
+	return nil
+}
+
+// isInvalidStreamID 判断错误是否是 Redis 无法解析 stream ID（例如序号超出范围）
+func isInvalidStreamID(err error) bool {
+	return err != nil && strings.Contains(err.Error(), "Invalid stream ID")
+}
+
+// toMessage 将 stream 消息转换为 Message
+func toMessage(m redis.XMessage, deliveries int64) Message {
+	payload, _ := m.Values["payload"].(string)
+	return Message{ID: m.ID, Payload: []byte(payload), Deliveries: deliveries}
+}
+
+// parseDeadLetter 将死信 stream 中的原始字段转换为 DeadLetter
+func parseDeadLetter(m redis.XMessage) DeadLetter {
+	str := func(key string) string {
+		v, _ := m.Values[key].(string)
+		return v
+	}
+	formID, _ := strconv.ParseUint(str("form_id"), 10, 32)
+	attempts, _ := strconv.ParseInt(str("attempts"), 10, 64)
+	failedAt, _ := strconv.ParseInt(str("failed_at"), 10, 64)
+	return DeadLetter{
+		ID:         m.ID,
+		OriginalID: str("original_id"),
+		FormID:     uint(formID),
+		Payload:    str("payload"),
+		Reason:     str("reason"),
+		Attempts:   attempts,
+		FailedAt:   time.Unix(failedAt, 0),
+	}
+}
@@ -0,0 +1,79 @@
+package queue
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"questflow/pkg/config"
+	redisPkg "questflow/pkg/redis"
+	"testing"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// newTestRedisQueue 连接 QUESTFLOW_TEST_REDIS_ADDR 指定的 Redis，未设置时跳过测试。
+// 每个测试使用独立的 stream，测试结束后删除
+func newTestRedisQueue(t *testing.T) SubmissionQueue {
+	addr := os.Getenv("QUESTFLOW_TEST_REDIS_ADDR")
+	if addr == "" {
+		t.Skip("QUESTFLOW_TEST_REDIS_ADDR is not set")
+	}
+	redisPkg.RDB = redis.NewClient(&redis.Options{Addr: addr})
+	t.Cleanup(func() { redisPkg.RDB = nil })
+
+	prefix := fmt.Sprintf("questflow:test:%s:%d", t.Name(), time.Now().UnixNano())
+	config.Cfg.Redis.SubmissionStreamKey = prefix + ":submissions"
+	config.Cfg.Redis.SubmissionGroupName = "test-group"
+	config.Cfg.Redis.SubmissionDeadLetterStreamKey = prefix + ":dead-letters"
+	t.Cleanup(func() {
+		redisPkg.RDB.Del(context.Background(), config.Cfg.Redis.SubmissionStreamKey, config.Cfg.Redis.SubmissionDeadLetterStreamKey)
+	})
+	return NewRedisStreamQueue()
+}
+
+func TestRedisStreamRoundTrip(t *testing.T) {
+	testRoundTrip(t, newTestRedisQueue(t))
+}
+
+// 死信 stream 中混有其他表单的死信时，翻页会跨批读取并返回已扫描到的位置
+func TestRedisDeadLetterPages(t *testing.T) {
+	q := newTestRedisQueue(t)
+	ctx := context.Background()
+	var want []string
+	for i := 0; i < deadLetterScanBatch+5; i++ {
+		formID := uint(2)
+		if i%50 == 0 {
+			formID = 1
+		}
+		id, err := q.DeadLetter(ctx, &DeadLetter{OriginalID: fmt.Sprintf("0-%d", i+1), FormID: formID, FailedAt: time.Now()})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if formID == 1 {
+			want = append([]string{id}, want...)
+		}
+	}
+
+	var got []string
+	cursor := ""
+	for pages := 0; ; pages++ {
+		if pages > len(want) {
+			t.Fatal("pagination does not terminate")
+		}
+		page, err := q.ListDeadLetters(ctx, 1, cursor, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, dl := range page.Items {
+			got = append(got, dl.ID)
+		}
+		if page.NextCursor == "" {
+			break
+		}
+		cursor = page.NextCursor
+	}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("paged dead letters = %v, want %v", got, want)
+	}
+}
@@ -15,6 +15,7 @@ type FormRepository interface {
 	FindByCreatorID(creatorID uint) ([]model.Form, error)
 	Delete(form *model.Form) error
 	Update(form *model.Form) error
+	CloseIfPublished(id uint) (bool, error)
 }
 
 // formGormRepository 是 FormRepository 的 GORM 实现
@@ -69,3 +70,10 @@ func (r *formGormRepository) Delete(form *model.Form) error {
 func (r *formGormRepository) Update(form *model.Form) error {
 	return r.db.Save(form).Error
 }
+
+// CloseIfPublished 把已发布的表单改为已关闭状态，只更新 status 字段，避免覆盖并发的编辑；
+// 返回表单是否由本次调用关闭
+func (r *formGormRepository) CloseIfPublished(id uint) (bool, error) {
+	result := r.db.Model(&model.Form{}).Where("id = ? AND status = ?", id, 2).Update("status", 3)
+	return result.RowsAffected > 0, result.Error
+}
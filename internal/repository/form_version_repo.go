@@ -0,0 +1,97 @@
+// Package repository 封装了数据访问逻辑
+package repository
+
+import (
+	"questflow/internal/model"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// FormVersionRepository 定义了表单版本快照数据仓库的接口
+type FormVersionRepository interface {
+	UpdateWithSnapshots(form *model.Form, columns []string, snapshots ...*model.Form) error
+	FindByID(id uint) (*model.FormVersion, error)
+	FindByFormID(formID uint) ([]model.FormVersion, error)
+}
+
+// formVersionGormRepository 是 FormVersionRepository 的 GORM 实现
+type formVersionGormRepository struct {
+	db *gorm.DB
+}
+
+// NewFormVersionRepository 创建一个新的 FormVersionRepository 实例
+func NewFormVersionRepository(db *gorm.DB) FormVersionRepository {
+	return &formVersionGormRepository{db: db}
+}
+
+// UpdateWithSnapshots 在同一个事务中把 snapshots 中的标题、描述和定义依次保存为表单的新版本，
+// 再更新表单本身；最后一个快照成为表单最近发布的版本。任意一步失败时版本和表单都不会改变。
+// 保存第一个版本时，之前没有版本的提交记录都会归到这个版本。
+// 表单只更新 columns 中列出的字段和 published_version_id，
+// 不会覆盖并发修改的其他字段，例如配额或截止时间触发的 CloseIfPublished 写入的 status
+func (r *formVersionGormRepository) UpdateWithSnapshots(form *model.Form, columns []string, snapshots ...*model.Form) error {
+	publishedVersionID := form.PublishedVersionID
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		// 锁住表单行，保证并发发布时版本号不会重复
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id").First(&model.Form{}, form.ID).Error; err != nil {
+			return err
+		}
+		for _, snapshot := range snapshots {
+			version, err := createVersion(tx, snapshot)
+			if err != nil {
+				return err
+			}
+			form.PublishedVersionID = &version.ID
+		}
+		selected := append(append([]string(nil), columns...), "published_version_id")
+		return tx.Model(form).Select(selected).Updates(form).Error
+	})
+	if err != nil {
+		form.PublishedVersionID = publishedVersionID
+	}
+	return err
+}
+
+// createVersion 在事务中把表单内容保存为下一个版本，调用方需要先锁住表单行
+func createVersion(tx *gorm.DB, snapshot *model.Form) (*model.FormVersion, error) {
+	version := &model.FormVersion{
+		FormID:      snapshot.ID,
+		Title:       snapshot.Title,
+		Description: snapshot.Description,
+		Definition:  snapshot.Definition,
+	}
+	var latest int
+	if err := tx.Model(&model.FormVersion{}).Where("form_id = ?", snapshot.ID).
+		Select("COALESCE(MAX(version), 0)").Scan(&latest).Error; err != nil {
+		return nil, err
+	}
+	version.Version = latest + 1
+	if err := tx.Create(version).Error; err != nil {
+		return nil, err
+	}
+	if version.Version == 1 {
+		if err := tx.Model(&model.Submission{}).
+			Where("form_id = ? AND form_version_id IS NULL", snapshot.ID).
+			UpdateColumn("form_version_id", version.ID).Error; err != nil {
+			return nil, err
+		}
+	}
+	return version, nil
+}
+
+// FindByID 通过主键 ID 查找版本快照
+func (r *formVersionGormRepository) FindByID(id uint) (*model.FormVersion, error) {
+	var version model.FormVersion
+	if err := r.db.First(&version, id).Error; err != nil {
+		return nil, err
+	}
+	return &version, nil
+}
+
+// FindByFormID 查找表单的所有版本快照，最新的版本在前
+func (r *formVersionGormRepository) FindByFormID(formID uint) ([]model.FormVersion, error) {
+	var versions []model.FormVersion
+	err := r.db.Where("form_id = ?", formID).Order("version desc").Find(&versions).Error
+	return versions, err
+}
@@ -4,25 +4,31 @@ package repository
 import (
 	"fmt"
 	"questflow/internal/model"
+	"questflow/internal/question"
 	"strings"
 	"time"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
-// FilterCondition 定义了单个筛选条件
-type FilterCondition struct {
-	QuestionID   string   `json:"questionId"`
-	QuestionType string   `json:"questionType"`
-	Operator     string   `json:"operator"` // "equals", "not_equals", "contains", "not_contains"
-	Value        []string `json:"value"`    // 答案值，使用数组以支持多选
-}
+// FilterCondition 定义了单个筛选条件，具体的 SQL 由对应题型生成
+type FilterCondition = question.FilterCondition
 
 // SubmissionRepository 接口定义
 type SubmissionRepository interface {
-	Create(submission *model.Submission) error
+	Create(submission *model.Submission, fileTokens []string) error
+	CreateBatch(submissions []*model.Submission, fileTokens [][]string) error
 	FindByFormID(formID uint) ([]model.Submission, error)
+	FindByIdempotencyKey(formID uint, key string) (*model.Submission, error)
 	FindWithFilters(formID uint, startTime, endTime *time.Time, conditions []FilterCondition) ([]model.Submission, error)
+	FindByID(id uint) (*model.Submission, error)
+	FindUngradedAnswers(formID uint, questionID string, limit, offset int) ([]model.Submission, error)
+	FindGradesByFormID(formID uint) ([]model.AnswerGrade, error)
+	CountByFormVersion(formID uint) (map[uint]int64, error)
+	CountByFormID(formID uint) (int64, error)
+	CountByAnswerOption(formID uint, questionID, optionID string) (int64, error)
+	SaveAnswerGrade(grade *model.AnswerGrade, autoScore, maxScore int, manualQuestionIDs []string) error
 }
 
 // submissionGormRepository 是 SubmissionRepository 的 GORM 实现
@@ -35,9 +41,32 @@ func NewSubmissionRepository(db *gorm.DB) SubmissionRepository {
 	return &submissionGormRepository{db: db}
 }
 
-// Create 在数据库中创建一条新的提交记录
-func (r *submissionGormRepository) Create(submission *model.Submission) error {
-	return r.db.Create(submission).Error
+// Create 在一个事务中创建一条新的提交记录，并把答案引用的上传文件绑定到该提交
+func (r *submissionGormRepository) Create(submission *model.Submission, fileTokens []string) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(submission).Error; err != nil {
+			return err
+		}
+		return bindUploadedFiles(tx, submission, fileTokens)
+	})
+}
+
+// CreateBatch 在一个事务中批量插入多条提交记录并绑定它们引用的上传文件，fileTokens 与 submissions 一一对应
+func (r *submissionGormRepository) CreateBatch(submissions []*model.Submission, fileTokens [][]string) error {
+	if len(submissions) == 0 {
+		return nil
+	}
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.CreateInBatches(submissions, len(submissions)).Error; err != nil {
+			return err
+		}
+		for i, submission := range submissions {
+			if err := bindUploadedFiles(tx, submission, fileTokens[i]); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
 }
 
 // FindByFormID 查找某个表单下的所有提交记录
@@ -50,7 +79,17 @@ func (r *submissionGormRepository) FindByFormID(formID uint) ([]model.Submission
 	return submissions, nil
 }
 
-// FindWithFilters 【核心修复】使用更健壮的 SQL 构建逻辑
+// FindByIdempotencyKey 通过表单ID和客户端幂等键查找提交记录
+func (r *submissionGormRepository) FindByIdempotencyKey(formID uint, key string) (*model.Submission, error) {
+	var submission model.Submission
+	err := r.db.Where("form_id = ? AND idempotency_key = ?", formID, key).First(&submission).Error
+	if err != nil {
+		return nil, err
+	}
+	return &submission, nil
+}
+
+// FindWithFilters 按时间范围和答案条件查找提交记录，每个条件的 SQL 由题型注册表生成
 func (r *submissionGormRepository) FindWithFilters(formID uint, startTime, endTime *time.Time, conditions []FilterCondition) ([]model.Submission, error) {
 	var submissions []model.Submission
 
@@ -69,46 +108,30 @@ func (r *submissionGormRepository) FindWithFilters(formID uint, startTime, endTi
 	}
 
 	for _, cond := range conditions {
-		jsonPath := fmt.Sprintf("$.\"%s\"", cond.QuestionID)
-
-		switch cond.QuestionType {
-		case "single_choice", "judgment", "text_input":
-			// 对于这些题型，答案是一个字符串
-			value := cond.Value[0] // 单值
-			switch cond.Operator {
-			case "equals":
-				// JSON_EXTRACT 返回带引号的字符串, JSON_UNQUOTE 去掉引号
-				sqlBuilder.WriteString(fmt.Sprintf(" AND JSON_UNQUOTE(data->'%s') = ?", jsonPath))
-				args = append(args, value)
-			case "not_equals":
-				// 检查值不等于或者该键不存在
-				sqlBuilder.WriteString(fmt.Sprintf(" AND (data->'%s' IS NULL OR JSON_UNQUOTE(data->'%s') != ?)", jsonPath, jsonPath))
-				args = append(args, value)
-			}
-		case "multi_choice":
-			// 对于多选题，答案是一个数组
-			// 构造一个 JSON 数组字符串用于查询, e.g., '["val1", "val2"]'
-			var valuePlaceholders []string
-			for range cond.Value {
-				valuePlaceholders = append(valuePlaceholders, "?")
-			}
-			jsonArrayForQuery := fmt.Sprintf("CAST(JSON_ARRAY(%s) AS JSON)", strings.Join(valuePlaceholders, ","))
-
-			for _, v := range cond.Value {
-				args = append(args, v)
-			}
-
-			switch cond.Operator {
-			case "contains": // 包含 cond.Value 中的任意一个
-				sqlBuilder.WriteString(fmt.Sprintf(" AND JSON_OVERLAPS(data->'%s', %s)", jsonPath, jsonArrayForQuery))
-			case "not_contains": // 不包含 cond.Value 中的任何一个
-				sqlBuilder.WriteString(fmt.Sprintf(" AND NOT JSON_OVERLAPS(data->'%s', %s)", jsonPath, jsonArrayForQuery))
-			case "equals": // 完全匹配（忽略顺序）
-				// 检查两个数组长度是否相等 并且 数据库中的数组完全包含查询数组的所有元素
-				sqlBuilder.WriteString(fmt.Sprintf(" AND JSON_LENGTH(data->'%s') = ? AND JSON_CONTAINS(data->'%s', %s)", jsonPath, jsonPath, jsonArrayForQuery))
-				args = append(args, len(cond.Value)) // 添加数组长度作为参数
+		// 按隐藏字段筛选的条件不属于任何问题
+		if cond.Field != "" {
+			clause, clauseArgs := question.BuildHiddenFieldFilter(cond)
+			if clause != "" {
+				sqlBuilder.WriteString(" AND " + clause)
+				args = append(args, clauseArgs...)
 			}
+			continue
+		}
+		qt, ok := question.Lookup(cond.QuestionType)
+		if !ok {
+			continue // 未知题型的条件直接忽略
 		}
+		// 问题ID会拼接进 SQL 中的 JSON 路径，调用方应当只传入定义中存在的问题
+		if !question.ValidKey(cond.QuestionID) {
+			return nil, fmt.Errorf("invalid filter question id %q", cond.QuestionID)
+		}
+		jsonPath := fmt.Sprintf("$.\"%s\"", cond.QuestionID)
+		clause, clauseArgs := qt.BuildFilter(jsonPath, cond)
+		if clause == "" {
+			continue
+		}
+		sqlBuilder.WriteString(" AND " + clause)
+		args = append(args, clauseArgs...)
 	}
 
 	sqlBuilder.WriteString(" ORDER BY created_at asc")
@@ -120,3 +143,99 @@ func (r *submissionGormRepository) FindWithFilters(formID uint, startTime, endTi
 
 	return submissions, nil
 }
+
+// FindByID 通过主键查找提交记录
+func (r *submissionGormRepository) FindByID(id uint) (*model.Submission, error) {
+	var submission model.Submission
+	err := r.db.First(&submission, id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &submission, nil
+}
+
+// FindUngradedAnswers 查找某个问题已作答（非空字符串）但尚未人工评分的提交记录
+func (r *submissionGormRepository) FindUngradedAnswers(formID uint, questionID string, limit, offset int) ([]model.Submission, error) {
+	var submissions []model.Submission
+	jsonPath := fmt.Sprintf("$.\"%s\"", questionID)
+	err := r.db.
+		Where("form_id = ?", formID).
+		Where("JSON_TYPE(JSON_EXTRACT(data, ?)) = 'STRING' AND JSON_UNQUOTE(JSON_EXTRACT(data, ?)) <> ''", jsonPath, jsonPath).
+		Where("NOT EXISTS (SELECT 1 FROM answer_grades WHERE answer_grades.submission_id = submissions.id AND answer_grades.question_id = ?)", questionID).
+		Order("created_at asc").
+		Limit(limit).
+		Offset(offset).
+		Find(&submissions).Error
+	if err != nil {
+		return nil, err
+	}
+	return submissions, nil
+}
+
+// FindGradesByFormID 查找某个表单下的所有人工评分
+func (r *submissionGormRepository) FindGradesByFormID(formID uint) ([]model.AnswerGrade, error) {
+	var grades []model.AnswerGrade
+	err := r.db.Where("form_id = ?", formID).Find(&grades).Error
+	if err != nil {
+		return nil, err
+	}
+	return grades, nil
+}
+
+// CountByFormVersion 按表单版本统计提交数，返回版本ID到提交数的映射（不包含没有版本的提交）
+func (r *submissionGormRepository) CountByFormVersion(formID uint) (map[uint]int64, error) {
+	var rows []struct {
+		FormVersionID uint
+		Count         int64
+	}
+	err := r.db.Model(&model.Submission{}).
+		Select("form_version_id, COUNT(*) AS count").
+		Where("form_id = ? AND form_version_id IS NOT NULL", formID).
+		Group("form_version_id").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	counts := make(map[uint]int64, len(rows))
+	for _, row := range rows {
+		counts[row.FormVersionID] = row.Count
+	}
+	return counts, nil
+}
+
+// CountByFormID 统计表单的提交总数（包含所有版本）
+func (r *submissionGormRepository) CountByFormID(formID uint) (int64, error) {
+	var count int64
+	err := r.db.Model(&model.Submission{}).Where("form_id = ?", formID).Count(&count).Error
+	return count, err
+}
+
+// CountByAnswerOption 统计某个问题选择了指定选项的提交数：单选题的答案等于该选项，或多选题的答案包含该选项
+func (r *submissionGormRepository) CountByAnswerOption(formID uint, questionID, optionID string) (int64, error) {
+	var count int64
+	jsonPath := fmt.Sprintf("$.\"%s\"", questionID)
+	err := r.db.Model(&model.Submission{}).
+		Where("form_id = ?", formID).
+		Where("JSON_CONTAINS(data, JSON_QUOTE(?), ?)", optionID, jsonPath).
+		Count(&count).Error
+	return count, err
+}
+
+// SaveAnswerGrade 在一个事务中保存（或覆盖）单个答案的人工评分，并重新计算提交的得分
+// 新得分 = autoScore + 该提交在 manualQuestionIDs 中各题的人工评分之和，
+// 在同一条 UPDATE 中完成累加，避免并发阅卷同一份答卷时互相覆盖
+func (r *submissionGormRepository) SaveAnswerGrade(grade *model.AnswerGrade, autoScore, maxScore int, manualQuestionIDs []string) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "submission_id"}, {Name: "question_id"}},
+			DoUpdates: clause.AssignmentColumns([]string{"points", "feedback", "grader_id", "updated_at"}),
+		}).Create(grade).Error
+		if err != nil {
+			return err
+		}
+		return tx.Exec(
+			"UPDATE submissions SET raw_score = ? + (SELECT COALESCE(SUM(points), 0) FROM answer_grades WHERE submission_id = ? AND question_id IN ?), max_score = ? WHERE id = ?",
+			autoScore, grade.SubmissionID, manualQuestionIDs, maxScore, grade.SubmissionID,
+		).Error
+	})
+}
@@ -0,0 +1,86 @@
+// Package repository 封装了数据访问逻辑
+package repository
+
+import (
+	"errors"
+	"questflow/internal/model"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// UploadedFileRepository 定义了上传文件记录数据仓库的接口
+type UploadedFileRepository interface {
+	Create(file *model.UploadedFile) error
+	FindByToken(token string) (*model.UploadedFile, error)
+	FindByTokens(tokens []string) ([]model.UploadedFile, error)
+	FindUnusedBefore(before time.Time, limit int) ([]model.UploadedFile, error)
+	DeleteIfUnused(id uint) (bool, error)
+}
+
+// bindUploadedFiles 在写入提交的事务中把文件绑定到该提交。文件必须属于该表单且尚未被使用，
+// 否则返回错误使整个事务回滚，同一个文件令牌不会被两次提交使用
+func bindUploadedFiles(tx *gorm.DB, submission *model.Submission, tokens []string) error {
+	if len(tokens) == 0 {
+		return nil
+	}
+	result := tx.Model(&model.UploadedFile{}).
+		Where("token IN ? AND form_id = ? AND submission_id IS NULL", tokens, submission.FormID).
+		UpdateColumn("submission_id", submission.ID)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected != int64(len(tokens)) {
+		return errors.New("uploaded file is missing or already used by another submission")
+	}
+	return nil
+}
+
+// uploadedFileGormRepository 是 UploadedFileRepository 的 GORM 实现
+type uploadedFileGormRepository struct {
+	db *gorm.DB
+}
+
+// NewUploadedFileRepository 创建一个新的 UploadedFileRepository 实例
+func NewUploadedFileRepository(db *gorm.DB) UploadedFileRepository {
+	return &uploadedFileGormRepository{db: db}
+}
+
+// Create 保存一条上传文件记录
+func (r *uploadedFileGormRepository) Create(file *model.UploadedFile) error {
+	return r.db.Create(file).Error
+}
+
+// FindByToken 通过文件令牌查找上传文件记录
+func (r *uploadedFileGormRepository) FindByToken(token string) (*model.UploadedFile, error) {
+	var file model.UploadedFile
+	if err := r.db.Where("token = ?", token).First(&file).Error; err != nil {
+		return nil, err
+	}
+	return &file, nil
+}
+
+// FindByTokens 批量查找上传文件记录，不存在的令牌会被忽略
+func (r *uploadedFileGormRepository) FindByTokens(tokens []string) ([]model.UploadedFile, error) {
+	var files []model.UploadedFile
+	if len(tokens) == 0 {
+		return files, nil
+	}
+	err := r.db.Where("token IN ?", tokens).Find(&files).Error
+	return files, err
+}
+
+// FindUnusedBefore 查找在 before 之前上传、至今没有被任何提交使用的文件，最多返回 limit 条
+func (r *uploadedFileGormRepository) FindUnusedBefore(before time.Time, limit int) ([]model.UploadedFile, error) {
+	var files []model.UploadedFile
+	err := r.db.Where("submission_id IS NULL AND created_at < ?", before).
+		Order("id asc").Limit(limit).Find(&files).Error
+	return files, err
+}
+
+// DeleteIfUnused 删除一条尚未被提交使用的上传记录，返回是否删除；
+// 与 bindUploadedFiles 通过同一行互斥，已被绑定的文件不会被删除
+func (r *uploadedFileGormRepository) DeleteIfUnused(id uint) (bool, error) {
+	result := r.db.Where("id = ? AND submission_id IS NULL", id).Delete(&model.UploadedFile{})
+	return result.RowsAffected > 0, result.Error
+}
@@ -0,0 +1,148 @@
+// Package service 包含了应用的业务逻辑
+package service
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"hash/fnv"
+	"questflow/internal/question"
+	"questflow/pkg/config"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// 超时提交的处理方式，对应配置中的 exam.late_policy
+const (
+	LatePolicyReject = "reject" // 拒绝提交
+	LatePolicyFlag   = "flag"   // 接受提交，并将其标记为超时
+)
+
+// defaultGracePeriod 是未配置 grace_period_seconds 时超过限时后仍允许提交的时间
+const defaultGracePeriod = 30 * time.Second
+
+// defaultAttemptsPerMinute 是未配置 attempts_per_minute 时同一 IP 每分钟在同一表单上最多开始答题的次数
+const defaultAttemptsPerMinute = 10
+
+// attemptTokenIssuer 用于区分答题令牌和登录令牌
+const attemptTokenIssuer = "questflow-attempt"
+
+// AttemptClaims 是答题令牌中的声明，IssuedAt 即开始答题的时间
+type AttemptClaims struct {
+	FormID        uint  `json:"form_id"`
+	FormVersionID *uint `json:"form_version_id,omitempty"` // 开始答题时表单最近发布的版本
+	jwt.RegisteredClaims
+}
+
+// Attempt 是一次答题的开始信息，返回给填写页
+type Attempt struct {
+	Token     string     `json:"attempt_token"`
+	StartedAt time.Time  `json:"started_at"`
+	TimeLimit int        `json:"time_limit,omitempty"` // 限时（秒），0 表示不限时
+	Deadline  *time.Time `json:"deadline,omitempty"`   // 不含宽限时间的截止时间
+}
+
+// attemptTiming 是校验答题令牌后得到的用时信息
+type attemptTiming struct {
+	AttemptID       string
+	DurationSeconds uint
+	Overtime        bool
+	FormVersionID   *uint // 开始答题时表单最近发布的版本，随机抽题按该版本的定义计算
+}
+
+// issueAttemptToken 为表单签发一个记录了开始时间和当前发布版本的答题令牌
+func issueAttemptToken(formID uint, versionID *uint, settings question.Settings) (*Attempt, error) {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return nil, err
+	}
+	// JWT 中的时间精确到秒，这里同样截断，保证返回的开始时间与令牌一致
+	startedAt := time.Now().Truncate(time.Second)
+
+	claims := AttemptClaims{
+		FormID:        formID,
+		FormVersionID: versionID,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:       hex.EncodeToString(id),
+			Issuer:   attemptTokenIssuer,
+			IssuedAt: jwt.NewNumericDate(startedAt),
+		},
+	}
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(attemptSecret())
+	if err != nil {
+		return nil, errors.New("failed to sign attempt token")
+	}
+
+	attempt := &Attempt{Token: token, StartedAt: startedAt, TimeLimit: settings.TimeLimit}
+	if settings.TimeLimit > 0 {
+		deadline := startedAt.Add(time.Duration(settings.TimeLimit) * time.Second)
+		attempt.Deadline = &deadline
+	}
+	return attempt, nil
+}
+
+// verifyAttemptToken 校验答题令牌，并按服务器时间计算答题用时
+// 超过限时加宽限时间的提交，按 exam.late_policy 拒绝或标记为超时
+func verifyAttemptToken(tokenString string, formID uint, settings question.Settings, now time.Time) (*attemptTiming, error) {
+	claims, err := parseAttemptToken(tokenString, formID)
+	if err != nil {
+		return nil, err
+	}
+
+	elapsed := now.Sub(claims.IssuedAt.Time)
+	timing := &attemptTiming{
+		AttemptID:       claims.ID,
+		DurationSeconds: uint(elapsed / time.Second),
+		FormVersionID:   claims.FormVersionID,
+	}
+	if settings.TimeLimit > 0 {
+		grace := defaultGracePeriod
+		if config.Cfg.Exam.GracePeriodSeconds > 0 {
+			grace = time.Duration(config.Cfg.Exam.GracePeriodSeconds) * time.Second
+		}
+		if elapsed > time.Duration(settings.TimeLimit)*time.Second+grace {
+			if config.Cfg.Exam.LatePolicy != LatePolicyFlag {
+				return nil, errors.New("time limit exceeded")
+			}
+			timing.Overtime = true
+		}
+	}
+	return timing, nil
+}
+
+// attemptsPerMinute 返回同一 IP 每分钟在同一表单上最多开始答题的次数
+func attemptsPerMinute() int {
+	if config.Cfg.Exam.AttemptsPerMinute > 0 {
+		return config.Cfg.Exam.AttemptsPerMinute
+	}
+	return defaultAttemptsPerMinute
+}
+
+// parseAttemptToken 校验答题令牌的签名，并确认令牌属于指定表单
+func parseAttemptToken(tokenString string, formID uint) (*AttemptClaims, error) {
+	claims := &AttemptClaims{}
+	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		return attemptSecret(), nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}), jwt.WithIssuer(attemptTokenIssuer), jwt.WithIssuedAt())
+	if err != nil || !token.Valid || claims.FormID != formID || claims.IssuedAt == nil {
+		return nil, errors.New("invalid attempt token")
+	}
+	return claims, nil
+}
+
+// attemptSeed 由答题ID生成随机抽题的种子，保证同一次答题看到的题目和顺序不变
+func attemptSeed(attemptID string) int64 {
+	h := fnv.New64a()
+	h.Write([]byte(attemptID))
+	return int64(h.Sum64())
+}
+
+// attemptSecret 返回签发答题令牌的密钥
+// 未单独配置时由 JWT 密钥派生，保证答题令牌不能被当作登录令牌使用
+func attemptSecret() []byte {
+	if config.Cfg.Exam.AttemptSecret != "" {
+		return []byte(config.Cfg.Exam.AttemptSecret)
+	}
+	return []byte(config.Cfg.JWT.Secret + ":" + attemptTokenIssuer)
+}
@@ -0,0 +1,152 @@
+// Package service 包含了应用的业务逻辑
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"questflow/internal/model"
+	"questflow/internal/question"
+	"questflow/internal/queue"
+	"questflow/internal/repository"
+	"questflow/pkg/redis"
+
+	"gorm.io/gorm"
+)
+
+// DeadLetterService 定义了管理死信提交的服务接口
+// 死信按表单归属进行权限控制，用户只能查看和处理自己表单下的死信
+type DeadLetterService interface {
+	ListDeadLetters(formID, userID uint, cursor string, count int) (*queue.DeadLetterPage, error)
+	GetDeadLetter(formID, userID uint, id string) (*queue.DeadLetter, error)
+	RedriveDeadLetter(formID, userID uint, id string) (string, error)
+	DiscardDeadLetter(formID, userID uint, id string) error
+}
+
+// deadLetterServiceImpl 是 DeadLetterService 的实现
+type deadLetterServiceImpl struct {
+	formRepo       repository.FormRepository
+	submissionRepo repository.SubmissionRepository
+	queue          queue.SubmissionQueue
+}
+
+// NewDeadLetterService 创建一个新的 DeadLetterService 实例
+func NewDeadLetterService(formRepo repository.FormRepository, submissionRepo repository.SubmissionRepository, q queue.SubmissionQueue) DeadLetterService {
+	return &deadLetterServiceImpl{formRepo: formRepo, submissionRepo: submissionRepo, queue: q}
+}
+
+// ListDeadLetters 按时间倒序分页列出某个表单下的死信，cursor 是上一页返回的 NextCursor
+func (s *deadLetterServiceImpl) ListDeadLetters(formID, userID uint, cursor string, count int) (*queue.DeadLetterPage, error) {
+	if _, err := s.checkFormOwner(formID, userID); err != nil {
+		return nil, err
+	}
+	return s.queue.ListDeadLetters(context.Background(), formID, cursor, count)
+}
+
+// GetDeadLetter 获取单条死信的详情
+func (s *deadLetterServiceImpl) GetDeadLetter(formID, userID uint, id string) (*queue.DeadLetter, error) {
+	_, msg, err := s.findDeadLetter(formID, userID, id)
+	return msg, err
+}
+
+// findDeadLetter 校验表单归属后获取死信，同时返回表单
+func (s *deadLetterServiceImpl) findDeadLetter(formID, userID uint, id string) (*model.Form, *queue.DeadLetter, error) {
+	form, err := s.checkFormOwner(formID, userID)
+	if err != nil {
+		return nil, nil, err
+	}
+	msg, err := s.queue.GetDeadLetter(context.Background(), id)
+	if err != nil {
+		return nil, nil, err
+	}
+	// 不属于该表单的死信按不存在处理，避免泄露其他表单的数据
+	if msg.FormID != formID {
+		return nil, nil, queue.ErrDeadLetterNotFound
+	}
+	return form, msg, nil
+}
+
+// RedriveDeadLetter 将死信重新发布到提交队列，并从死信队列中移除。
+// 转入死信时提交占用的配额已经归还，重新投递前按表单当前的配额设置重新占用，配额已满时拒绝重新投递。
+// 重新发布的消息有新的消息ID，原消息ID的状态改为 redriven 并指向新ID，按原ID查询状态的客户端可以继续跟踪
+func (s *deadLetterServiceImpl) RedriveDeadLetter(formID, userID uint, id string) (string, error) {
+	form, msg, err := s.findDeadLetter(formID, userID, id)
+	if err != nil {
+		return "", err
+	}
+	ctx := context.Background()
+	payload, quotaKeys, err := s.reserveQuotas(ctx, form, []byte(msg.Payload))
+	if err != nil {
+		return "", err
+	}
+	messageID, err := s.queue.Publish(ctx, payload)
+	if err != nil {
+		releaseQuotas(ctx, quotaKeys)
+		return "", errors.New("failed to publish submission message to queue")
+	}
+	if err := s.queue.DeleteDeadLetter(context.Background(), id); err != nil {
+		return "", err
+	}
+	if err := redis.SetQueuedSubmissionStatus(context.Background(), messageID); err != nil {
+		log.Printf("Failed to record status for message %s: %v", messageID, err)
+	}
+	redriven := redis.SubmissionStatus{MessageID: msg.OriginalID, Status: redis.SubmissionStatusRedriven, RedrivenTo: messageID}
+	if err := redis.SetSubmissionStatuses(context.Background(), redriven); err != nil {
+		log.Printf("Failed to record status for message %s: %v", msg.OriginalID, err)
+	}
+	return messageID, nil
+}
+
+// reserveQuotas 为重新投递的提交占用配额，返回记录了新占用的计数器的消息内容。
+// 无法解析的消息没有答案可以匹配配额，按原样重新投递
+func (s *deadLetterServiceImpl) reserveQuotas(ctx context.Context, form *model.Form, payload []byte) ([]byte, []string, error) {
+	var subMsg SubmissionMessage
+	if err := json.Unmarshal(payload, &subMsg); err != nil {
+		return payload, nil, nil
+	}
+	def, err := question.ParseDefinition(form.Definition)
+	if err != nil {
+		return nil, nil, errors.New("failed to parse form definition")
+	}
+	counters := quotaCounters(form.ID, def, subMsg.Data)
+	if len(counters) == 0 && len(subMsg.QuotaKeys) == 0 {
+		return payload, nil, nil
+	}
+	if err := reserveQuotas(ctx, s.submissionRepo, form.ID, counters); err != nil {
+		return nil, nil, err
+	}
+	subMsg.QuotaKeys = nil
+	for _, c := range counters {
+		subMsg.QuotaKeys = append(subMsg.QuotaKeys, c.key)
+	}
+	updated, err := json.Marshal(subMsg)
+	if err != nil {
+		releaseQuotas(ctx, subMsg.QuotaKeys)
+		return nil, nil, errors.New("failed to serialize submission message")
+	}
+	return updated, subMsg.QuotaKeys, nil
+}
+
+// DiscardDeadLetter 永久丢弃一条死信
+func (s *deadLetterServiceImpl) DiscardDeadLetter(formID, userID uint, id string) error {
+	if _, err := s.GetDeadLetter(formID, userID, id); err != nil {
+		return err
+	}
+	return s.queue.DeleteDeadLetter(context.Background(), id)
+}
+
+// checkFormOwner 校验表单存在且属于当前用户
+func (s *deadLetterServiceImpl) checkFormOwner(formID, userID uint) (*model.Form, error) {
+	form, err := s.formRepo.FindByID(formID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("form not found")
+		}
+		return nil, err
+	}
+	if form.CreatorID != userID {
+		return nil, errors.New("access denied")
+	}
+	return form, nil
+}
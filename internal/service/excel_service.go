@@ -6,13 +6,15 @@ import (
 	"encoding/json"
 	"fmt"
 	"questflow/internal/model"
+	"questflow/internal/question"
+	"time"
 
 	"github.com/xuri/excelize/v2"
 )
 
 // ExcelService 定义了导出 Excel 服务的接口
 type ExcelService interface {
-	ExportSubmissionsToExcel(formDef *formDefinition, submissions []model.Submission) (*bytes.Buffer, error)
+	ExportSubmissionsToExcel(formDef *question.Definition, submissions []model.Submission, versionDefs map[uint]*question.Definition, files map[string]model.UploadedFile) (*bytes.Buffer, error)
 }
 
 // excelServiceImpl 是 ExcelService 的实现
@@ -24,7 +26,9 @@ func NewExcelService() ExcelService {
 }
 
 // ExportSubmissionsToExcel 将提交数据导出为 Excel 文件流
-func (s *excelServiceImpl) ExportSubmissionsToExcel(formDef *formDefinition, submissions []model.Submission) (*bytes.Buffer, error) {
+// formDef 决定表头中的问题；versionDefs 是提交所属版本的定义，用于格式化该提交的答案，缺少对应版本时使用 formDef；
+// files 是文件上传题引用的文件，按令牌索引，用于在单元格中写入文件名和下载链接
+func (s *excelServiceImpl) ExportSubmissionsToExcel(formDef *question.Definition, submissions []model.Submission, versionDefs map[uint]*question.Definition, files map[string]model.UploadedFile) (*bytes.Buffer, error) {
 	f := excelize.NewFile()
 	defer f.Close()
 
@@ -36,48 +40,175 @@ func (s *excelServiceImpl) ExportSubmissionsToExcel(formDef *formDefinition, sub
 
 	// --- 1. 构建并写入表头 ---
 	headers := []string{"提交序号", "提交时间"}
-	// questionID 到 Excel 列字母的映射，例如: {"q1": "C", "q2": "D"}
-	questionIDToCol := make(map[string]string)
+	// questionID 到 Excel 列字母的映射，每个问题按列的 Key 映射到一列，例如: {"q1": {"": "C"}, "q2": {"r1": "D", "r2": "E"}}
+	questionIDToCols := make(map[string]map[string]string)
 
-	for i, q := range formDef.Questions {
-		headers = append(headers, q.Title)
-		// Excel 列从 A 开始，前两列是固定的，所以题目从第3列（即索引2）开始
-		colName, _ := excelize.ColumnNumberToName(i + 3)
-		questionIDToCol[q.ID] = colName
+	// Excel 列从 A 开始，前两列是固定的，所以题目从第3列开始
+	col := 3
+	columnsOf := questionExportColumns(formDef, versionDefs)
+	for i := range formDef.Questions {
+		q := &formDef.Questions[i]
+		cols := make(map[string]string)
+		for _, column := range columnsOf[q.ID] {
+			headers = append(headers, column.Title)
+			colName, _ := excelize.ColumnNumberToName(col)
+			cols[column.Key] = colName
+			col++
+		}
+		questionIDToCols[q.ID] = cols
+	}
+	// 隐藏字段在题目之后各占一列
+	hiddenFieldCols := make(map[string]string, len(formDef.HiddenFields))
+	for _, field := range formDef.HiddenFields {
+		headers = append(headers, field.DisplayTitle())
+		colName, _ := excelize.ColumnNumberToName(col)
+		hiddenFieldCols[field.Name] = colName
+		col++
+	}
+	// markQuestion 在问题占用的所有列中写入同一个标记
+	markQuestion := func(qID string, rowNum int, text string) {
+		for _, colName := range questionIDToCols[qID] {
+			f.SetCellValue(sheetName, fmt.Sprintf("%s%d", colName, rowNum), text)
+		}
+	}
+
+	// 日期题和时间题写入真正的日期、时间单元格，使用固定的显示格式
+	dateFormat, timeFormat := "yyyy-mm-dd", "hh:mm:ss"
+	dateStyle, err := f.NewStyle(&excelize.Style{CustomNumFmt: &dateFormat})
+	if err != nil {
+		return nil, err
+	}
+	timeStyle, err := f.NewStyle(&excelize.Style{CustomNumFmt: &timeFormat})
+	if err != nil {
+		return nil, err
+	}
+
+	// 有已判分的提交时，在题目之后追加得分和总分两列
+	graded := false
+	for _, sub := range submissions {
+		if sub.RawScore != nil {
+			graded = true
+			break
+		}
+	}
+	scoreCol, _ := excelize.ColumnNumberToName(col)
+	maxScoreCol, _ := excelize.ColumnNumberToName(col + 1)
+	if graded {
+		headers = append(headers, "得分", "总分")
+	}
+
+	// 分页表单在题目表头上方增加一行页面（分节）标题，同一页的题目合并为一个单元格
+	headerRows := 1
+	if formDef.Paged() {
+		headerRows = 2
+		if err := writePageHeaders(f, sheetName, formDef, columnsOf, headers); err != nil {
+			return nil, err
+		}
 	}
 
 	// 写入表头
-	if err := f.SetSheetRow(sheetName, "A1", &headers); err != nil {
+	if err := f.SetSheetRow(sheetName, fmt.Sprintf("A%d", headerRows), &headers); err != nil {
 		return nil, err
 	}
+	lastCol, _ := excelize.ColumnNumberToName(len(headers))
 
 	// --- 2. 遍历提交数据并写入每一行 ---
 	for i, sub := range submissions {
-		rowNum := i + 2 // 数据从第二行开始
+		rowNum := i + headerRows + 1 // 数据从表头的下一行开始
 		// a. 写入固定列：提交序号和提交时间
 		f.SetCellValue(sheetName, fmt.Sprintf("A%d", rowNum), i+1)
 		f.SetCellValue(sheetName, fmt.Sprintf("B%d", rowNum), sub.CreatedAt.Format("2006-01-02 15:04:05"))
+		if sub.RawScore != nil {
+			f.SetCellValue(sheetName, fmt.Sprintf("%s%d", scoreCol, rowNum), *sub.RawScore)
+		}
+		if sub.MaxScore != nil {
+			f.SetCellValue(sheetName, fmt.Sprintf("%s%d", maxScoreCol, rowNum), *sub.MaxScore)
+		}
+		var hiddenValues map[string]string
+		if len(sub.HiddenFields) > 0 && json.Unmarshal(sub.HiddenFields, &hiddenValues) == nil {
+			for name, value := range hiddenValues {
+				if colName, ok := hiddenFieldCols[name]; ok {
+					f.SetCellValue(sheetName, fmt.Sprintf("%s%d", colName, rowNum), value)
+				}
+			}
+		}
 
 		// b. 解析答案并写入对应的题目列
-		var answers map[string]interface{}
+		var answers map[string]json.RawMessage
 		// 在 GORM 中，datatypes.JSON 实际上是 []byte 类型
 		if err := json.Unmarshal(sub.Data, &answers); err != nil {
 			// 如果解析失败，跳过此条记录的答案部分
 			continue
 		}
 
+		rowDef := formDef
+		if sub.FormVersionID != nil {
+			if versionDef, ok := versionDefs[*sub.FormVersionID]; ok {
+				rowDef = versionDef
+			}
+		}
+
+		// 随机抽题时，没有抽到的问题标记为"未抽到"，与未作答区分开
+		if servedIDs := sub.ServedQuestionIDs(); servedIDs != nil {
+			served := make(map[string]bool, len(servedIDs))
+			for _, qID := range servedIDs {
+				served[qID] = true
+			}
+			for _, q := range rowDef.Questions {
+				if !served[q.ID] {
+					markQuestion(q.ID, rowNum, "未抽到")
+				}
+			}
+		}
+
+		// 抽到了但被条件逻辑隐藏的问题标记为"未显示"
+		if rowDef.HasLogic() {
+			served := servedDefinition(rowDef, &sub)
+			visible := served.Visible(answers)
+			for _, q := range served.Questions {
+				if !visible[q.ID] {
+					markQuestion(q.ID, rowNum, "未显示")
+				}
+			}
+		}
+
 		for qID, ans := range answers {
-			colName, ok := questionIDToCol[qID]
+			cols, ok := questionIDToCols[qID]
 			if !ok {
 				continue // 如果答案中的 qID 在表单定义中找不到，则跳过
 			}
 
-			cell := fmt.Sprintf("%s%d", colName, rowNum)
-
 			// c. 根据答案类型进行格式化
 			// 在这里，我们需要将选项ID转换为可读的文本
-			answerText := formatAnswer(ans, qID, formDef)
-			f.SetCellValue(sheetName, cell, answerText)
+			for key, value := range formatAnswer(ans, qID, rowDef) {
+				colName, ok := cols[key]
+				if !ok {
+					continue
+				}
+				cell := fmt.Sprintf("%s%d", colName, rowNum)
+				// 上传的文件显示文件名，并链接到需要登录的下载接口
+				if ref, ok := value.(question.FileRef); ok {
+					file, found := files[ref.Token]
+					if !found {
+						f.SetCellValue(sheetName, cell, "文件不存在")
+						continue
+					}
+					f.SetCellValue(sheetName, cell, file.Name)
+					f.SetCellHyperLink(sheetName, cell, fileDownloadURL(sub.FormID, ref.Token), "External")
+					continue
+				}
+				switch v := value.(type) {
+				case time.Time:
+					f.SetCellValue(sheetName, cell, v)
+					f.SetCellStyle(sheetName, cell, cell, dateStyle)
+				case question.TimeOfDay:
+					// Excel 中的时间是一天的小数部分
+					f.SetCellFloat(sheetName, cell, time.Duration(v).Hours()/24, -1, 64)
+					f.SetCellStyle(sheetName, cell, cell, timeStyle)
+				default:
+					f.SetCellValue(sheetName, cell, value)
+				}
+			}
 		}
 	}
 
@@ -88,7 +219,7 @@ func (s *excelServiceImpl) ExportSubmissionsToExcel(formDef *formDefinition, sub
 		Fill:      excelize.Fill{Type: "pattern", Color: []string{"#E0E0E0"}, Pattern: 1},
 		Alignment: &excelize.Alignment{Horizontal: "center"},
 	})
-	f.SetCellStyle(sheetName, "A1", fmt.Sprintf("%s1", headers[len(headers)-1]), headerStyle)
+	f.SetCellStyle(sheetName, "A1", fmt.Sprintf("%s%d", lastCol, headerRows), headerStyle)
 
 	// b. 自动调整列宽
 	cols, _ := f.GetCols(sheetName)
@@ -120,55 +251,106 @@ func (s *excelServiceImpl) ExportSubmissionsToExcel(formDef *formDefinition, sub
 	return buffer, nil
 }
 
-// formatAnswer 是一个辅助函数，用于将不同类型的答案格式化为可读的字符串
-func formatAnswer(ans interface{}, qID string, formDef *formDefinition) string {
-	var question *questionDefinition
-	for i := range formDef.Questions {
-		if formDef.Questions[i].ID == qID {
-			question = &formDef.Questions[i]
-			break
+// writePageHeaders 在第一行写入页面标题：同一页的连续题目列合并为一个单元格，
+// 不属于任何页面的题目留空，固定列、隐藏字段列以及得分列与第二行纵向合并后显示列名
+func writePageHeaders(f *excelize.File, sheetName string, formDef *question.Definition, columnsOf map[string][]question.ExportColumn, headers []string) error {
+	col := 3 // 题目从第 3 列开始
+	questions := formDef.Questions
+	for i := 0; i < len(questions); {
+		page := formDef.PageOf(questions[i].ID)
+		start := col
+		j := i
+		for j < len(questions) && formDef.PageOf(questions[j].ID) == page {
+			col += len(columnsOf[questions[j].ID])
+			j++
 		}
+		if page != nil {
+			startName, _ := excelize.ColumnNumberToName(start)
+			endName, _ := excelize.ColumnNumberToName(col - 1)
+			f.SetCellValue(sheetName, startName+"1", page.Title)
+			if col-1 > start {
+				if err := f.MergeCell(sheetName, startName+"1", endName+"1"); err != nil {
+					return err
+				}
+			}
+		}
+		i = j
 	}
 
-	if question == nil {
-		return "未知问题"
-	}
-
-	// 创建选项ID到文本的映射以便快速查找
-	optionMap := make(map[string]string)
-	for _, opt := range question.Options {
-		optionMap[opt.ID] = opt.Text
+	for c := 1; c <= len(headers); c++ {
+		if c >= 3 && c < col {
+			continue
+		}
+		colName, _ := excelize.ColumnNumberToName(c)
+		f.SetCellValue(sheetName, colName+"1", headers[c-1])
+		if err := f.MergeCell(sheetName, colName+"1", colName+"2"); err != nil {
+			return err
+		}
 	}
+	return nil
+}
 
-	switch question.Type {
-	case "single_choice", "judgment":
-		if optID, ok := ans.(string); ok {
-			if text, exists := optionMap[optID]; exists {
-				return text
+// questionExportColumns 返回每个问题在导出文件中占用的列：先是 formDef 中该问题的列，
+// 再按版本从新到旧追加只在旧版本中出现过的列（例如旧版本矩阵题中已删除的行、排序题中更多的名次），
+// 在这些版本上作答的提交的答案不会因为当前定义中没有对应的列而丢失
+func questionExportColumns(formDef *question.Definition, versionDefs map[uint]*question.Definition) map[string][]question.ExportColumn {
+	columnsOf := make(map[string][]question.ExportColumn, len(formDef.Questions))
+	versionIDs := newestVersionsFirst(versionDefs)
+	for i := range formDef.Questions {
+		q := &formDef.Questions[i]
+		columns := exportColumns(q)
+		keys := make(map[string]bool, len(columns))
+		for _, column := range columns {
+			keys[column.Key] = true
+		}
+		for _, id := range versionIDs {
+			old := versionDefs[id].QuestionByID(q.ID)
+			if old == nil {
+				continue
 			}
-			return optID // 回退显示ID
-		}
-	case "multi_choice":
-		if optIDs, ok := ans.([]interface{}); ok {
-			var result string
-			for i, optIDRaw := range optIDs {
-				if optID, ok := optIDRaw.(string); ok {
-					if text, exists := optionMap[optID]; exists {
-						if i > 0 {
-							result += ", "
-						}
-						result += text
-					}
+			for _, column := range exportColumns(old) {
+				if !keys[column.Key] {
+					keys[column.Key] = true
+					columns = append(columns, column)
 				}
 			}
-			return result
 		}
-	case "text_input":
-		if text, ok := ans.(string); ok {
-			return text
+		columnsOf[q.ID] = columns
+	}
+	return columnsOf
+}
+
+// exportColumns 返回问题在导出文件中占用的列；大多数题型只占一列，Key 为空
+func exportColumns(q *question.Question) []question.ExportColumn {
+	if qt, ok := question.Lookup(q.Type); ok {
+		if exporter, ok := qt.(question.MultiColumnExporter); ok {
+			columns := exporter.ExportColumns(q)
+			for i := range columns {
+				if columns[i].Title == "" {
+					columns[i].Title = q.Title
+					continue
+				}
+				columns[i].Title = q.Title + " - " + columns[i].Title
+			}
+			return columns
 		}
 	}
+	return []question.ExportColumn{{Title: q.Title}}
+}
 
-	// 如果所有类型都不匹配，则返回原始值的字符串表示
-	return fmt.Sprintf("%v", ans)
+// formatAnswer 是一个辅助函数，通过题型注册表将答案格式化为单元格的值，
+// 返回列的 Key（见 exportColumns）到单元格值的映射
+func formatAnswer(ans json.RawMessage, qID string, formDef *question.Definition) map[string]interface{} {
+	q := formDef.QuestionByID(qID)
+	if q == nil {
+		return map[string]interface{}{"": "未知问题"}
+	}
+	if qt, ok := question.Lookup(q.Type); ok {
+		if exporter, ok := qt.(question.MultiColumnExporter); ok {
+			return exporter.FormatColumns(q, ans)
+		}
+		return map[string]interface{}{"": qt.FormatAnswer(q, ans)}
+	}
+	// 未注册的题型直接返回原始值的字符串表示
+	return map[string]interface{}{"": string(ans)}
 }
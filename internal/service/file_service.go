@@ -0,0 +1,429 @@
+// Package service 包含了应用的业务逻辑
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime/multipart"
+	"path/filepath"
+	"questflow/internal/model"
+	"questflow/internal/question"
+	"questflow/internal/repository"
+	"questflow/internal/storage"
+	"questflow/pkg/config"
+	"questflow/pkg/redis"
+	"strings"
+	"time"
+
+	"github.com/gabriel-vasile/mimetype"
+	"gorm.io/gorm"
+)
+
+// defaultMaxUploadSizeMB 是未配置 storage.max_file_size_mb 时单个文件大小的全局上限
+const defaultMaxUploadSizeMB = 20
+
+// defaultUnusedFileTTLHours 是未配置 storage.unused_file_ttl_hours 时未使用文件的保留时间
+const defaultUnusedFileTTLHours = 24
+
+// defaultUploadsPerMinute 是未配置 storage.uploads_per_minute 时同一 IP 每分钟在同一表单上最多发起的上传请求数
+const defaultUploadsPerMinute = 30
+
+// defaultFormUploadMBPerHour 是未配置 storage.form_upload_mb_per_hour 时每个表单每小时最多接收的上传总大小
+const defaultFormUploadMBPerHour = 1024
+
+// uploadCleanupInterval 是清理未使用文件的间隔，uploadCleanupBatchSize 是每次最多清理的文件数
+const (
+	uploadCleanupInterval  = time.Hour
+	uploadCleanupBatchSize = 500
+)
+
+// UploadedFileInfo 是预上传成功后返回给填写页的文件信息，提交时在答案中使用 Token
+type UploadedFileInfo struct {
+	Token       string `json:"token"`
+	Name        string `json:"name"`
+	ContentType string `json:"content_type"`
+	Size        int64  `json:"size"`
+}
+
+// FileService 定义了文件上传题的文件上传和下载服务
+type FileService interface {
+	UploadFiles(form *model.Form, questionID, clientIP string, files []*multipart.FileHeader) ([]UploadedFileInfo, error)
+	OpenFile(formID, userID uint, token string) (io.ReadCloser, *model.UploadedFile, error)
+}
+
+// fileServiceImpl 是 FileService 的实现
+type fileServiceImpl struct {
+	formRepo repository.FormRepository
+	fileRepo repository.UploadedFileRepository
+	storage  storage.Storage
+}
+
+// NewFileService 创建一个新的 FileService 实例
+func NewFileService(formRepo repository.FormRepository, fileRepo repository.UploadedFileRepository, store storage.Storage) FileService {
+	return &fileServiceImpl{formRepo: formRepo, fileRepo: fileRepo, storage: store}
+}
+
+// MaxUploadSize 返回单个文件大小的全局上限（字节）
+func MaxUploadSize() int64 {
+	sizeMB := config.Cfg.Storage.MaxFileSizeMB
+	if sizeMB <= 0 {
+		sizeMB = defaultMaxUploadSizeMB
+	}
+	return int64(sizeMB) << 20
+}
+
+// unusedFileTTL 返回上传后未被使用的文件的保留时间，超过后文件不能再被提交引用，并会被清理
+func unusedFileTTL() time.Duration {
+	hours := config.Cfg.Storage.UnusedFileTTLHours
+	if hours <= 0 {
+		hours = defaultUnusedFileTTLHours
+	}
+	return time.Duration(hours) * time.Hour
+}
+
+// checkUploadLimits 限制匿名上传的频率和总量：同一 IP 在同一表单上每分钟的上传请求数，
+// 以及每个表单每小时接收的文件总大小
+func checkUploadLimits(formID uint, clientIP string, size int64) error {
+	perMinute := config.Cfg.Storage.UploadsPerMinute
+	if perMinute <= 0 {
+		perMinute = defaultUploadsPerMinute
+	}
+	perHourMB := config.Cfg.Storage.FormUploadMBPerHour
+	if perHourMB <= 0 {
+		perHourMB = defaultFormUploadMBPerHour
+	}
+
+	ctx := context.Background()
+	allowed, err := redis.Allow(ctx, fmt.Sprintf("upload:%d:%s", formID, clientIP), perMinute, time.Minute)
+	if err != nil {
+		return errors.New("failed to check upload rate limit")
+	}
+	if !allowed {
+		return errors.New("too many uploads")
+	}
+	allowed, err = redis.AllowN(ctx, fmt.Sprintf("upload_bytes:%d", formID), size, int64(perHourMB)<<20, time.Hour)
+	if err != nil {
+		return errors.New("failed to check upload rate limit")
+	}
+	if !allowed {
+		return errors.New("form upload quota exceeded")
+	}
+	return nil
+}
+
+// UploadFiles 为已发布表单中的文件上传题预上传文件，返回每个文件的令牌。
+// 文件类型根据内容检测，而不是使用客户端声明的 Content-Type 或扩展名；
+// 上传频率和表单的上传总量受 checkUploadLimits 限制
+func (s *fileServiceImpl) UploadFiles(form *model.Form, questionID, clientIP string, files []*multipart.FileHeader) ([]UploadedFileInfo, error) {
+	if form.Status != 2 {
+		return nil, errors.New("form is not published")
+	}
+	def, err := question.ParseDefinition(form.Definition)
+	if err != nil {
+		return nil, errors.New("failed to parse form definition")
+	}
+	q := def.QuestionByID(questionID)
+	if q == nil {
+		return nil, errors.New("question not found")
+	}
+	if q.Type != "file_upload" {
+		return nil, errors.New("question does not accept files")
+	}
+	if len(files) == 0 {
+		return nil, errors.New("no files uploaded")
+	}
+	if len(files) > q.FileCountLimit() {
+		return nil, errors.New("too many files")
+	}
+
+	// 先检查所有文件，避免只保存了其中一部分
+	maxSize := min(q.FileSizeLimit(), MaxUploadSize())
+	contentTypes := make([]string, len(files))
+	for i, fh := range files {
+		if fh.Size > maxSize {
+			return nil, errors.New("file too large")
+		}
+		mtype, err := detectContentType(fh)
+		if err != nil {
+			return nil, err
+		}
+		var types []string
+		for m := mtype; m != nil; m = m.Parent() {
+			types = append(types, m.String())
+		}
+		if !q.AcceptsContentType(types...) {
+			return nil, errors.New("file type not allowed")
+		}
+		contentTypes[i] = mtype.String()
+	}
+	var total int64
+	for _, fh := range files {
+		total += fh.Size
+	}
+	if err := checkUploadLimits(form.ID, clientIP, total); err != nil {
+		return nil, err
+	}
+
+	infos := make([]UploadedFileInfo, 0, len(files))
+	for i, fh := range files {
+		file, err := s.saveFile(form.ID, questionID, fh, contentTypes[i])
+		if err != nil {
+			return nil, err
+		}
+		infos = append(infos, UploadedFileInfo{
+			Token:       file.Token,
+			Name:        file.Name,
+			ContentType: file.ContentType,
+			Size:        file.Size,
+		})
+	}
+	return infos, nil
+}
+
+// saveFile 把文件写入存储并保存上传记录，记录保存失败时删除已写入的文件
+func (s *fileServiceImpl) saveFile(formID uint, questionID string, fh *multipart.FileHeader, contentType string) (*model.UploadedFile, error) {
+	token, err := newFileToken()
+	if err != nil {
+		return nil, err
+	}
+	file := &model.UploadedFile{
+		Token:       token,
+		FormID:      formID,
+		QuestionID:  questionID,
+		Name:        sanitizeFileName(fh.Filename),
+		ContentType: contentType,
+		Size:        fh.Size,
+		StorageKey:  fmt.Sprintf("forms/%d/%s", formID, token),
+	}
+
+	src, err := fh.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer src.Close()
+
+	ctx := context.Background()
+	if err := s.storage.Put(ctx, file.StorageKey, src, file.Size, file.ContentType); err != nil {
+		return nil, errors.New("failed to store file")
+	}
+	if err := s.fileRepo.Create(file); err != nil {
+		if delErr := s.storage.Delete(ctx, file.StorageKey); delErr != nil {
+			log.Printf("Failed to delete orphaned file %s: %v", file.StorageKey, delErr)
+		}
+		return nil, err
+	}
+	return file, nil
+}
+
+// OpenFile 打开表单中的一个上传文件供创建者下载，调用方负责关闭返回的 ReadCloser
+func (s *fileServiceImpl) OpenFile(formID, userID uint, token string) (io.ReadCloser, *model.UploadedFile, error) {
+	form, err := s.formRepo.FindByID(formID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil, errors.New("form not found")
+		}
+		return nil, nil, err
+	}
+	if form.CreatorID != userID {
+		return nil, nil, errors.New("access denied")
+	}
+
+	file, err := s.fileRepo.FindByToken(token)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil, errors.New("uploaded file not found")
+		}
+		return nil, nil, err
+	}
+	if file.FormID != formID {
+		return nil, nil, errors.New("uploaded file not found")
+	}
+
+	reader, err := s.storage.Get(context.Background(), file.StorageKey)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return nil, nil, errors.New("uploaded file not found")
+		}
+		return nil, nil, err
+	}
+	return reader, file, nil
+}
+
+// StartUploadCleanup 启动一个 Goroutine，定期删除超过保留时间仍未被任何提交使用的上传文件，直到 ctx 被取消。
+// 多个 API 实例同时清理是安全的：先删除数据库记录，删除成功的实例再删除存储中的文件
+func StartUploadCleanup(ctx context.Context, fileRepo repository.UploadedFileRepository, store storage.Storage) {
+	go func() {
+		ticker := time.NewTicker(uploadCleanupInterval)
+		defer ticker.Stop()
+		for {
+			cleanupUnusedUploads(ctx, fileRepo, store)
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// cleanupUnusedUploads 删除一批过期的未使用文件。数据库记录删除后文件不能再被提交绑定，
+// 因此不会删除已被提交引用的文件
+func cleanupUnusedUploads(ctx context.Context, fileRepo repository.UploadedFileRepository, store storage.Storage) {
+	files, err := fileRepo.FindUnusedBefore(time.Now().Add(-unusedFileTTL()), uploadCleanupBatchSize)
+	if err != nil {
+		log.Printf("Failed to find unused uploaded files: %v", err)
+		return
+	}
+	removed := 0
+	for _, file := range files {
+		deleted, err := fileRepo.DeleteIfUnused(file.ID)
+		if err != nil {
+			log.Printf("Failed to delete unused uploaded file %s: %v", file.Token, err)
+			continue
+		}
+		if !deleted {
+			continue // 已被提交绑定或已被其他实例删除
+		}
+		if err := store.Delete(ctx, file.StorageKey); err != nil {
+			log.Printf("Failed to delete orphaned file %s: %v", file.StorageKey, err)
+			continue
+		}
+		removed++
+	}
+	if removed > 0 {
+		log.Printf("Removed %d unused uploaded files", removed)
+	}
+}
+
+// validateFileTokens 检查答案中的文件令牌都是预上传到该表单对应问题、尚未被使用也没有过期的文件，
+// 返回去重后的所有文件令牌，提交写入数据库时这些文件会绑定到该提交
+func validateFileTokens(fileRepo repository.UploadedFileRepository, formID uint, def *question.Definition, data []byte) ([]string, error) {
+	answerTokens := make(map[string][]string)
+	var all []string
+	seen := make(map[string]bool)
+	for qID, raw := range fileAnswers(def, data) {
+		tokens, err := question.FileTokens(raw)
+		if err != nil {
+			continue // 格式错误已经在 ValidateSubmissionData 中报告
+		}
+		answerTokens[qID] = tokens
+		for _, token := range tokens {
+			if !seen[token] {
+				seen[token] = true
+				all = append(all, token)
+			}
+		}
+	}
+	if len(all) == 0 {
+		return nil, nil
+	}
+
+	files, err := fileRepo.FindByTokens(all)
+	if err != nil {
+		return nil, err
+	}
+	uploaded := make(map[string]model.UploadedFile, len(files))
+	for _, f := range files {
+		uploaded[f.Token] = f
+	}
+
+	expiredBefore := time.Now().Add(-unusedFileTTL())
+	var errs []QuestionError
+	for i := range def.Questions {
+		qID := def.Questions[i].ID
+		for _, token := range answerTokens[qID] {
+			f, ok := uploaded[token]
+			if !ok || f.FormID != formID || f.QuestionID != qID || f.CreatedAt.Before(expiredBefore) {
+				errs = append(errs, QuestionError{QuestionID: qID, Message: fmt.Sprintf("file %q not found", token)})
+				break
+			}
+			if f.SubmissionID != nil {
+				errs = append(errs, QuestionError{QuestionID: qID, Message: fmt.Sprintf("file %q has already been submitted", token)})
+				break
+			}
+		}
+	}
+	if len(errs) > 0 {
+		return nil, &ValidationError{Errors: errs}
+	}
+	return all, nil
+}
+
+// uploadedFilesForExport 查找导出的提交中引用到的所有上传文件，按令牌索引
+func uploadedFilesForExport(fileRepo repository.UploadedFileRepository, def *question.Definition, submissions []model.Submission) (map[string]model.UploadedFile, error) {
+	var all []string
+	for i := range submissions {
+		for _, raw := range fileAnswers(def, submissions[i].Data) {
+			if tokens, err := question.FileTokens(raw); err == nil {
+				all = append(all, tokens...)
+			}
+		}
+	}
+	files, err := fileRepo.FindByTokens(all)
+	if err != nil {
+		return nil, err
+	}
+	byToken := make(map[string]model.UploadedFile, len(files))
+	for _, f := range files {
+		byToken[f.Token] = f
+	}
+	return byToken, nil
+}
+
+// fileAnswers 返回答案中所有文件上传题的非空答案
+func fileAnswers(def *question.Definition, data []byte) map[string]json.RawMessage {
+	var answers map[string]json.RawMessage
+	if err := json.Unmarshal(data, &answers); err != nil {
+		return nil
+	}
+	result := make(map[string]json.RawMessage)
+	for qID, raw := range answers {
+		if q := def.QuestionByID(qID); q != nil && q.Type == "file_upload" && !question.IsEmptyAnswer(raw) {
+			result[qID] = raw
+		}
+	}
+	return result
+}
+
+// detectContentType 读取文件开头的内容检测 MIME 类型
+func detectContentType(fh *multipart.FileHeader) (*mimetype.MIME, error) {
+	f, err := fh.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return mimetype.DetectReader(f)
+}
+
+// newFileToken 生成一个随机的文件令牌
+func newFileToken() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// sanitizeFileName 去掉文件名中的路径部分，并限制在数据库字段长度以内
+func sanitizeFileName(name string) string {
+	name = filepath.Base(strings.ReplaceAll(name, "\\", "/"))
+	if name == "." || name == "/" {
+		name = "file"
+	}
+	if runes := []rune(name); len(runes) > 255 {
+		name = string(runes[:255])
+	}
+	return name
+}
+
+// fileDownloadURL 返回上传文件的下载地址，配置了 storage.download_base_url 时为绝对地址
+func fileDownloadURL(formID uint, token string) string {
+	base := strings.TrimSuffix(config.Cfg.Storage.DownloadBaseURL, "/")
+	return fmt.Sprintf("%s/api/v1/forms/%d/files/%s", base, formID, token)
+}
@@ -5,57 +5,99 @@ import (
 	"bytes"
 	"encoding/json"
 	"errors"
+	"net/url"
 	"questflow/internal/model"
+	"questflow/internal/question"
 	"questflow/internal/repository"
+	"sort"
 	"time"
 
 	"gorm.io/datatypes"
 	"gorm.io/gorm"
 )
 
+// DefinitionError 表示创建或修改表单时提交的定义无效
+type DefinitionError struct {
+	Err error
+}
+
+// Error 实现 error 接口
+func (e *DefinitionError) Error() string {
+	return "invalid form definition: " + e.Err.Error()
+}
+
+// validateDefinition 解析并检查将要保存的表单定义
+func validateDefinition(definition datatypes.JSON) error {
+	def, err := question.ParseDefinition(definition)
+	if err != nil {
+		return &DefinitionError{Err: err}
+	}
+	if err := def.Validate(); err != nil {
+		return &DefinitionError{Err: err}
+	}
+	return nil
+}
+
 // --- 为统计结果定义清晰的结构体 ---
 
 // OptionStat 存储单个选项的统计
-type OptionStat struct {
-	Text  string `json:"text"`
-	Count int    `json:"count"`
+type OptionStat = question.OptionStat
+
+// QuestionStat 存储单个问题的统计结果，具体字段由各题型的聚合器填充
+type QuestionStat = question.QuestionStat
+
+// ScoreStats 存储考试类表单的成绩统计，只统计已判分的提交
+type ScoreStats struct {
+	GradedCount  int     `json:"graded_count"`
+	MaxScore     int     `json:"max_score"` // 试卷总分
+	AverageScore float64 `json:"average_score"`
+	HighestScore int     `json:"highest_score"`
+	LowestScore  int     `json:"lowest_score"`
 }
 
-// QuestionStat 存储单个问题的统计结果
-type QuestionStat struct {
-	QuestionID   string       `json:"question_id"`
-	QuestionType string       `json:"question_type"`
-	Title        string       `json:"title"`
-	OptionStats  []OptionStat `json:"option_stats,omitempty"` // 用于选择题
-	TextAnswers  []string     `json:"text_answers,omitempty"` // 用于填空题
+// PageStat 是分页表单中单页的统计，问题的统计结果仍在 QuestionStats 中，通过 QuestionIDs 分组
+type PageStat struct {
+	PageID      string   `json:"page_id"`
+	Title       string   `json:"title"`
+	QuestionIDs []string `json:"question_ids"`
+	ShownCount  int      `json:"shown_count"` // 看到该页（至少看到其中一道题）的提交数
 }
 
-// FormStats 最终返回给前端的完整统计数据结构
-type FormStats struct {
-	TotalSubmissions int            `json:"total_submissions"`
-	QuestionStats    []QuestionStat `json:"question_stats"`
+// HiddenFieldStat 是单个隐藏字段的取值分布，按出现次数从多到少排列
+type HiddenFieldStat struct {
+	Name   string       `json:"name"`
+	Title  string       `json:"title"`
+	Values []OptionStat `json:"values"`
 }
 
-// --- 表单定义解析用的临时结构体 ---
-type questionDefinition struct {
-	ID      string `json:"id"`
-	Type    string `json:"type"`
-	Title   string `json:"title"`
-	Options []struct {
-		ID   string `json:"id"`
-		Text string `json:"text"`
-	} `json:"options"`
+// Prefill 是根据 URL 查询参数得到的隐藏字段值和预填答案，填写页提交时原样带回隐藏字段
+type Prefill struct {
+	HiddenFields map[string]string          `json:"hidden_fields"`
+	Answers      map[string]json.RawMessage `json:"answers"` // 问题ID到预填答案的映射
 }
 
-type formDefinition struct {
-	Questions []questionDefinition `json:"questions"`
+// FormStats 最终返回给前端的完整统计数据结构
+type FormStats struct {
+	TotalSubmissions int              `json:"total_submissions"`
+	QuestionStats    []QuestionStat   `json:"question_stats"`
+	Pages            []PageStat       `json:"pages,omitempty"`       // 仅分页表单返回，按页面顺序排列
+	ScoreStats       *ScoreStats      `json:"score_stats,omitempty"` // 仅在存在已判分的提交时返回
+	Version          *FormVersionInfo `json:"version,omitempty"`     // 统计所基于的表单版本，表单还没有版本时不返回
+
+	HiddenFields []HiddenFieldStat `json:"hidden_fields,omitempty"` // 仅定义了隐藏字段的表单返回
+
+	ResponseLimit *QuotaStat  `json:"response_limit,omitempty"` // 仅设置了 maxResponses 的表单返回，统计所有版本上的提交
+	Quotas        []QuotaStat `json:"quotas,omitempty"`         // 仅设置了选项配额的表单返回，统计所有版本上的提交
 }
 
 // --- 更新 Service 接口和实现 ---
 type FormService interface {
 	CreateForm(creatorID uint, title, description string, definition datatypes.JSON) (*model.Form, error)
 	GetPublicFormByKey(key string) (*model.Form, error)
-	GetFormStatistics(formID uint, userID uint) (*FormStats, error)
+	GetPublicDefinition(form *model.Form, attemptToken string, page int) (datatypes.JSON, *question.PageProgress, error)
+	GetPrefill(form *model.Form, params url.Values) (*Prefill, error)
+	GetFormStatistics(formID uint, userID uint, versionID uint) (*FormStats, error)
+	GetItemAnalysis(formID uint, userID uint, versionID uint) (*ItemAnalysis, error)
 	GetFormsByCreator(userID uint) ([]model.Form, error)
 	DeleteForm(formID uint, userID uint) error
 	GetFormForEditing(formID uint, userID uint) (*model.Form, error)
@@ -66,20 +108,27 @@ type FormService interface {
 
 type formServiceImpl struct {
 	formRepo       repository.FormRepository
+	versionRepo    repository.FormVersionRepository
 	submissionRepo repository.SubmissionRepository
+	fileRepo       repository.UploadedFileRepository
 	excelService   ExcelService
 }
 
-func NewFormService(formRepo repository.FormRepository, submissionRepo repository.SubmissionRepository) FormService {
+func NewFormService(formRepo repository.FormRepository, versionRepo repository.FormVersionRepository, submissionRepo repository.SubmissionRepository, fileRepo repository.UploadedFileRepository) FormService {
 	return &formServiceImpl{
 		formRepo:       formRepo,
+		versionRepo:    versionRepo,
 		submissionRepo: submissionRepo,
+		fileRepo:       fileRepo,
 		excelService:   NewExcelService(),
 	}
 }
 
 // CreateForm
 func (s *formServiceImpl) CreateForm(creatorID uint, title, description string, definition datatypes.JSON) (*model.Form, error) {
+	if err := validateDefinition(definition); err != nil {
+		return nil, err
+	}
 	newForm := &model.Form{
 		CreatorID:   creatorID,
 		Title:       title,
@@ -100,17 +149,58 @@ func (s *formServiceImpl) UpdateForm(formID, userID uint, title, description str
 	if err != nil {
 		return nil, err
 	}
+	if err := validateDefinition(definition); err != nil {
+		return nil, err
+	}
+
+	// 已发布的表单在修改前如果还没有版本，先把当前内容保存为第一个版本，已有的提交会归到这个版本
+	var snapshots []*model.Form
+	if form.Status == 2 && form.PublishedVersionID == nil {
+		original := *form
+		snapshots = append(snapshots, &original)
+	}
 
 	form.Title = title
 	form.Description = description
 	form.Definition = definition
 
-	if err := s.formRepo.Update(form); err != nil {
+	// 修改已发布的表单相当于重新发布，之后的提交会固定到新的版本；
+	// 版本快照和表单在同一个事务中保存
+	if form.Status == 2 {
+		if snapshots, err = s.appendSnapshot(form, snapshots); err != nil {
+			return nil, err
+		}
+	}
+	if err := s.versionRepo.UpdateWithSnapshots(form, []string{"title", "description", "definition"}, snapshots...); err != nil {
 		return nil, err
 	}
 	return form, nil
 }
 
+// appendSnapshot 在表单内容与最近发布的版本（包括 pending 中尚未保存的快照）不同时，
+// 把表单当前的内容追加为一个待保存的版本快照
+func (s *formServiceImpl) appendSnapshot(form *model.Form, pending []*model.Form) ([]*model.Form, error) {
+	var latest *model.Form
+	switch {
+	case len(pending) > 0:
+		latest = pending[len(pending)-1]
+	case form.PublishedVersionID != nil:
+		version, err := s.versionRepo.FindByID(*form.PublishedVersionID)
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, err
+		}
+		if err == nil {
+			latest = &model.Form{Title: version.Title, Description: version.Description, Definition: version.Definition}
+		}
+	}
+	if latest != nil && latest.Title == form.Title && latest.Description == form.Description &&
+		question.EqualJSON(latest.Definition, form.Definition) {
+		return pending, nil
+	}
+	snapshot := *form
+	return append(pending, &snapshot), nil
+}
+
 // UpdateFormStatus
 func (s *formServiceImpl) UpdateFormStatus(formID, userID uint, status uint8) error {
 	form, err := s.GetFormForEditing(formID, userID) // 复用权限检查逻辑
@@ -123,7 +213,13 @@ func (s *formServiceImpl) UpdateFormStatus(formID, userID uint, status uint8) er
 	}
 
 	form.Status = status
-	return s.formRepo.Update(form)
+	var snapshots []*model.Form
+	if status == 2 {
+		if snapshots, err = s.appendSnapshot(form, nil); err != nil {
+			return err
+		}
+	}
+	return s.versionRepo.UpdateWithSnapshots(form, []string{"status"}, snapshots...)
 }
 
 // GetFormForEditing
@@ -154,6 +250,75 @@ func (s *formServiceImpl) GetPublicFormByKey(key string) (*model.Form, error) {
 	return form, nil
 }
 
+// GetPrefill 根据打开表单时 URL 中的查询参数计算隐藏字段的值和问题的预填答案
+func (s *formServiceImpl) GetPrefill(form *model.Form, params url.Values) (*Prefill, error) {
+	def, err := question.ParseDefinition(form.Definition)
+	if err != nil {
+		return nil, errors.New("failed to parse form definition")
+	}
+	lookup := func(name string) (string, bool) {
+		if !params.Has(name) {
+			return "", false
+		}
+		return params.Get(name), true
+	}
+	return &Prefill{
+		HiddenFields: def.ResolveHiddenFields(lookup),
+		Answers:      def.Prefill(lookup),
+	}, nil
+}
+
+// GetPublicDefinition 返回给填写页的表单定义，其中不包含标准答案
+// 限时、随机抽题或打乱选项的表单需要答题令牌，只有开始答题后才能获取题目，
+// 超过限时的令牌按 exam.late_policy 处理；同一次答题总是得到相同的题目和顺序。
+// page 大于 0 时只返回该页的问题以及当前页的进度，供长表单逐页加载
+func (s *formServiceImpl) GetPublicDefinition(form *model.Form, attemptToken string, page int) (datatypes.JSON, *question.PageProgress, error) {
+	def, err := question.ParseDefinition(form.Definition)
+	if err != nil {
+		return nil, nil, errors.New("failed to parse form definition")
+	}
+
+	raw := form.Definition
+	var questionIDs []string
+	var optionOrder map[string][]string
+	if def.RequiresAttempt() {
+		if attemptToken == "" {
+			return nil, nil, errors.New("attempt token is required")
+		}
+		timing, err := verifyAttemptToken(attemptToken, form.ID, def.Settings, time.Now())
+		if err != nil {
+			return nil, nil, err
+		}
+		// 开始答题后表单被修改时，本次答题继续使用开始答题时发布的版本
+		version, err := attemptVersion(s.versionRepo, form, timing)
+		if err != nil {
+			return nil, nil, err
+		}
+		if version != nil {
+			if def, err = question.ParseDefinition(version.Definition); err != nil {
+				return nil, nil, errors.New("failed to parse form definition")
+			}
+			raw = version.Definition
+		}
+		if def.Randomized() {
+			questionIDs, optionOrder = def.Draw(attemptSeed(timing.AttemptID))
+		}
+	}
+	public, err := question.PublicDefinition(raw, questionIDs, optionOrder)
+	if err != nil || page == 0 {
+		return public, nil, err
+	}
+
+	selected, progress, err := question.SelectPage(public, page)
+	if err != nil {
+		if errors.Is(err, question.ErrPageOutOfRange) {
+			return nil, nil, errors.New("page out of range")
+		}
+		return nil, nil, err
+	}
+	return selected, progress, nil
+}
+
 // GetFormsByCreator 获取用户创建的表单列表
 func (s *formServiceImpl) GetFormsByCreator(userID uint) ([]model.Form, error) {
 	return s.formRepo.FindByCreatorID(userID)
@@ -189,12 +354,16 @@ func (s *formServiceImpl) ExportFormSubmissions(formID, userID uint, startTime,
 	}
 
 	// 2. 解析表单定义
-	var def formDefinition
-	if err := json.Unmarshal(form.Definition, &def); err != nil {
+	def, err := question.ParseDefinition(form.Definition)
+	if err != nil {
 		return nil, nil, errors.New("failed to parse form definition")
 	}
 
 	// 3. 根据筛选条件获取提交记录
+	conditions, err = s.resolveFilterConditions(formID, def, conditions)
+	if err != nil {
+		return nil, nil, err
+	}
 	submissions, err := s.submissionRepo.FindWithFilters(formID, startTime, endTime, conditions)
 	if err != nil {
 		return nil, nil, err
@@ -204,8 +373,28 @@ func (s *formServiceImpl) ExportFormSubmissions(formID, userID uint, startTime,
 		return nil, nil, errors.New("no submissions found for the given criteria")
 	}
 
-	// 4. 调用 ExcelService 生成文件流
-	buffer, err := s.excelService.ExportSubmissionsToExcel(&def, submissions)
+	// 4. 每条提交按作答时的版本格式化，表头包含这些版本中出现过的所有问题
+	versions := newVersionDefinitions(s.versionRepo)
+	versionDefs := make(map[uint]*question.Definition)
+	for i := range submissions {
+		versionDef, err := versions.get(&submissions[i])
+		if err != nil {
+			return nil, nil, err
+		}
+		if versionDef != nil {
+			versionDefs[*submissions[i].FormVersionID] = versionDef
+		}
+	}
+
+	// 5. 查找文件上传题引用的文件，用于生成下载链接
+	merged := mergeQuestions(def, versionDefs)
+	files, err := uploadedFilesForExport(s.fileRepo, merged, submissions)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// 6. 调用 ExcelService 生成文件流
+	buffer, err := s.excelService.ExportSubmissionsToExcel(merged, submissions, versionDefs, files)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -213,8 +402,143 @@ func (s *formServiceImpl) ExportFormSubmissions(formID, userID uint, startTime,
 	return buffer, form, nil
 }
 
-// GetFormStatistics
-func (s *formServiceImpl) GetFormStatistics(formID uint, userID uint) (*FormStats, error) {
+// resolveFilterConditions 检查按问题筛选的条件：问题必须存在于当前定义或某个历史版本中，
+// 否则返回错误；条件中的题型以定义为准，不信任请求中的 questionType
+func (s *formServiceImpl) resolveFilterConditions(formID uint, def *question.Definition, conditions []repository.FilterCondition) ([]repository.FilterCondition, error) {
+	var versionDefs []*question.Definition // 第一次需要时才加载，加载后不为 nil
+	resolved := make([]repository.FilterCondition, 0, len(conditions))
+	for _, cond := range conditions {
+		if cond.Field != "" {
+			resolved = append(resolved, cond)
+			continue
+		}
+		q := def.QuestionByID(cond.QuestionID)
+		if q == nil && versionDefs == nil {
+			versions, err := s.versionRepo.FindByFormID(formID)
+			if err != nil {
+				return nil, err
+			}
+			versionDefs = make([]*question.Definition, 0, len(versions))
+			for _, version := range versions {
+				if versionDef, err := question.ParseDefinition(version.Definition); err == nil {
+					versionDefs = append(versionDefs, versionDef)
+				}
+			}
+		}
+		for i := 0; q == nil && i < len(versionDefs); i++ {
+			q = versionDefs[i].QuestionByID(cond.QuestionID)
+		}
+		if q == nil {
+			return nil, errors.New("unknown filter question")
+		}
+		cond.QuestionType = q.Type
+		resolved = append(resolved, cond)
+	}
+	return resolved, nil
+}
+
+// mergeQuestions 以当前定义为基础，按版本从新到旧追加只在旧版本中出现过的问题和隐藏字段，
+// 用作导出的表头和跨版本统计的定义
+func mergeQuestions(current *question.Definition, versionDefs map[uint]*question.Definition) *question.Definition {
+	merged := *current
+	merged.Questions = append([]question.Question(nil), current.Questions...)
+	merged.HiddenFields = append([]question.HiddenField(nil), current.HiddenFields...)
+	for _, id := range newestVersionsFirst(versionDefs) {
+		for _, q := range versionDefs[id].Questions {
+			if merged.QuestionByID(q.ID) == nil {
+				merged.Questions = append(merged.Questions, q)
+			}
+		}
+		for _, field := range versionDefs[id].HiddenFields {
+			if merged.HiddenFieldByName(field.Name) == nil {
+				merged.HiddenFields = append(merged.HiddenFields, field)
+			}
+		}
+	}
+	return &merged
+}
+
+// newestVersionsFirst 返回版本定义的版本 ID，较新的版本在前
+func newestVersionsFirst(versionDefs map[uint]*question.Definition) []uint {
+	versionIDs := make([]uint, 0, len(versionDefs))
+	for id := range versionDefs {
+		versionIDs = append(versionIDs, id)
+	}
+	sort.Slice(versionIDs, func(i, j int) bool { return versionIDs[i] > versionIDs[j] })
+	return versionIDs
+}
+
+// statsScope 确定统计分析使用的表单定义，并筛选出在该版本上作答的提交。
+// versionID 为 0 时统计全部提交，修改表单重新发布后旧版本上的提交仍然计入统计：
+// 返回当前定义与各个版本合并后的定义用于输出结果，以及这些提交作答时的版本定义，
+// 每份提交都要按 rowDefinition 取得自己作答时的定义来统计
+func (s *formServiceImpl) statsScope(form *model.Form, versionID uint, submissions []model.Submission) (*question.Definition, map[uint]*question.Definition, *FormVersionInfo, []model.Submission, error) {
+	if versionID == 0 {
+		def, err := question.ParseDefinition(form.Definition)
+		if err != nil {
+			return nil, nil, nil, nil, errors.New("failed to parse form definition")
+		}
+		versions := newVersionDefinitions(s.versionRepo)
+		versionDefs := make(map[uint]*question.Definition)
+		for i := range submissions {
+			versionDef, err := versions.get(&submissions[i])
+			if err != nil {
+				return nil, nil, nil, nil, err
+			}
+			if versionDef != nil {
+				versionDefs[*submissions[i].FormVersionID] = versionDef
+			}
+		}
+		return mergeQuestions(def, versionDefs), versionDefs, nil, submissions, nil
+	}
+
+	version, err := s.versionRepo.FindByID(versionID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil, nil, nil, errors.New("form version not found")
+		}
+		return nil, nil, nil, nil, err
+	}
+	if version.FormID != form.ID {
+		return nil, nil, nil, nil, errors.New("form version not found")
+	}
+	def, err := question.ParseDefinition(version.Definition)
+	if err != nil {
+		return nil, nil, nil, nil, errors.New("failed to parse form definition")
+	}
+
+	scoped := make([]model.Submission, 0, len(submissions))
+	for _, sub := range submissions {
+		if sub.FormVersionID != nil && *sub.FormVersionID == version.ID {
+			scoped = append(scoped, sub)
+		}
+	}
+	info := newFormVersionInfo(form, version)
+	info.Submissions = int64(len(scoped))
+	return def, nil, &info, scoped, nil
+}
+
+// rowDefinition 返回提交作答时的版本定义，提交没有版本或版本定义不可用时返回 def
+func rowDefinition(def *question.Definition, versionDefs map[uint]*question.Definition, sub *model.Submission) *question.Definition {
+	if sub.FormVersionID != nil {
+		if versionDef, ok := versionDefs[*sub.FormVersionID]; ok {
+			return versionDef
+		}
+	}
+	return def
+}
+
+// newestDefinitionsFirst 返回统计结果使用的定义和各个版本定义，较新的在前，用于按顺序合并跨版本的聚合结果
+func newestDefinitionsFirst(def *question.Definition, versionDefs map[uint]*question.Definition) []*question.Definition {
+	defs := []*question.Definition{def}
+	for _, id := range newestVersionsFirst(versionDefs) {
+		defs = append(defs, versionDefs[id])
+	}
+	return defs
+}
+
+// GetFormStatistics 统计表单某个版本上的提交，默认统计所有版本
+func (s *formServiceImpl) GetFormStatistics(formID uint, userID uint, versionID uint) (*FormStats, error) {
 	// 1. 获取表单并进行权限验证
 	form, err := s.formRepo.FindByID(formID)
 	if err != nil {
@@ -233,106 +557,208 @@ func (s *formServiceImpl) GetFormStatistics(formID uint, userID uint) (*FormStat
 		return nil, err
 	}
 
-	// 3. 解析表单定义
-	var def formDefinition
-	if err := json.Unmarshal(form.Definition, &def); err != nil {
-		return nil, errors.New("failed to parse form definition")
+	// 3. 确定统计的版本，只统计在该版本上作答的提交；总提交数上限和配额按所有版本统计
+	allCount := len(submissions)
+	def, versionDefs, version, submissions, err := s.statsScope(form, versionID, submissions)
+	if err != nil {
+		return nil, err
 	}
 
-	questionDefMap := make(map[string]questionDefinition)
-	optionTextMap := make(map[string]string)
-	for _, q := range def.Questions {
-		questionDefMap[q.ID] = q
-		for _, opt := range q.Options {
-			optionTextMap[opt.ID] = opt.Text
+	// 4. 遍历所有提交记录，按提交作答时的版本定义（选项、随机抽题和条件逻辑）判断实际看到的问题，
+	// 把答案交给该版本上对应问题的聚合器；同时统计每道题被抽到、被看到和被作答的次数
+	aggregators := make(map[*question.Definition]map[string]question.Aggregator)
+	aggregatorOf := func(rowDef *question.Definition, qID string) question.Aggregator {
+		if aggregators[rowDef] == nil {
+			aggregators[rowDef] = make(map[string]question.Aggregator)
 		}
+		agg, ok := aggregators[rowDef][qID]
+		if !ok {
+			if q := rowDef.QuestionByID(qID); q != nil {
+				if qt, found := question.Lookup(q.Type); found {
+					agg = qt.NewAggregator(q)
+				}
+			}
+			aggregators[rowDef][qID] = agg
+		}
+		return agg
 	}
-
-	// 4. 初始化统计结果结构
-	statsResult := &FormStats{
-		TotalSubmissions: len(submissions),
-		QuestionStats:    make([]QuestionStat, 0, len(def.Questions)),
-	}
-	tempStats := make(map[string]map[string]int) // {questionID: {optionID: count}}
-	tempTextAnswers := make(map[string][]string) // {questionID: [answer1, answer2]}
-
-	// 5. 遍历所有提交记录，进行数据聚合
+	pooled := false
+	servedCounts := make(map[string]int)
+	shownCounts := make(map[string]int)
+	answeredCounts := make(map[string]int)
+	pageShownCounts := make(map[string]int)
 	for _, sub := range submissions {
-		var answers map[string]interface{}
+		rowDef := rowDefinition(def, versionDefs, &sub)
+		served := servedDefinition(rowDef, &sub)
+		if len(rowDef.Pools) > 0 {
+			pooled = true
+			for _, q := range served.Questions {
+				servedCounts[q.ID]++
+			}
+		}
+		var answers map[string]json.RawMessage
 		if err := json.Unmarshal(sub.Data, &answers); err != nil {
 			continue
 		}
-
-		for qID, ans := range answers {
-			qDef, ok := questionDefMap[qID]
-			if !ok {
+		pagesShown := make(map[string]bool)
+		for qID := range served.Visible(answers) {
+			shownCounts[qID]++
+			if page := rowDef.PageOf(qID); page != nil && !pagesShown[page.ID] {
+				pagesShown[page.ID] = true
+				pageShownCounts[page.ID]++
+			}
+			ans, ok := answers[qID]
+			if !ok || question.IsEmptyAnswer(ans) {
 				continue
 			}
-
-			// 确保为每个问题初始化统计map
-			if _, exists := tempStats[qID]; !exists {
-				tempStats[qID] = make(map[string]int)
+			answeredCounts[qID]++
+			if agg := aggregatorOf(rowDef, qID); agg != nil {
+				agg.Add(ans)
 			}
+		}
+	}
 
-			switch qDef.Type {
-			// 单选题和判断题的答案是 string
-			case "single_choice", "judgment":
-				if optID, ok := ans.(string); ok {
-					tempStats[qID][optID]++
-				}
-			// 多选题的答案是 []string
-			case "multi_choice":
-				if opts, ok := ans.([]interface{}); ok {
-					for _, opt := range opts {
-						if optID, ok := opt.(string); ok {
-							tempStats[qID][optID]++
-						}
-					}
-				}
-			// 填空题的答案是 string
-			case "text_input":
-				if text, ok := ans.(string); ok {
-					tempTextAnswers[qID] = append(tempTextAnswers[qID], text)
-				}
+	// 5. 按输出的定义创建每个问题的聚合器，再按从新到旧的顺序合并各个版本上同一题型的聚合结果
+	pageIDOf := make(map[string]string)
+	for _, page := range def.Pages {
+		for _, qID := range page.QuestionIDs {
+			pageIDOf[qID] = page.ID
+		}
+	}
+	merged := make(map[string]question.Aggregator, len(def.Questions))
+	for i := range def.Questions {
+		q := &def.Questions[i]
+		qt, ok := question.Lookup(q.Type)
+		if !ok {
+			continue
+		}
+		merged[q.ID] = qt.NewAggregator(q)
+		for _, rowDef := range newestDefinitionsFirst(def, versionDefs) {
+			rowQ := rowDef.QuestionByID(q.ID)
+			agg := aggregators[rowDef][q.ID]
+			if rowQ != nil && rowQ.Type == q.Type && agg != nil {
+				merged[q.ID].Merge(agg)
 			}
 		}
 	}
 
 	// 6. 将聚合后的数据整理成最终的返回格式
+	statsResult := &FormStats{
+		TotalSubmissions: len(submissions),
+		QuestionStats:    make([]QuestionStat, 0, len(def.Questions)),
+	}
 	for _, qDef := range def.Questions {
 		qStat := QuestionStat{
-			QuestionID:   qDef.ID,
-			QuestionType: qDef.Type,
-			Title:        qDef.Title,
+			QuestionID:    qDef.ID,
+			QuestionType:  qDef.Type,
+			Title:         qDef.Title,
+			PageID:        pageIDOf[qDef.ID],
+			ShownCount:    shownCounts[qDef.ID],
+			AnsweredCount: answeredCounts[qDef.ID],
+		}
+		if agg, ok := merged[qDef.ID]; ok {
+			agg.Fill(&qStat)
+		}
+		if pooled {
+			served := servedCounts[qDef.ID]
+			qStat.ServedCount = &served
 		}
+		statsResult.QuestionStats = append(statsResult.QuestionStats, qStat)
+	}
+	for _, page := range def.Pages {
+		statsResult.Pages = append(statsResult.Pages, PageStat{
+			PageID:      page.ID,
+			Title:       page.Title,
+			QuestionIDs: filterQuestionIDs(def, page.QuestionIDs),
+			ShownCount:  pageShownCounts[page.ID],
+		})
+	}
+	statsResult.ScoreStats = buildScoreStats(submissions)
+	statsResult.Version = version
+	statsResult.HiddenFields = buildHiddenFieldStats(def, submissions)
+
+	// 7. 总提交数上限和配额按表单当前的定义统计
+	if current, err := question.ParseDefinition(form.Definition); err == nil {
+		if current.Settings.MaxResponses > 0 {
+			statsResult.ResponseLimit = &QuotaStat{ID: "total", Title: "总提交数", Limit: current.Settings.MaxResponses, Count: int64(allCount)}
+		}
+		if statsResult.Quotas, err = buildQuotaStats(s.submissionRepo, formID, current); err != nil {
+			return nil, err
+		}
+	}
 
-		// 统一处理所有基于选项的题型
-		if qDef.Type == "single_choice" || qDef.Type == "multi_choice" || qDef.Type == "judgment" {
-			// 初始化所有选项的计数为0
-			optionCounts := make(map[string]int)
-			for _, opt := range qDef.Options {
-				optionCounts[opt.ID] = 0
-			}
-			// 如果有统计数据，则填充
-			if counts, ok := tempStats[qDef.ID]; ok {
-				for optID, count := range counts {
-					optionCounts[optID] = count
-				}
-			}
-			// 转换为最终的数组格式
-			qStat.OptionStats = make([]OptionStat, 0, len(qDef.Options))
-			for _, opt := range qDef.Options {
-				qStat.OptionStats = append(qStat.OptionStats, OptionStat{
-					Text:  optionTextMap[opt.ID],
-					Count: optionCounts[opt.ID],
-				})
+	return statsResult, nil
+}
+
+// buildHiddenFieldStats 统计每个隐藏字段各个取值出现的次数
+func buildHiddenFieldStats(def *question.Definition, submissions []model.Submission) []HiddenFieldStat {
+	if len(def.HiddenFields) == 0 {
+		return nil
+	}
+	counts := make(map[string]map[string]int, len(def.HiddenFields))
+	for _, sub := range submissions {
+		var values map[string]string
+		if len(sub.HiddenFields) == 0 || json.Unmarshal(sub.HiddenFields, &values) != nil {
+			continue
+		}
+		for name, value := range values {
+			if counts[name] == nil {
+				counts[name] = make(map[string]int)
 			}
-		} else if qDef.Type == "text_input" {
-			qStat.TextAnswers = tempTextAnswers[qDef.ID]
+			counts[name][value]++
 		}
+	}
 
-		statsResult.QuestionStats = append(statsResult.QuestionStats, qStat)
+	stats := make([]HiddenFieldStat, 0, len(def.HiddenFields))
+	for _, field := range def.HiddenFields {
+		stat := HiddenFieldStat{Name: field.Name, Title: field.DisplayTitle(), Values: make([]OptionStat, 0, len(counts[field.Name]))}
+		for value, count := range counts[field.Name] {
+			stat.Values = append(stat.Values, OptionStat{Text: value, Count: count})
+		}
+		sort.Slice(stat.Values, func(i, j int) bool {
+			if stat.Values[i].Count != stat.Values[j].Count {
+				return stat.Values[i].Count > stat.Values[j].Count
+			}
+			return stat.Values[i].Text < stat.Values[j].Text
+		})
+		stats = append(stats, stat)
 	}
+	return stats
+}
 
-	return statsResult, nil
+// filterQuestionIDs 去掉定义中不存在的问题ID
+func filterQuestionIDs(def *question.Definition, questionIDs []string) []string {
+	result := make([]string, 0, len(questionIDs))
+	for _, qID := range questionIDs {
+		if def.QuestionByID(qID) != nil {
+			result = append(result, qID)
+		}
+	}
+	return result
+}
+
+// buildScoreStats 汇总已判分提交的成绩，没有已判分的提交时返回 nil
+func buildScoreStats(submissions []model.Submission) *ScoreStats {
+	var stats *ScoreStats
+	total := 0
+	for _, sub := range submissions {
+		if sub.RawScore == nil {
+			continue
+		}
+		score := *sub.RawScore
+		if stats == nil {
+			stats = &ScoreStats{HighestScore: score, LowestScore: score}
+		}
+		stats.GradedCount++
+		total += score
+		stats.HighestScore = max(stats.HighestScore, score)
+		stats.LowestScore = min(stats.LowestScore, score)
+		if sub.MaxScore != nil {
+			stats.MaxScore = max(stats.MaxScore, *sub.MaxScore)
+		}
+	}
+	if stats != nil {
+		stats.AverageScore = float64(total) / float64(stats.GradedCount)
+	}
+	return stats
 }
@@ -0,0 +1,214 @@
+package service
+
+import (
+	"fmt"
+	"questflow/internal/model"
+	"questflow/internal/repository"
+	"reflect"
+	"testing"
+	"time"
+
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+)
+
+// fakeFormRepository 只实现统计用到的查询方法，其余方法调用时会 panic
+type fakeFormRepository struct {
+	repository.FormRepository
+	form *model.Form
+}
+
+func (r *fakeFormRepository) FindByID(id uint) (*model.Form, error) {
+	if r.form.ID != id {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return r.form, nil
+}
+
+type fakeVersionRepository struct {
+	repository.FormVersionRepository
+	versions []model.FormVersion
+}
+
+func (r *fakeVersionRepository) FindByID(id uint) (*model.FormVersion, error) {
+	for i := range r.versions {
+		if r.versions[i].ID == id {
+			return &r.versions[i], nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (r *fakeVersionRepository) FindByFormID(formID uint) ([]model.FormVersion, error) {
+	versions := make([]model.FormVersion, 0, len(r.versions))
+	for i := len(r.versions) - 1; i >= 0; i-- {
+		versions = append(versions, r.versions[i])
+	}
+	return versions, nil
+}
+
+type fakeSubmissionRepository struct {
+	repository.SubmissionRepository
+	submissions []model.Submission
+	conditions  []repository.FilterCondition // 最近一次 FindWithFilters 收到的筛选条件
+}
+
+func (r *fakeSubmissionRepository) FindWithFilters(formID uint, startTime, endTime *time.Time, conditions []repository.FilterCondition) ([]model.Submission, error) {
+	r.conditions = conditions
+	return nil, nil
+}
+
+func (r *fakeSubmissionRepository) FindByFormID(formID uint) ([]model.Submission, error) {
+	return r.submissions, nil
+}
+
+func (r *fakeSubmissionRepository) FindGradesByFormID(formID uint) ([]model.AnswerGrade, error) {
+	return nil, nil
+}
+
+// newVersionedFormService 创建一个表单服务，表单当前的定义是 current，versions 依次是版本 1、2……的定义，
+// submissions 中每一项是作答的版本号和答案
+func newVersionedFormService(current string, versions []string, submissions ...model.Submission) FormService {
+	form := &model.Form{ID: 1, CreatorID: 1, Definition: datatypes.JSON(current)}
+	versionRepo := &fakeVersionRepository{}
+	for i, def := range versions {
+		versionRepo.versions = append(versionRepo.versions, model.FormVersion{ID: uint(i + 1), FormID: 1, Version: i + 1, Definition: datatypes.JSON(def)})
+	}
+	return NewFormService(&fakeFormRepository{form: form}, versionRepo, &fakeSubmissionRepository{submissions: submissions}, nil)
+}
+
+// versionedSubmission 创建一份在指定版本上作答的提交
+func versionedSubmission(versionID uint, data string, score ...int) model.Submission {
+	sub := model.Submission{FormID: 1, FormVersionID: &versionID, Data: datatypes.JSON(data)}
+	if len(score) == 2 {
+		sub.RawScore, sub.MaxScore = &score[0], &score[1]
+	}
+	return sub
+}
+
+// 版本 1 的 green 选项在版本 2 中被 purple 取代，追问的显示条件也随之改变
+const colorV1 = `{"questions": [
+	{"id": "color", "type": "single_choice", "options": [{"id": "red", "text": "Red"}, {"id": "blue", "text": "Blue"}, {"id": "green", "text": "Green"}]},
+	{"id": "why", "type": "text_input", "visibleIf": {"question": "color", "op": "equals", "value": "green"}}
+]}`
+
+const colorV2 = `{"questions": [
+	{"id": "color", "type": "single_choice", "options": [{"id": "red", "text": "Red"}, {"id": "blue", "text": "Blue"}, {"id": "purple", "text": "Purple"}]},
+	{"id": "why", "type": "text_input", "visibleIf": {"question": "color", "op": "equals", "value": "purple"}}
+]}`
+
+func TestFormStatisticsAcrossVersions(t *testing.T) {
+	svc := newVersionedFormService(colorV2, []string{colorV1, colorV2},
+		versionedSubmission(1, `{"color": "green", "why": "old favourite"}`),
+		versionedSubmission(1, `{"color": "red"}`),
+		versionedSubmission(2, `{"color": "purple", "why": "new favourite"}`),
+		versionedSubmission(2, `{"color": "blue"}`),
+	)
+
+	tests := []struct {
+		name        string
+		versionID   uint
+		wantOptions []string // "文本:次数"
+		wantWhy     []string // 较新版本上的答案在前
+		wantShown   int
+	}{
+		{"all versions", 0, []string{"Red:1", "Blue:1", "Purple:1", "Green:1"}, []string{"new favourite", "old favourite"}, 2},
+		{"version 1", 1, []string{"Red:1", "Blue:0", "Green:1"}, []string{"old favourite"}, 1},
+		{"version 2", 2, []string{"Red:0", "Blue:1", "Purple:1"}, []string{"new favourite"}, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stats, err := svc.GetFormStatistics(1, 1, tt.versionID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(stats.QuestionStats) != 2 {
+				t.Fatalf("got %d question stats, want 2", len(stats.QuestionStats))
+			}
+			color, why := stats.QuestionStats[0], stats.QuestionStats[1]
+			options := make([]string, 0, len(color.OptionStats))
+			for _, opt := range color.OptionStats {
+				options = append(options, fmt.Sprintf("%s:%d", opt.Text, opt.Count))
+			}
+			if !reflect.DeepEqual(options, tt.wantOptions) {
+				t.Errorf("options = %v, want %v", options, tt.wantOptions)
+			}
+			// 追问按每份提交作答时版本的显示条件计算分母
+			if why.ShownCount != tt.wantShown || !reflect.DeepEqual(why.TextAnswers, tt.wantWhy) {
+				t.Errorf("why shown %d times with answers %v, want %d and %v", why.ShownCount, why.TextAnswers, tt.wantShown, tt.wantWhy)
+			}
+		})
+	}
+}
+
+func TestItemAnalysisAcrossVersions(t *testing.T) {
+	// 版本 2 修改了 q1 的标准答案和分值，每份提交都应按作答版本的标准答案判分
+	v1 := `{"settings": {"type": "exam"}, "questions": [
+		{"id": "q1", "type": "single_choice", "score": 2, "options": [{"id": "a"}, {"id": "b"}], "correct_answer": "a"},
+		{"id": "q2", "type": "single_choice", "score": 2, "options": [{"id": "a"}, {"id": "b"}], "correct_answer": "a"}
+	]}`
+	v2 := `{"settings": {"type": "exam"}, "questions": [
+		{"id": "q1", "type": "single_choice", "score": 4, "options": [{"id": "a"}, {"id": "b"}], "correct_answer": "b"},
+		{"id": "q2", "type": "single_choice", "score": 2, "options": [{"id": "a"}, {"id": "b"}], "correct_answer": "a"}
+	]}`
+	svc := newVersionedFormService(v2, []string{v1, v2},
+		versionedSubmission(1, `{"q1": "a", "q2": "a"}`, 4, 4),
+		versionedSubmission(1, `{"q1": "a", "q2": "b"}`, 2, 4),
+		versionedSubmission(2, `{"q1": "b", "q2": "a"}`, 6, 6),
+		versionedSubmission(2, `{"q1": "b", "q2": "b"}`, 4, 6),
+	)
+
+	analysis, err := svc.GetItemAnalysis(1, 1, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if analysis.MaxScore != 6 {
+		t.Errorf("MaxScore = %d, want the highest recorded total 6", analysis.MaxScore)
+	}
+	want := map[string]float64{"q1": 1, "q2": 0.5}
+	for _, item := range analysis.Items {
+		if item.Difficulty == nil || *item.Difficulty != want[item.QuestionID] {
+			t.Errorf("%s difficulty = %v, want %v", item.QuestionID, item.Difficulty, want[item.QuestionID])
+		}
+	}
+}
+
+// 筛选条件中的问题必须在当前定义或历史版本中存在，题型以定义为准
+func TestExportFilterConditions(t *testing.T) {
+	v1 := `{"questions": [{"id": "age", "type": "number"}, {"id": "color", "type": "single_choice", "options": [{"id": "red"}]}]}`
+	v2 := `{"questions": [{"id": "color", "type": "single_choice", "options": [{"id": "red"}]}]}`
+	submissions := &fakeSubmissionRepository{}
+	svc := NewFormService(
+		&fakeFormRepository{form: &model.Form{ID: 1, CreatorID: 1, Definition: datatypes.JSON(v2)}},
+		&fakeVersionRepository{versions: []model.FormVersion{{ID: 1, FormID: 1, Definition: datatypes.JSON(v1)}, {ID: 2, FormID: 1, Definition: datatypes.JSON(v2)}}},
+		submissions, nil)
+
+	tests := []struct {
+		name     string
+		cond     repository.FilterCondition
+		wantErr  string
+		wantType string
+	}{
+		{"current question", repository.FilterCondition{QuestionID: "color", QuestionType: "text_input", Operator: "equals", Value: []string{"red"}}, "", "single_choice"},
+		{"question of an old version", repository.FilterCondition{QuestionID: "age", Operator: "gt", Value: []string{"18"}}, "", "number"},
+		{"unknown question", repository.FilterCondition{QuestionID: "x' OR '1'='1", QuestionType: "single_choice", Operator: "equals", Value: []string{"a"}}, "unknown filter question", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			submissions.conditions = nil
+			_, _, err := svc.ExportFormSubmissions(1, 1, nil, nil, []repository.FilterCondition{tt.cond})
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("error = %v, want %q", err, tt.wantErr)
+				}
+				if submissions.conditions != nil {
+					t.Fatal("conditions with an unknown question reached the repository")
+				}
+				return
+			}
+			if len(submissions.conditions) != 1 || submissions.conditions[0].QuestionType != tt.wantType {
+				t.Fatalf("repository got %+v, want question type %q", submissions.conditions, tt.wantType)
+			}
+		})
+	}
+}
@@ -0,0 +1,229 @@
+// Package service 包含了应用的业务逻辑
+package service
+
+import (
+	"errors"
+	"questflow/internal/model"
+	"questflow/internal/question"
+	"questflow/internal/repository"
+	"time"
+
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+)
+
+// FormVersionInfo 是版本历史中单个版本的摘要
+type FormVersionInfo struct {
+	ID          uint      `json:"id"`
+	Version     int       `json:"version"`
+	Title       string    `json:"title"`
+	Submissions int64     `json:"submissions"` // 在该版本上作答的提交数
+	Current     bool      `json:"current"`     // 是否为最近发布的版本
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// FormVersionDetail 是单个版本的完整快照
+type FormVersionDetail struct {
+	FormVersionInfo
+	Description string         `json:"description"`
+	Definition  datatypes.JSON `json:"definition"`
+}
+
+// FormVersionDiff 是两个版本之间的差异
+type FormVersionDiff struct {
+	From FormVersionInfo `json:"from"`
+	To   FormVersionInfo `json:"to"`
+	// FormChanges 包含标题和描述的变化
+	FormChanges []question.FieldChange `json:"form_changes"`
+	*question.DefinitionDiff
+}
+
+// FormVersionService 定义了表单版本历史的服务接口，只有表单的创建者可以查看
+type FormVersionService interface {
+	ListVersions(formID, userID uint) ([]FormVersionInfo, error)
+	GetVersion(formID, userID, versionID uint) (*FormVersionDetail, error)
+	DiffVersions(formID, userID, fromID, toID uint) (*FormVersionDiff, error)
+}
+
+// formVersionServiceImpl 是 FormVersionService 的实现
+type formVersionServiceImpl struct {
+	formRepo       repository.FormRepository
+	versionRepo    repository.FormVersionRepository
+	submissionRepo repository.SubmissionRepository
+}
+
+// NewFormVersionService 创建一个新的 FormVersionService 实例
+func NewFormVersionService(formRepo repository.FormRepository, versionRepo repository.FormVersionRepository, submissionRepo repository.SubmissionRepository) FormVersionService {
+	return &formVersionServiceImpl{formRepo: formRepo, versionRepo: versionRepo, submissionRepo: submissionRepo}
+}
+
+// ListVersions 列出表单的所有版本，最新的版本在前
+func (s *formVersionServiceImpl) ListVersions(formID, userID uint) ([]FormVersionInfo, error) {
+	form, err := s.loadForm(formID, userID)
+	if err != nil {
+		return nil, err
+	}
+	versions, err := s.versionRepo.FindByFormID(formID)
+	if err != nil {
+		return nil, err
+	}
+	counts, err := s.submissionRepo.CountByFormVersion(formID)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]FormVersionInfo, 0, len(versions))
+	for i := range versions {
+		info := newFormVersionInfo(form, &versions[i])
+		info.Submissions = counts[versions[i].ID]
+		result = append(result, info)
+	}
+	return result, nil
+}
+
+// GetVersion 返回单个版本的完整快照
+func (s *formVersionServiceImpl) GetVersion(formID, userID, versionID uint) (*FormVersionDetail, error) {
+	form, err := s.loadForm(formID, userID)
+	if err != nil {
+		return nil, err
+	}
+	version, err := s.loadVersion(formID, versionID)
+	if err != nil {
+		return nil, err
+	}
+	counts, err := s.submissionRepo.CountByFormVersion(formID)
+	if err != nil {
+		return nil, err
+	}
+	info := newFormVersionInfo(form, version)
+	info.Submissions = counts[version.ID]
+	return &FormVersionDetail{
+		FormVersionInfo: info,
+		Description:     version.Description,
+		Definition:      version.Definition,
+	}, nil
+}
+
+// DiffVersions 比较同一表单的两个版本，返回从 fromID 到 toID 的变化
+func (s *formVersionServiceImpl) DiffVersions(formID, userID, fromID, toID uint) (*FormVersionDiff, error) {
+	form, err := s.loadForm(formID, userID)
+	if err != nil {
+		return nil, err
+	}
+	from, err := s.loadVersion(formID, fromID)
+	if err != nil {
+		return nil, err
+	}
+	to, err := s.loadVersion(formID, toID)
+	if err != nil {
+		return nil, err
+	}
+	defDiff, err := question.Diff(from.Definition, to.Definition)
+	if err != nil {
+		return nil, errors.New("failed to parse form definition")
+	}
+
+	formChanges := make([]question.FieldChange, 0)
+	if from.Title != to.Title {
+		formChanges = append(formChanges, question.FieldChange{Field: "title", From: from.Title, To: to.Title})
+	}
+	if from.Description != to.Description {
+		formChanges = append(formChanges, question.FieldChange{Field: "description", From: from.Description, To: to.Description})
+	}
+	return &FormVersionDiff{
+		From:           newFormVersionInfo(form, from),
+		To:             newFormVersionInfo(form, to),
+		FormChanges:    formChanges,
+		DefinitionDiff: defDiff,
+	}, nil
+}
+
+// loadForm 校验表单归属
+func (s *formVersionServiceImpl) loadForm(formID, userID uint) (*model.Form, error) {
+	form, err := s.formRepo.FindByID(formID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("form not found")
+		}
+		return nil, err
+	}
+	if form.CreatorID != userID {
+		return nil, errors.New("access denied")
+	}
+	return form, nil
+}
+
+// loadVersion 查找属于指定表单的版本
+func (s *formVersionServiceImpl) loadVersion(formID, versionID uint) (*model.FormVersion, error) {
+	version, err := s.versionRepo.FindByID(versionID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("form version not found")
+		}
+		return nil, err
+	}
+	if version.FormID != formID {
+		return nil, errors.New("form version not found")
+	}
+	return version, nil
+}
+
+// newFormVersionInfo 生成版本摘要，提交数由调用方按需填充
+func newFormVersionInfo(form *model.Form, version *model.FormVersion) FormVersionInfo {
+	return FormVersionInfo{
+		ID:        version.ID,
+		Version:   version.Version,
+		Title:     version.Title,
+		Current:   form.PublishedVersionID != nil && *form.PublishedVersionID == version.ID,
+		CreatedAt: version.CreatedAt,
+	}
+}
+
+// versionDefinitions 按版本ID缓存解析后的表单定义，同一个版本只加载和解析一次
+type versionDefinitions struct {
+	repo repository.FormVersionRepository
+	defs map[uint]*question.Definition
+}
+
+// attemptVersion 返回答题令牌中记录的版本，即开始答题时表单最近发布的版本；
+// 令牌没有记录版本、记录的就是当前发布的版本或版本已不存在时返回 nil，由调用方使用表单当前的定义
+func attemptVersion(repo repository.FormVersionRepository, form *model.Form, timing *attemptTiming) (*model.FormVersion, error) {
+	id := timing.FormVersionID
+	if id == nil || (form.PublishedVersionID != nil && *form.PublishedVersionID == *id) {
+		return nil, nil
+	}
+	version, err := repo.FindByID(*id)
+	if errors.Is(err, gorm.ErrRecordNotFound) || (err == nil && version.FormID != form.ID) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.New("failed to load form version")
+	}
+	return version, nil
+}
+
+// newVersionDefinitions 创建一个空的版本定义缓存
+func newVersionDefinitions(repo repository.FormVersionRepository) *versionDefinitions {
+	return &versionDefinitions{repo: repo, defs: make(map[uint]*question.Definition)}
+}
+
+// get 返回提交记录作答时的表单定义；提交没有版本、版本已不存在或定义无法解析时返回 nil，
+// 由调用方回退到表单当前的定义
+func (v *versionDefinitions) get(sub *model.Submission) (*question.Definition, error) {
+	if sub.FormVersionID == nil {
+		return nil, nil
+	}
+	id := *sub.FormVersionID
+	if def, loaded := v.defs[id]; loaded {
+		return def, nil
+	}
+	version, err := v.repo.FindByID(id)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+	var def *question.Definition
+	if err == nil {
+		def, _ = question.ParseDefinition(version.Definition)
+	}
+	v.defs[id] = def
+	return def, nil
+}
@@ -0,0 +1,291 @@
+// Package service 包含了应用的业务逻辑
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"questflow/internal/model"
+	"questflow/internal/question"
+	"questflow/internal/repository"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// QuestionGradingProgress 是单个人工阅卷问题的阅卷进度
+type QuestionGradingProgress struct {
+	QuestionID string `json:"question_id"`
+	Title      string `json:"title"`
+	Score      int    `json:"score"`    // 题目分值
+	Answered   int    `json:"answered"` // 作答了该题的提交数
+	Graded     int    `json:"graded"`
+	Ungraded   int    `json:"ungraded"`
+}
+
+// GradingProgress 是一个表单的人工阅卷进度汇总
+type GradingProgress struct {
+	TotalSubmissions int                       `json:"total_submissions"`
+	FullyGraded      int                       `json:"fully_graded"` // 所有作答的人工阅卷题都已评分的提交数
+	Questions        []QuestionGradingProgress `json:"questions"`
+}
+
+// UngradedAnswer 是一条待人工评分的答案
+type UngradedAnswer struct {
+	SubmissionID uint      `json:"submission_id"`
+	Answer       string    `json:"answer"`
+	SubmittedAt  time.Time `json:"submitted_at"`
+}
+
+// GradeResult 是评分后提交的最新得分
+type GradeResult struct {
+	SubmissionID uint `json:"submission_id"`
+	RawScore     *int `json:"raw_score"`
+	MaxScore     *int `json:"max_score"`
+}
+
+// GradingService 定义了人工阅卷的服务接口，只有表单的创建者可以阅卷
+type GradingService interface {
+	GetGradingProgress(formID, userID uint) (*GradingProgress, error)
+	ListUngradedAnswers(formID, userID uint, questionID string, page, pageSize int) ([]UngradedAnswer, error)
+	GradeAnswer(formID, userID, submissionID uint, questionID string, points int, feedback string) (*GradeResult, error)
+}
+
+// gradingServiceImpl 是 GradingService 的实现
+type gradingServiceImpl struct {
+	formRepo       repository.FormRepository
+	versionRepo    repository.FormVersionRepository
+	submissionRepo repository.SubmissionRepository
+}
+
+// NewGradingService 创建一个新的 GradingService 实例
+func NewGradingService(formRepo repository.FormRepository, versionRepo repository.FormVersionRepository, submissionRepo repository.SubmissionRepository) GradingService {
+	return &gradingServiceImpl{formRepo: formRepo, versionRepo: versionRepo, submissionRepo: submissionRepo}
+}
+
+// GetGradingProgress 汇总表单中每个人工阅卷问题的评分进度。每条提交按作答时的版本和
+// 答题者实际看到的题目判断需要人工评分的问题，只在旧版本中出现过的问题排在当前问题之后
+func (s *gradingServiceImpl) GetGradingProgress(formID, userID uint) (*GradingProgress, error) {
+	def, err := s.loadDefinition(formID, userID)
+	if err != nil {
+		return nil, err
+	}
+	submissions, err := s.submissionRepo.FindByFormID(formID)
+	if err != nil {
+		return nil, err
+	}
+	grades, err := s.submissionRepo.FindGradesByFormID(formID)
+	if err != nil {
+		return nil, err
+	}
+
+	// graded[submissionID][questionID] 表示该答案已评分
+	graded := make(map[uint]map[string]bool)
+	for _, g := range grades {
+		if graded[g.SubmissionID] == nil {
+			graded[g.SubmissionID] = make(map[string]bool)
+		}
+		graded[g.SubmissionID][g.QuestionID] = true
+	}
+
+	progress := &GradingProgress{
+		TotalSubmissions: len(submissions),
+		Questions:        make([]QuestionGradingProgress, 0),
+	}
+	index := make(map[string]int) // questionID -> progress.Questions 中的下标
+	addQuestion := func(q *question.Question) int {
+		if i, ok := index[q.ID]; ok {
+			return i
+		}
+		index[q.ID] = len(progress.Questions)
+		progress.Questions = append(progress.Questions, QuestionGradingProgress{
+			QuestionID: q.ID,
+			Title:      q.Title,
+			Score:      q.Score,
+		})
+		return index[q.ID]
+	}
+	for i := range def.Questions {
+		if def.ManuallyGraded(&def.Questions[i]) {
+			addQuestion(&def.Questions[i])
+		}
+	}
+
+	versions := newVersionDefinitions(s.versionRepo)
+	for _, sub := range submissions {
+		var answers map[string]json.RawMessage
+		if err := json.Unmarshal(sub.Data, &answers); err != nil {
+			continue
+		}
+		rowDef, err := versions.get(&sub)
+		if err != nil {
+			return nil, err
+		}
+		if rowDef == nil {
+			rowDef = def
+		}
+		served := servedDefinition(rowDef, &sub)
+		complete := true
+		for j := range served.Questions {
+			q := &served.Questions[j]
+			if !served.ManuallyGraded(q) {
+				continue
+			}
+			if raw, ok := answers[q.ID]; !ok || question.IsEmptyAnswer(raw) {
+				continue
+			}
+			i := addQuestion(q)
+			progress.Questions[i].Answered++
+			if graded[sub.ID][q.ID] {
+				progress.Questions[i].Graded++
+			} else {
+				progress.Questions[i].Ungraded++
+				complete = false
+			}
+		}
+		if complete {
+			progress.FullyGraded++
+		}
+	}
+	return progress, nil
+}
+
+// ListUngradedAnswers 分页列出某个问题尚未评分的答案，按提交时间先后排序
+func (s *gradingServiceImpl) ListUngradedAnswers(formID, userID uint, questionID string, page, pageSize int) ([]UngradedAnswer, error) {
+	def, err := s.loadDefinition(formID, userID)
+	if err != nil {
+		return nil, err
+	}
+	manual, err := s.manuallyGradedInAnyVersion(formID, def, questionID)
+	if err != nil {
+		return nil, err
+	}
+	if !manual {
+		return nil, errors.New("question is not manually graded")
+	}
+
+	submissions, err := s.submissionRepo.FindUngradedAnswers(formID, questionID, pageSize, (page-1)*pageSize)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]UngradedAnswer, 0, len(submissions))
+	for _, sub := range submissions {
+		var answers map[string]json.RawMessage
+		if err := json.Unmarshal(sub.Data, &answers); err != nil {
+			continue
+		}
+		var text string
+		_ = json.Unmarshal(answers[questionID], &text)
+		result = append(result, UngradedAnswer{
+			SubmissionID: sub.ID,
+			Answer:       text,
+			SubmittedAt:  sub.CreatedAt,
+		})
+	}
+	return result, nil
+}
+
+// GradeAnswer 为一个答案评分（重复评分会覆盖之前的结果），并重新计算该提交的 RawScore
+func (s *gradingServiceImpl) GradeAnswer(formID, userID, submissionID uint, questionID string, points int, feedback string) (*GradeResult, error) {
+	def, err := s.loadDefinition(formID, userID)
+	if err != nil {
+		return nil, err
+	}
+	sub, err := s.submissionRepo.FindByID(submissionID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("submission not found")
+		}
+		return nil, err
+	}
+	if sub.FormID != formID {
+		return nil, errors.New("submission not found")
+	}
+	// 按提交作答时的版本评分，题目分值以该版本为准
+	versionDef, err := newVersionDefinitions(s.versionRepo).get(sub)
+	if err != nil {
+		return nil, err
+	}
+	if versionDef != nil {
+		def = versionDef
+	}
+
+	q := def.QuestionByID(questionID)
+	if q == nil || !def.ManuallyGraded(q) {
+		return nil, errors.New("question is not manually graded")
+	}
+	if points < 0 || points > q.Score {
+		return nil, errors.New("points out of range")
+	}
+	var answers map[string]json.RawMessage
+	if err := json.Unmarshal(sub.Data, &answers); err != nil || question.IsEmptyAnswer(answers[questionID]) {
+		return nil, errors.New("question was not answered")
+	}
+
+	// 自动判分部分按作答时的表单定义和答题者看到的题目重新计算，再加上所有人工评分
+	served := servedDefinition(def, sub)
+	score := served.Grade(sub.Data)
+	manualIDs := make([]string, 0)
+	for i := range served.Questions {
+		if served.ManuallyGraded(&served.Questions[i]) {
+			manualIDs = append(manualIDs, served.Questions[i].ID)
+		}
+	}
+	grade := &model.AnswerGrade{
+		SubmissionID: sub.ID,
+		QuestionID:   questionID,
+		FormID:       formID,
+		Points:       points,
+		Feedback:     feedback,
+		GraderID:     userID,
+	}
+	if err := s.submissionRepo.SaveAnswerGrade(grade, score.RawScore, score.MaxScore, manualIDs); err != nil {
+		return nil, err
+	}
+
+	updated, err := s.submissionRepo.FindByID(sub.ID)
+	if err != nil {
+		return nil, err
+	}
+	return &GradeResult{SubmissionID: updated.ID, RawScore: updated.RawScore, MaxScore: updated.MaxScore}, nil
+}
+
+// manuallyGradedInAnyVersion 判断问题在当前定义或任意一个历史版本中是否需要人工评分，
+// 旧版本中的问题即使已从表单中删除，在这些版本上作答的答案仍然需要评分
+func (s *gradingServiceImpl) manuallyGradedInAnyVersion(formID uint, def *question.Definition, questionID string) (bool, error) {
+	if q := def.QuestionByID(questionID); q != nil {
+		return def.ManuallyGraded(q), nil
+	}
+	versions, err := s.versionRepo.FindByFormID(formID)
+	if err != nil {
+		return false, err
+	}
+	for _, version := range versions {
+		versionDef, err := question.ParseDefinition(version.Definition)
+		if err != nil {
+			continue
+		}
+		if q := versionDef.QuestionByID(questionID); q != nil && versionDef.ManuallyGraded(q) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// loadDefinition 校验表单归属并解析表单定义
+func (s *gradingServiceImpl) loadDefinition(formID, userID uint) (*question.Definition, error) {
+	form, err := s.formRepo.FindByID(formID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("form not found")
+		}
+		return nil, err
+	}
+	if form.CreatorID != userID {
+		return nil, errors.New("access denied")
+	}
+	def, err := question.ParseDefinition(form.Definition)
+	if err != nil {
+		return nil, errors.New("failed to parse form definition")
+	}
+	return def, nil
+}
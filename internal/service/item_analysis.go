@@ -0,0 +1,388 @@
+// Package service 包含了应用的业务逻辑
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"questflow/internal/model"
+	"questflow/internal/question"
+	"sort"
+
+	"gorm.io/gorm"
+)
+
+// groupRatio 是区分度计算中高分组和低分组各自所占的比例
+const groupRatio = 0.27
+
+// histogramBuckets 是总分较高时成绩分布直方图的分组数
+const histogramBuckets = 10
+
+// ScoreBucket 是成绩分布直方图中的一个分组，包含 [Min, Max] 闭区间
+type ScoreBucket struct {
+	Min   int `json:"min"`
+	Max   int `json:"max"`
+	Count int `json:"count"`
+}
+
+// DistractorStat 是单个选项的干扰项分析结果
+type DistractorStat struct {
+	OptionID   string  `json:"option_id"`
+	Text       string  `json:"text"`
+	IsCorrect  bool    `json:"is_correct"` // 按分析结果使用的定义中的标准答案标记，跨版本分析时以当前定义为准
+	Count      int     `json:"count"`
+	Proportion float64 `json:"proportion"`  // 选择该选项的作答比例
+	UpperCount int     `json:"upper_count"` // 高分组中选择该选项的人数
+	LowerCount int     `json:"lower_count"` // 低分组中选择该选项的人数
+}
+
+// ItemStat 是单个计分问题的项目分析结果
+type ItemStat struct {
+	QuestionID     string           `json:"question_id"`
+	QuestionType   string           `json:"question_type"`
+	Title          string           `json:"title"`
+	MaxScore       int              `json:"max_score"`
+	Responses      int              `json:"responses"`      // 参与分析的得分数（人工阅卷题只统计已评分的答案）
+	Difficulty     *float64         `json:"difficulty"`     // 难度：平均得分率，越高越容易
+	Discrimination *float64         `json:"discrimination"` // 区分度：高分组与低分组的平均得分率之差
+	Distractors    []DistractorStat `json:"distractors,omitempty"`
+}
+
+// ItemAnalysis 是考试的项目分析结果，只统计已判分的提交
+type ItemAnalysis struct {
+	GradedSubmissions int              `json:"graded_submissions"`
+	MaxScore          int              `json:"max_score"`
+	Mean              float64          `json:"mean"`
+	Median            float64          `json:"median"`
+	StdDev            float64          `json:"std_dev"`
+	CronbachAlpha     *float64         `json:"cronbach_alpha"` // 题目少于两道或有效样本不足时为 null
+	ScoreDistribution []ScoreBucket    `json:"score_distribution"`
+	Items             []ItemStat       `json:"items"`
+	Version           *FormVersionInfo `json:"version,omitempty"` // 分析所基于的表单版本，表单还没有版本时不返回
+}
+
+// GetItemAnalysis 计算考试的项目分析：每道计分题的难度、区分度和干扰项分析，
+// 以及整卷的成绩分布、均值、中位数、标准差和 Cronbach's alpha 信度系数。
+// 可以只分析某个版本上的提交，默认分析所有版本；每份提交的每道题都按作答时的版本定义判分，
+// 与提交记录中按同一版本计算的总分使用同一套标准答案
+func (s *formServiceImpl) GetItemAnalysis(formID uint, userID uint, versionID uint) (*ItemAnalysis, error) {
+	form, err := s.formRepo.FindByID(formID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("form not found")
+		}
+		return nil, err
+	}
+	if form.CreatorID != userID {
+		return nil, errors.New("access denied")
+	}
+	submissions, err := s.submissionRepo.FindByFormID(formID)
+	if err != nil {
+		return nil, err
+	}
+	def, versionDefs, version, submissions, err := s.statsScope(form, versionID, submissions)
+	if err != nil {
+		return nil, err
+	}
+	grades, err := s.submissionRepo.FindGradesByFormID(formID)
+	if err != nil {
+		return nil, err
+	}
+	manualPoints := make(map[uint]map[string]int)
+	for _, g := range grades {
+		if manualPoints[g.SubmissionID] == nil {
+			manualPoints[g.SubmissionID] = make(map[string]int)
+		}
+		manualPoints[g.SubmissionID][g.QuestionID] = g.Points
+	}
+
+	// 1. 只分析已判分的提交，按总分从低到高排序
+	graded := make([]model.Submission, 0, len(submissions))
+	for _, sub := range submissions {
+		if sub.RawScore != nil {
+			graded = append(graded, sub)
+		}
+	}
+	sort.SliceStable(graded, func(i, j int) bool { return *graded[i].RawScore < *graded[j].RawScore })
+
+	// 2. 收集在任意一个参与分析的版本中计分的问题
+	var items []*question.Question
+	for i := range def.Questions {
+		q := &def.Questions[i]
+		for _, rowDef := range newestDefinitionsFirst(def, versionDefs) {
+			if rowQ := rowDef.QuestionByID(q.ID); rowQ != nil && (rowQ.AutoGradable() || rowDef.ManuallyGraded(rowQ)) {
+				items = append(items, q)
+				break
+			}
+		}
+	}
+
+	analysis := &ItemAnalysis{
+		GradedSubmissions: len(graded),
+		ScoreDistribution: make([]ScoreBucket, 0),
+		Items:             make([]ItemStat, 0, len(items)),
+		Version:           version,
+	}
+	for _, q := range items {
+		analysis.MaxScore += q.Score
+	}
+
+	// 随机抽题或跨版本分析时每份答卷的总分可能不同，以实际记录的最高总分为准
+	if len(def.Pools) > 0 || len(versionDefs) > 0 {
+		analysis.MaxScore = 0
+		for _, sub := range graded {
+			if sub.MaxScore != nil {
+				analysis.MaxScore = max(analysis.MaxScore, *sub.MaxScore)
+			}
+		}
+	}
+
+	// 3. 构造得分矩阵 scores[i][j]：第 i 份提交在第 j 道题上的得分，
+	// nil 表示缺失（随机抽题时没有抽到该题、该题被条件逻辑隐藏、作答的版本中该题不计分，或人工阅卷题尚未评分）；
+	// 不同版本中同一道题的分值可能不同，ratios[i][j] 按作答版本中该题的分值换算为得分率
+	answers := make([]map[string]json.RawMessage, len(graded))
+	scores := make([][]*int, len(graded))
+	ratios := make([][]float64, len(graded))
+	totals := make([]float64, len(graded))
+	for i, sub := range graded {
+		_ = json.Unmarshal(sub.Data, &answers[i])
+		totals[i] = float64(*sub.RawScore)
+		scores[i] = make([]*int, len(items))
+		ratios[i] = make([]float64, len(items))
+		rowDef := rowDefinition(def, versionDefs, &sub)
+		visible := servedDefinition(rowDef, &sub).Visible(answers[i])
+		for j, item := range items {
+			q := rowDef.QuestionByID(item.ID)
+			if q == nil || q.Score <= 0 || !visible[q.ID] {
+				continue
+			}
+			var points int
+			switch {
+			case rowDef.ManuallyGraded(q):
+				if !question.IsEmptyAnswer(answers[i][q.ID]) {
+					manual, ok := manualPoints[sub.ID][q.ID]
+					if !ok {
+						continue
+					}
+					points = manual
+				}
+			case q.AutoGradable():
+				points = q.AutoGrade(answers[i][q.ID])
+			default:
+				continue
+			}
+			scores[i][j] = &points
+			ratios[i][j] = float64(points) / float64(q.Score)
+		}
+	}
+
+	// 4. 整卷统计
+	if len(graded) > 0 {
+		analysis.Mean = mean(totals)
+		analysis.StdDev = math.Sqrt(variance(totals))
+		analysis.Median = median(totals)
+		analysis.ScoreDistribution = buildHistogram(graded, analysis.MaxScore)
+	}
+	analysis.CronbachAlpha = cronbachAlpha(scores, len(items))
+
+	// 5. 逐题分析；高分组和低分组各取总分排名前后 27% 的提交
+	groupSize := int(math.Ceil(float64(len(graded)) * groupRatio))
+	lower := func(i int) bool { return i < groupSize }
+	upper := func(i int) bool { return i >= len(graded)-groupSize }
+	for j, q := range items {
+		item := ItemStat{
+			QuestionID:   q.ID,
+			QuestionType: q.Type,
+			Title:        q.Title,
+			MaxScore:     q.Score,
+		}
+
+		var sum, upperSum, lowerSum float64
+		var upperN, lowerN int
+		for i := range graded {
+			if scores[i][j] == nil {
+				continue
+			}
+			ratio := ratios[i][j]
+			item.Responses++
+			sum += ratio
+			if upper(i) {
+				upperSum += ratio
+				upperN++
+			}
+			if lower(i) {
+				lowerSum += ratio
+				lowerN++
+			}
+		}
+		if item.Responses > 0 {
+			difficulty := sum / float64(item.Responses)
+			item.Difficulty = &difficulty
+		}
+		// 样本太少时高低分组会重叠，区分度没有意义
+		if upperN > 0 && lowerN > 0 && len(graded) >= 2*groupSize {
+			discrimination := upperSum/float64(upperN) - lowerSum/float64(lowerN)
+			item.Discrimination = &discrimination
+		}
+
+		if len(q.Options) > 0 {
+			item.Distractors = analyzeDistractors(q, answers, upper, lower)
+		}
+		analysis.Items = append(analysis.Items, item)
+	}
+
+	return analysis, nil
+}
+
+// analyzeDistractors 统计每个选项在全体、高分组和低分组中被选择的次数
+func analyzeDistractors(q *question.Question, answers []map[string]json.RawMessage, upper, lower func(i int) bool) []DistractorStat {
+	correct := make(map[string]bool)
+	var correctIDs []string
+	if err := json.Unmarshal(q.CorrectAnswer, &correctIDs); err != nil {
+		var correctID string
+		if json.Unmarshal(q.CorrectAnswer, &correctID) == nil {
+			correctIDs = []string{correctID}
+		}
+	}
+	for _, id := range correctIDs {
+		correct[id] = true
+	}
+
+	index := make(map[string]int, len(q.Options))
+	stats := make([]DistractorStat, 0, len(q.Options))
+	for _, opt := range q.Options {
+		index[opt.ID] = len(stats)
+		stats = append(stats, DistractorStat{OptionID: opt.ID, Text: opt.Text, IsCorrect: correct[opt.ID]})
+	}
+
+	responded := 0
+	for i, ans := range answers {
+		selected := selectedOptions(ans[q.ID])
+		if len(selected) == 0 {
+			continue
+		}
+		responded++
+		for _, optID := range selected {
+			k, ok := index[optID]
+			if !ok {
+				continue
+			}
+			stats[k].Count++
+			if upper(i) {
+				stats[k].UpperCount++
+			}
+			if lower(i) {
+				stats[k].LowerCount++
+			}
+		}
+	}
+	if responded > 0 {
+		for k := range stats {
+			stats[k].Proportion = float64(stats[k].Count) / float64(responded)
+		}
+	}
+	return stats
+}
+
+// selectedOptions 解析选择类答案中选中的选项ID，单选题为字符串，多选题为字符串数组
+func selectedOptions(raw json.RawMessage) []string {
+	var optID string
+	if err := json.Unmarshal(raw, &optID); err == nil {
+		if optID == "" {
+			return nil
+		}
+		return []string{optID}
+	}
+	var optIDs []string
+	_ = json.Unmarshal(raw, &optIDs)
+	return optIDs
+}
+
+// buildHistogram 按总分生成成绩分布直方图
+// 总分不超过 histogramBuckets 时每分一组，否则平均分成 histogramBuckets 组
+func buildHistogram(graded []model.Submission, maxScore int) []ScoreBucket {
+	lowest, highest := *graded[0].RawScore, *graded[len(graded)-1].RawScore
+	lo, hi := min(0, lowest), max(maxScore, highest) // 允许负分时得分可能低于 0
+	width := int(math.Ceil(float64(hi-lo+1) / histogramBuckets))
+	if width < 1 {
+		width = 1
+	}
+
+	buckets := make([]ScoreBucket, 0, histogramBuckets)
+	for start := lo; start <= hi; start += width {
+		buckets = append(buckets, ScoreBucket{Min: start, Max: min(start+width-1, hi)})
+	}
+	for _, sub := range graded {
+		buckets[(*sub.RawScore-lo)/width].Count++
+	}
+	return buckets
+}
+
+// cronbachAlpha 计算 Cronbach's alpha：k/(k-1) * (1 - Σ题目方差 / 总分方差)
+// 只使用所有题目都有得分的提交，因此随机抽题的考试通常无法计算
+func cronbachAlpha(scores [][]*int, k int) *float64 {
+	if k < 2 {
+		return nil
+	}
+	var rows [][]float64
+	for _, row := range scores {
+		complete := make([]float64, 0, k)
+		for _, v := range row {
+			if v == nil {
+				break
+			}
+			complete = append(complete, float64(*v))
+		}
+		if len(complete) == k {
+			rows = append(rows, complete)
+		}
+	}
+	if len(rows) < 2 {
+		return nil
+	}
+
+	totals := make([]float64, len(rows))
+	itemVarianceSum := 0.0
+	column := make([]float64, len(rows))
+	for j := 0; j < k; j++ {
+		for i, row := range rows {
+			column[i] = row[j]
+			totals[i] += row[j]
+		}
+		itemVarianceSum += variance(column)
+	}
+	totalVariance := variance(totals)
+	if totalVariance == 0 {
+		return nil
+	}
+	alpha := float64(k) / float64(k-1) * (1 - itemVarianceSum/totalVariance)
+	return &alpha
+}
+
+// mean 计算平均值
+func mean(values []float64) float64 {
+	sum := 0.0
+	for _, v := range values {
+		sum += v
+	}
+	return sum / float64(len(values))
+}
+
+// variance 计算总体方差
+func variance(values []float64) float64 {
+	m := mean(values)
+	sum := 0.0
+	for _, v := range values {
+		sum += (v - m) * (v - m)
+	}
+	return sum / float64(len(values))
+}
+
+// median 计算中位数，values 需已按升序排列
+func median(values []float64) float64 {
+	n := len(values)
+	if n%2 == 1 {
+		return values[n/2]
+	}
+	return (values[n/2-1] + values[n/2]) / 2
+}
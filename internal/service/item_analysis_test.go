@@ -0,0 +1,78 @@
+package service
+
+import (
+	"math"
+	"testing"
+)
+
+// ints 把分数转换为 cronbachAlpha 使用的格式，负数表示该题没有得分
+func ints(values ...int) []*int {
+	row := make([]*int, len(values))
+	for i, v := range values {
+		if v >= 0 {
+			v := v
+			row[i] = &v
+		}
+	}
+	return row
+}
+
+func TestCronbachAlpha(t *testing.T) {
+	tests := []struct {
+		name   string
+		scores [][]*int
+		k      int
+		want   *float64
+	}{
+		{
+			name:   "perfectly consistent items",
+			scores: [][]*int{ints(1, 1, 1), ints(0, 0, 0), ints(1, 1, 1), ints(0, 0, 0)},
+			k:      3,
+			want:   float64Ptr(1),
+		},
+		{
+			name:   "typical items",
+			scores: [][]*int{ints(2, 3, 3), ints(1, 1, 2), ints(3, 3, 2), ints(0, 1, 1)},
+			k:      3,
+			want:   float64Ptr(8.0 / 9), // 题目方差之和 2.75，总分方差 6.75
+		},
+		{
+			name:   "incomplete rows are skipped",
+			scores: [][]*int{ints(1, 1), ints(0, 0), ints(1, -1), ints(-1, 0)},
+			k:      2,
+			want:   float64Ptr(1),
+		},
+		{
+			name:   "single item",
+			scores: [][]*int{ints(1), ints(0)},
+			k:      1,
+		},
+		{
+			name:   "fewer than two complete rows",
+			scores: [][]*int{ints(1, 1), ints(0, -1)},
+			k:      2,
+		},
+		{
+			name:   "no variance in totals",
+			scores: [][]*int{ints(1, 0), ints(0, 1)},
+			k:      2,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := cronbachAlpha(tt.scores, tt.k)
+			switch {
+			case tt.want == nil && got != nil:
+				t.Errorf("cronbachAlpha = %v, want nil", *got)
+			case tt.want != nil && got == nil:
+				t.Errorf("cronbachAlpha = nil, want %v", *tt.want)
+			case tt.want != nil && math.Abs(*got-*tt.want) > 1e-9:
+				t.Errorf("cronbachAlpha = %v, want %v", *got, *tt.want)
+			}
+		})
+	}
+}
+
+func float64Ptr(v float64) *float64 {
+	return &v
+}
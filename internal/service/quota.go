@@ -0,0 +1,191 @@
+// Package service 包含了应用的业务逻辑
+package service
+
+import (
+	"context"
+	"errors"
+	"log"
+	"questflow/internal/question"
+	"questflow/internal/repository"
+	"questflow/pkg/redis"
+
+	"gorm.io/gorm"
+)
+
+// quotaCounter 是一次提交需要占用的一个计数器：表单的总提交数或某个选项配额的提交数
+type quotaCounter struct {
+	key   string
+	limit int64
+	quota *question.Quota // 为 nil 时是总提交数
+}
+
+// QuotaStat 是单个配额的使用情况
+type QuotaStat struct {
+	ID    string `json:"id"`
+	Title string `json:"title"`
+	Limit int    `json:"limit"`
+	Count int64  `json:"count"` // 已写入数据库的提交数
+}
+
+// quotaCounters 返回一次提交需要占用的计数器：设置了 maxResponses 时包含总提交数，
+// 以及答案命中的所有选项配额
+func quotaCounters(formID uint, def *question.Definition, data []byte) []quotaCounter {
+	var counters []quotaCounter
+	if def.Settings.MaxResponses > 0 {
+		counters = append(counters, quotaCounter{key: redis.QuotaKey(formID, "total"), limit: int64(def.Settings.MaxResponses)})
+	}
+	for _, qt := range def.MatchedQuotas(data) {
+		counters = append(counters, quotaCounter{key: quotaCounterKey(formID, qt), limit: int64(qt.Limit), quota: &qt})
+	}
+	return counters
+}
+
+// quotaCounterKey 返回选项配额的计数器 key。key 由问题和选项决定，
+// 修改配额的上限不影响已有的计数，修改配额对应的选项时从数据库重新计数
+func quotaCounterKey(formID uint, qt question.Quota) string {
+	return redis.QuotaKey(formID, "option:"+qt.QuestionID+":"+qt.OptionID)
+}
+
+// countQuota 从数据库中统计计数器对应的实际提交数
+func countQuota(repo repository.SubmissionRepository, formID uint, c quotaCounter) (int64, error) {
+	if c.quota == nil {
+		return repo.CountByFormID(formID)
+	}
+	return repo.CountByAnswerOption(formID, c.quota.QuestionID, c.quota.OptionID)
+}
+
+// quotaFullError 返回计数器已满时报告给客户端的错误
+func quotaFullError(c quotaCounter) error {
+	if c.quota == nil {
+		return errors.New("response limit reached")
+	}
+	return errors.New("quota is full")
+}
+
+// reserveQuotas 在发布到队列前原子地占用提交需要的所有计数器，任意一个已满时不占用任何计数器。
+// 计数器不存在时（首次提交或 Redis 数据丢失）先用数据库中的提交数初始化。
+// 未启用 Redis 时退化为按数据库中的提交数检查，已入队但尚未写入的提交不计入，可能略微超出上限
+func reserveQuotas(ctx context.Context, repo repository.SubmissionRepository, formID uint, counters []quotaCounter) error {
+	if len(counters) == 0 {
+		return nil
+	}
+	if !redis.Enabled() {
+		for _, c := range counters {
+			count, err := countQuota(repo, formID, c)
+			if err != nil {
+				return errors.New("failed to check response quota")
+			}
+			if count >= c.limit {
+				return quotaFullError(c)
+			}
+		}
+		return nil
+	}
+
+	keys := make([]string, len(counters))
+	limits := make([]int64, len(counters))
+	for i, c := range counters {
+		keys[i], limits[i] = c.key, c.limit
+	}
+	missing, err := redis.MissingQuotaKeys(ctx, keys)
+	if err != nil {
+		return errors.New("failed to check response quota")
+	}
+	if len(missing) > 0 {
+		counts := make(map[string]int64, len(missing))
+		for _, key := range missing {
+			counts[key] = 0
+		}
+		for _, c := range counters {
+			if _, ok := counts[c.key]; !ok {
+				continue
+			}
+			if counts[c.key], err = countQuota(repo, formID, c); err != nil {
+				return errors.New("failed to check response quota")
+			}
+		}
+		if err := redis.RaiseQuotaCounters(ctx, counts); err != nil {
+			return errors.New("failed to check response quota")
+		}
+	}
+
+	full, err := redis.ReserveQuotas(ctx, keys, limits)
+	if err != nil {
+		return errors.New("failed to check response quota")
+	}
+	if full >= 0 {
+		return quotaFullError(counters[full])
+	}
+	return nil
+}
+
+// releaseQuotas 归还提交占用的计数器，失败只记录日志，计数器会在下次校准时修正
+func releaseQuotas(ctx context.Context, keys []string) {
+	if err := redis.ReleaseQuotas(ctx, keys); err != nil {
+		log.Printf("Failed to release quota counters %v: %v", keys, err)
+	}
+}
+
+// ReconcileQuotas (消费者逻辑): 提交写入数据库后用实际提交数校准表单的计数器，
+// 并在总提交数达到 maxResponses 时自动关闭表单
+func (s *submissionServiceImpl) ReconcileQuotas(formID uint) error {
+	form, err := s.formRepo.FindByID(formID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil // 表单已被删除
+		}
+		return err
+	}
+	def, err := question.ParseDefinition(form.Definition)
+	if err != nil {
+		return err
+	}
+
+	var counters []quotaCounter
+	if def.Settings.MaxResponses > 0 {
+		counters = append(counters, quotaCounter{key: redis.QuotaKey(formID, "total"), limit: int64(def.Settings.MaxResponses)})
+	}
+	for _, qt := range def.Quotas {
+		if qt.Limit > 0 {
+			counters = append(counters, quotaCounter{key: quotaCounterKey(formID, qt), limit: int64(qt.Limit), quota: &qt})
+		}
+	}
+	if len(counters) == 0 {
+		return nil
+	}
+
+	counts := make(map[string]int64, len(counters))
+	for _, c := range counters {
+		if counts[c.key], err = countQuota(s.submissionRepo, formID, c); err != nil {
+			return err
+		}
+		if c.quota == nil && counts[c.key] >= c.limit && form.Status == 2 {
+			closed, err := s.formRepo.CloseIfPublished(formID)
+			if err != nil {
+				return err
+			}
+			if closed {
+				log.Printf("Form %d reached its response limit of %d and was closed", formID, c.limit)
+			}
+		}
+	}
+	return redis.RaiseQuotaCounters(context.Background(), counts)
+}
+
+// ReleaseQuotas (消费者逻辑): 提交最终未能写入数据库（转入死信队列）时归还它占用的计数器
+func (s *submissionServiceImpl) ReleaseQuotas(msg SubmissionMessage) {
+	releaseQuotas(context.Background(), msg.QuotaKeys)
+}
+
+// buildQuotaStats 统计表单每个配额已写入数据库的提交数
+func buildQuotaStats(repo repository.SubmissionRepository, formID uint, def *question.Definition) ([]QuotaStat, error) {
+	stats := make([]QuotaStat, 0, len(def.Quotas))
+	for _, qt := range def.Quotas {
+		count, err := repo.CountByAnswerOption(formID, qt.QuestionID, qt.OptionID)
+		if err != nil {
+			return nil, err
+		}
+		stats = append(stats, QuotaStat{ID: qt.ID, Title: qt.DisplayTitle(def), Limit: qt.Limit, Count: count})
+	}
+	return stats, nil
+}
@@ -0,0 +1,92 @@
+package service
+
+import (
+	"context"
+	"questflow/internal/question"
+	"questflow/internal/repository"
+	"reflect"
+	"testing"
+)
+
+// fakeCountRepository 只实现配额检查用到的计数方法，其余方法调用时会 panic
+type fakeCountRepository struct {
+	repository.SubmissionRepository
+	total   int64
+	options map[string]int64 // "questionID:optionID" -> 提交数
+}
+
+func (r *fakeCountRepository) CountByFormID(formID uint) (int64, error) {
+	return r.total, nil
+}
+
+func (r *fakeCountRepository) CountByAnswerOption(formID uint, questionID, optionID string) (int64, error) {
+	return r.options[questionID+":"+optionID], nil
+}
+
+const quotaDefinition = `{
+	"settings": {"maxResponses": 100},
+	"questions": [
+		{"id": "age", "type": "single_choice", "options": [{"id": "young"}, {"id": "old"}]},
+		{"id": "tags", "type": "multi_choice", "options": [{"id": "x"}, {"id": "y"}]}
+	],
+	"quotas": [
+		{"id": "q-young", "questionId": "age", "optionId": "young", "limit": 10},
+		{"id": "q-x", "questionId": "tags", "optionId": "x", "limit": 5}
+	]
+}`
+
+func TestQuotaCounters(t *testing.T) {
+	def, err := question.ParseDefinition([]byte(quotaDefinition))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name string
+		data string
+		want []string
+	}{
+		{"total only", `{"age": "old"}`, []string{":quota:7:total"}},
+		{"matched quotas", `{"age": "young", "tags": ["x", "y"]}`, []string{":quota:7:total", ":quota:7:option:age:young", ":quota:7:option:tags:x"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var keys []string
+			for _, c := range quotaCounters(7, def, []byte(tt.data)) {
+				keys = append(keys, c.key)
+			}
+			if !reflect.DeepEqual(keys, tt.want) {
+				t.Errorf("quotaCounters(%s) = %v, want %v", tt.data, keys, tt.want)
+			}
+		})
+	}
+}
+
+// 未启用 Redis 时按数据库中的提交数检查配额
+func TestReserveQuotasWithoutRedis(t *testing.T) {
+	def, err := question.ParseDefinition([]byte(quotaDefinition))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name    string
+		repo    *fakeCountRepository
+		data    string
+		wantErr string
+	}{
+		{"below every limit", &fakeCountRepository{total: 99, options: map[string]int64{"age:young": 9}}, `{"age": "young"}`, ""},
+		{"response limit reached", &fakeCountRepository{total: 100}, `{"age": "old"}`, "response limit reached"},
+		{"option quota full", &fakeCountRepository{total: 20, options: map[string]int64{"tags:x": 5}}, `{"age": "old", "tags": ["x"]}`, "quota is full"},
+		{"full quota of another option", &fakeCountRepository{total: 20, options: map[string]int64{"tags:x": 5}}, `{"age": "old", "tags": ["y"]}`, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := reserveQuotas(context.Background(), tt.repo, 7, quotaCounters(7, def, []byte(tt.data)))
+			if tt.wantErr == "" && err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if tt.wantErr != "" && (err == nil || err.Error() != tt.wantErr) {
+				t.Fatalf("error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
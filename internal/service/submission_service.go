@@ -5,84 +5,362 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
+	"log"
 	"questflow/internal/model"
+	"questflow/internal/question"
+	"questflow/internal/queue"
 	"questflow/internal/repository"
 	"questflow/pkg/redis" // 只导入我们自己的 redis 包
 	"time"
 
 	"gorm.io/datatypes"
+	"gorm.io/gorm"
 )
 
 // SubmissionMessage 定义了发送到消息队列的提交数据的结构
 type SubmissionMessage struct {
-	FormID      uint            `json:"form_id"`
-	Data        json.RawMessage `json:"data"`
-	ClientIP    string          `json:"client_ip"`
-	UserAgent   string          `json:"user_agent"`
-	SubmitterID *uint           `json:"submitter_id,omitempty"`
-	SubmittedAt time.Time       `json:"submitted_at"`
+	FormID         uint            `json:"form_id"`
+	FormVersionID  *uint           `json:"form_version_id,omitempty"` // 提交时表单最近发布的版本
+	Data           json.RawMessage `json:"data"`
+	ClientIP       string          `json:"client_ip"`
+	UserAgent      string          `json:"user_agent"`
+	SubmitterID    *uint           `json:"submitter_id,omitempty"`
+	IdempotencyKey string          `json:"idempotency_key,omitempty"`
+	// 以下字段由服务器根据答题令牌计算，客户端无法伪造
+	DurationSeconds *uint     `json:"duration_seconds,omitempty"`
+	Overtime        bool      `json:"overtime,omitempty"`
+	ServedQuestions []string  `json:"served_questions,omitempty"` // 随机抽题时本次答题看到的问题ID
+	SubmittedAt     time.Time `json:"submitted_at"`
+
+	HiddenFields map[string]string `json:"hidden_fields,omitempty"` // 校验并补上默认值后的隐藏字段
+	QuotaKeys    []string          `json:"quota_keys,omitempty"`    // 发布时占用的配额计数器，提交未能写入时归还
+	FileTokens   []string          `json:"file_tokens,omitempty"`   // 答案引用的上传文件，写入时绑定到该提交
+}
+
+// SubmissionInput 封装了一次提交请求中来自客户端的信息
+type SubmissionInput struct {
+	Data           datatypes.JSON
+	ClientIP       string
+	UserAgent      string
+	SubmitterID    *uint
+	IdempotencyKey string            // 可选，相同表单下相同的键只会入队一次
+	AttemptToken   string            // 开始答题时签发的令牌，限时或随机出题的表单必须提供
+	HiddenFields   map[string]string // 打开表单时从 URL 取得的隐藏字段
 }
 
 // SubmissionService 定义了提交服务的接口
 type SubmissionService interface {
-	CreateSubmission(form *model.Form, data datatypes.JSON, clientIP string, userAgent string, submitterID *uint) (string, error)
-	ProcessSubmission(msg SubmissionMessage) error
+	StartAttempt(form *model.Form, clientIP string) (*Attempt, error)
+	CreateSubmission(form *model.Form, input SubmissionInput) (string, error)
+	ProcessSubmission(msg SubmissionMessage) (uint, error)
+	ProcessSubmissionBatch(msgs []SubmissionMessage) ([]uint, error)
+	GetSubmissionStatus(messageID string) (*redis.SubmissionStatus, error)
+	ReconcileQuotas(formID uint) error
+	ReleaseQuotas(msg SubmissionMessage)
 }
 
 // submissionServiceImpl 是 SubmissionService 的实现
 type submissionServiceImpl struct {
 	submissionRepo repository.SubmissionRepository
+	formRepo       repository.FormRepository
+	versionRepo    repository.FormVersionRepository
+	fileRepo       repository.UploadedFileRepository
+	queue          queue.SubmissionQueue
 }
 
 // NewSubmissionService 创建一个新的 SubmissionService 实例
-func NewSubmissionService(repo repository.SubmissionRepository) SubmissionService {
-	return &submissionServiceImpl{submissionRepo: repo}
+// fileRepo 和 q 只在生产者一侧 (CreateSubmission) 使用，consumer 可以传入 nil
+func NewSubmissionService(repo repository.SubmissionRepository, formRepo repository.FormRepository, versionRepo repository.FormVersionRepository, fileRepo repository.UploadedFileRepository, q queue.SubmissionQueue) SubmissionService {
+	return &submissionServiceImpl{submissionRepo: repo, formRepo: formRepo, versionRepo: versionRepo, fileRepo: fileRepo, queue: q}
 }
 
-// CreateSubmission (生产者逻辑): 调用封装好的 Redis 发布方法
-func (s *submissionServiceImpl) CreateSubmission(form *model.Form, data datatypes.JSON, clientIP string, userAgent string, submitterID *uint) (string, error) {
+// CreateSubmission (生产者逻辑): 校验后将提交消息发布到消息队列
+// 携带幂等键的重复请求直接返回首次请求的 message_id，不会再次入队，也不会再次占用配额
+func (s *submissionServiceImpl) CreateSubmission(form *model.Form, input SubmissionInput) (string, error) {
 	// 1. 业务校验 (在 Web 服务中快速完成)
 	if form.Status != 2 { // 假设 2 代表 "已发布"
 		return "", errors.New("form is not published")
 	}
 
-	// 2. 构造消息
+	// 2. 解析表单定义；限时或随机抽题的表单必须携带答题令牌
+	def, err := question.ParseDefinition(form.Definition)
+	if err != nil {
+		return "", errors.New("failed to parse form definition")
+	}
+	if input.AttemptToken == "" && (def.Settings.TimeLimit > 0 || len(def.Pools) > 0) {
+		return "", errors.New("attempt token is required")
+	}
+
+	// 3. 构造消息；用时由服务器根据令牌中的开始时间计算
 	msg := SubmissionMessage{
-		FormID:      form.ID,
-		Data:        json.RawMessage(data),
-		ClientIP:    clientIP,
-		UserAgent:   userAgent,
-		SubmitterID: submitterID,
-		SubmittedAt: time.Now(),
+		FormID:         form.ID,
+		FormVersionID:  form.PublishedVersionID,
+		Data:           json.RawMessage(input.Data),
+		ClientIP:       input.ClientIP,
+		UserAgent:      input.UserAgent,
+		SubmitterID:    input.SubmitterID,
+		IdempotencyKey: input.IdempotencyKey,
+		SubmittedAt:    time.Now(),
 	}
+	if input.AttemptToken != "" {
+		timing, err := verifyAttemptToken(input.AttemptToken, form.ID, def.Settings, msg.SubmittedAt)
+		if err != nil {
+			return "", err
+		}
+		msg.DurationSeconds = &timing.DurationSeconds
+		msg.Overtime = timing.Overtime
+		// 同一次答题只允许入队一次：客户端未提供幂等键时使用答题ID
+		if msg.IdempotencyKey == "" {
+			msg.IdempotencyKey = "attempt:" + timing.AttemptID
+		}
+		// 开始答题后表单被修改时，按开始答题时发布的版本抽题和校验答案，提交也归到该版本
+		version, err := attemptVersion(s.versionRepo, form, timing)
+		if err != nil {
+			return "", err
+		}
+		if version != nil {
+			if def, err = question.ParseDefinition(version.Definition); err != nil {
+				return "", errors.New("failed to parse form definition")
+			}
+			msg.FormVersionID = &version.ID
+		}
+		// 随机抽题时，只按本次答题看到的问题校验答案
+		if len(def.Pools) > 0 {
+			msg.ServedQuestions, _ = def.Draw(attemptSeed(timing.AttemptID))
+			def = def.Subset(msg.ServedQuestions)
+		}
+	}
+
+	// 4. 按表单定义校验答案，拒绝不合法的数据进入消息队列
+	if err := ValidateSubmissionData(def, input.Data); err != nil {
+		return "", err
+	}
+	if msg.FileTokens, err = validateFileTokens(s.fileRepo, form.ID, def, input.Data); err != nil {
+		return "", err
+	}
+	if msg.HiddenFields, err = ValidateHiddenFields(def, input.HiddenFields); err != nil {
+		return "", err
+	}
+	counters := quotaCounters(form.ID, def, input.Data)
+	for _, c := range counters {
+		msg.QuotaKeys = append(msg.QuotaKeys, c.key)
+	}
+
 	msgBytes, err := json.Marshal(msg)
 	if err != nil {
 		return "", errors.New("failed to serialize submission message")
 	}
 
-	// 3. 调用我们封装好的方法发送消息
-	messageID, err := redis.PublishSubmissionMessage(context.Background(), msgBytes)
+	// 5. 幂等检查：键已被占用时直接返回首次请求的 message_id
+	ctx := context.Background()
+	if msg.IdempotencyKey != "" {
+		reserved, existingID, err := redis.ReserveIdempotencyKey(ctx, form.ID, msg.IdempotencyKey)
+		switch {
+		case errors.Is(err, redis.ErrIdempotencyUnavailable) && input.IdempotencyKey == "":
+			// 未启用 Redis 时，由答题ID生成的幂等键只由数据库唯一索引保证：
+			// 重复入队的消息写入时合并为同一条提交，但重试会得到新的 message_id
+		case errors.Is(err, redis.ErrIdempotencyUnavailable):
+			// 客户端提供的幂等键要求重试返回相同的 message_id，无法保证时拒绝提交
+			return "", err
+		case err != nil:
+			return "", errors.New("failed to check idempotency key")
+		case !reserved && existingID == "":
+			return "", errors.New("submission is already in progress")
+		case !reserved:
+			return existingID, nil
+		}
+	}
+
+	// 6. 占用总提交数和选项配额，已满时拒绝提交
+	if err := reserveQuotas(ctx, s.submissionRepo, form.ID, counters); err != nil {
+		if msg.IdempotencyKey != "" {
+			if err := redis.ReleaseIdempotencyKey(ctx, form.ID, msg.IdempotencyKey); err != nil {
+				log.Printf("Failed to release idempotency key %s: %v", msg.IdempotencyKey, err)
+			}
+		}
+		return "", err
+	}
+
+	// 7. 发布到消息队列
+	messageID, err := s.queue.Publish(ctx, msgBytes)
 	if err != nil {
-		return "", errors.New("failed to publish submission message to stream")
+		releaseQuotas(ctx, msg.QuotaKeys)
+		if msg.IdempotencyKey != "" {
+			if err := redis.ReleaseIdempotencyKey(ctx, form.ID, msg.IdempotencyKey); err != nil {
+				log.Printf("Failed to release idempotency key %s: %v", msg.IdempotencyKey, err)
+			}
+		}
+		return "", errors.New("failed to publish submission message to queue")
+	}
+	if msg.IdempotencyKey != "" {
+		if err := redis.CompleteIdempotencyKey(ctx, form.ID, msg.IdempotencyKey, messageID); err != nil {
+			log.Printf("Failed to record idempotency key %s: %v", msg.IdempotencyKey, err)
+		}
+	}
+
+	// 8. 记录初始状态，供填写页轮询；失败不影响提交本身
+	if err := redis.SetQueuedSubmissionStatus(ctx, messageID); err != nil {
+		log.Printf("Failed to record status for message %s: %v", messageID, err)
 	}
 
 	return messageID, nil
 }
 
-// ProcessSubmission (消费者逻辑): 包含了写入数据库的逻辑
-func (s *submissionServiceImpl) ProcessSubmission(msg SubmissionMessage) error {
+// StartAttempt 为已发布的表单开始一次答题，返回记录了开始时间的答题令牌
+// 同一 IP 在同一表单上开始答题的频率受 exam.attempts_per_minute 限制
+func (s *submissionServiceImpl) StartAttempt(form *model.Form, clientIP string) (*Attempt, error) {
+	if form.Status != 2 {
+		return nil, errors.New("form is not published")
+	}
+	allowed, err := redis.Allow(context.Background(), fmt.Sprintf("attempt:%d:%s", form.ID, clientIP), attemptsPerMinute(), time.Minute)
+	if err != nil {
+		return nil, errors.New("failed to check attempt rate limit")
+	}
+	if !allowed {
+		return nil, errors.New("too many attempts")
+	}
+	def, err := question.ParseDefinition(form.Definition)
+	if err != nil {
+		return nil, errors.New("failed to parse form definition")
+	}
+	return issueAttemptToken(form.ID, form.PublishedVersionID, def.Settings)
+}
+
+// GetSubmissionStatus 查询提交消息的处理状态
+func (s *submissionServiceImpl) GetSubmissionStatus(messageID string) (*redis.SubmissionStatus, error) {
+	return redis.GetSubmissionStatus(context.Background(), messageID)
+}
+
+// ProcessSubmission (消费者逻辑): 包含了写入数据库的逻辑，返回新提交记录的ID
+func (s *submissionServiceImpl) ProcessSubmission(msg SubmissionMessage) (uint, error) {
+	if s.submissionRepo == nil {
+		return 0, errors.New("submission repository is not initialized")
+	}
+
+	submission := newSubmissionFromMessage(msg)
+	if err := s.gradeSubmissions(submission); err != nil {
+		return 0, err
+	}
+	if err := s.submissionRepo.Create(submission, msg.FileTokens); err != nil {
+		// 幂等键冲突说明这次提交已经写入过（例如消息被重复投递），直接返回已有记录
+		if errors.Is(err, gorm.ErrDuplicatedKey) && msg.IdempotencyKey != "" {
+			existing, findErr := s.submissionRepo.FindByIdempotencyKey(msg.FormID, msg.IdempotencyKey)
+			if findErr != nil {
+				return 0, findErr
+			}
+			return existing.ID, nil
+		}
+		return 0, err
+	}
+	return submission.ID, nil
+}
+
+// ProcessSubmissionBatch (消费者逻辑): 在一个事务中批量写入多条提交，任一失败则全部回滚
+// 返回的ID与 msgs 一一对应
+func (s *submissionServiceImpl) ProcessSubmissionBatch(msgs []SubmissionMessage) ([]uint, error) {
 	if s.submissionRepo == nil {
-		return errors.New("submission repository is not initialized")
+		return nil, errors.New("submission repository is not initialized")
+	}
+
+	submissions := make([]*model.Submission, 0, len(msgs))
+	fileTokens := make([][]string, 0, len(msgs))
+	for _, msg := range msgs {
+		submissions = append(submissions, newSubmissionFromMessage(msg))
+		fileTokens = append(fileTokens, msg.FileTokens)
+	}
+	if err := s.gradeSubmissions(submissions...); err != nil {
+		return nil, err
+	}
+	if err := s.submissionRepo.CreateBatch(submissions, fileTokens); err != nil {
+		return nil, err
 	}
 
-	newSubmission := &model.Submission{
-		FormID:      msg.FormID,
-		SubmitterID: msg.SubmitterID,
-		Data:        datatypes.JSON(msg.Data),
-		ClientIP:    msg.ClientIP,
-		UserAgent:   msg.UserAgent,
-		CreatedAt:   msg.SubmittedAt,
+	ids := make([]uint, 0, len(submissions))
+	for _, sub := range submissions {
+		ids = append(ids, sub.ID)
 	}
+	return ids, nil
+}
+
+// gradeSubmissions 按提交所属版本的表单定义中的标准答案为提交记录填充 RawScore 和 MaxScore
+// 没有版本的提交使用表单当前的定义；同一版本或表单的定义只加载一次，表单已被删除或定义无法解析时不判分
+func (s *submissionServiceImpl) gradeSubmissions(submissions ...*model.Submission) error {
+	versions := newVersionDefinitions(s.versionRepo)
+	defs := make(map[uint]*question.Definition)
+	for _, sub := range submissions {
+		def, err := versions.get(sub)
+		if err != nil {
+			return err
+		}
+		if def == nil {
+			def, err = s.formDefinition(defs, sub.FormID)
+			if err != nil {
+				return err
+			}
+		}
+		if def == nil {
+			continue
+		}
+		if score := servedDefinition(def, sub).Grade(sub.Data); score != nil {
+			sub.RawScore = &score.RawScore
+			sub.MaxScore = &score.MaxScore
+		}
+	}
+	return nil
+}
+
+// formDefinition 返回表单当前的定义并缓存在 defs 中；表单已被删除或定义无法解析时返回 nil
+func (s *submissionServiceImpl) formDefinition(defs map[uint]*question.Definition, formID uint) (*question.Definition, error) {
+	def, loaded := defs[formID]
+	if !loaded {
+		form, err := s.formRepo.FindByID(formID)
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, err
+		}
+		if err == nil {
+			def, _ = question.ParseDefinition(form.Definition)
+		}
+		defs[formID] = def
+	}
+	return def, nil
+}
 
-	return s.submissionRepo.Create(newSubmission)
+// servedDefinition 返回提交时答题者实际看到的问题组成的定义；未随机抽题时返回完整定义
+func servedDefinition(def *question.Definition, sub *model.Submission) *question.Definition {
+	questionIDs := sub.ServedQuestionIDs()
+	if questionIDs == nil {
+		return def
+	}
+	return def.Subset(questionIDs)
+}
+
+// newSubmissionFromMessage 将队列消息转换为待写入的提交记录
+func newSubmissionFromMessage(msg SubmissionMessage) *model.Submission {
+	var idempotencyKey *string
+	if msg.IdempotencyKey != "" {
+		idempotencyKey = &msg.IdempotencyKey
+	}
+	var servedQuestions datatypes.JSON
+	if len(msg.ServedQuestions) > 0 {
+		servedQuestions, _ = json.Marshal(msg.ServedQuestions)
+	}
+	var hiddenFields datatypes.JSON
+	if len(msg.HiddenFields) > 0 {
+		hiddenFields, _ = json.Marshal(msg.HiddenFields)
+	}
+	return &model.Submission{
+		FormID:          msg.FormID,
+		FormVersionID:   msg.FormVersionID,
+		ServedQuestions: servedQuestions,
+		SubmitterID:     msg.SubmitterID,
+		Data:            datatypes.JSON(msg.Data),
+		ClientIP:        msg.ClientIP,
+		UserAgent:       msg.UserAgent,
+		IdempotencyKey:  idempotencyKey,
+		DurationSeconds: msg.DurationSeconds,
+		Overtime:        msg.Overtime,
+		HiddenFields:    hiddenFields,
+		CreatedAt:       msg.SubmittedAt,
+	}
 }
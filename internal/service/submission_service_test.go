@@ -0,0 +1,88 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"questflow/internal/model"
+	"questflow/internal/question"
+	"questflow/internal/queue"
+	"reflect"
+	"testing"
+	"time"
+
+	"gorm.io/datatypes"
+)
+
+// 开始答题后表单换成了另一组题库，本次答题仍按开始答题时发布的版本抽题、校验，提交也归到该版本
+func TestAttemptUsesVersionFromToken(t *testing.T) {
+	v1 := `{"questions": [
+		{"id": "a1", "type": "single_choice", "options": [{"id": "o1"}, {"id": "o2"}]},
+		{"id": "a2", "type": "single_choice", "options": [{"id": "o1"}, {"id": "o2"}]}
+	], "pools": [{"id": "p", "draw": 1, "questionIds": ["a1", "a2"]}]}`
+	v2 := `{"questions": [
+		{"id": "b1", "type": "single_choice", "options": [{"id": "o1"}, {"id": "o2"}]},
+		{"id": "b2", "type": "single_choice", "options": [{"id": "o1"}, {"id": "o2"}]}
+	], "pools": [{"id": "p", "draw": 1, "questionIds": ["b1", "b2"]}]}`
+	versionRepo := &fakeVersionRepository{versions: []model.FormVersion{
+		{ID: 1, FormID: 1, Version: 1, Definition: datatypes.JSON(v1)},
+		{ID: 2, FormID: 1, Version: 2, Definition: datatypes.JSON(v2)},
+	}}
+	v1ID, v2ID := uint(1), uint(2)
+	form := &model.Form{ID: 1, CreatorID: 1, Status: 2, Definition: datatypes.JSON(v2), PublishedVersionID: &v2ID}
+
+	attempt, err := issueAttemptToken(form.ID, &v1ID, question.Settings{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims, err := parseAttemptToken(attempt.Token, form.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	def, err := question.ParseDefinition([]byte(v1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantServed, _ := def.Draw(attemptSeed(claims.ID))
+
+	// 填写页拿到的是版本 1 中抽到的题目
+	formService := NewFormService(&fakeFormRepository{form: form}, versionRepo, &fakeSubmissionRepository{}, nil)
+	public, _, err := formService.GetPublicDefinition(form, attempt.Token, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var served struct {
+		Questions []struct {
+			ID string `json:"id"`
+		} `json:"questions"`
+	}
+	if err := json.Unmarshal(public, &served); err != nil {
+		t.Fatal(err)
+	}
+	if len(served.Questions) != 1 || served.Questions[0].ID != wantServed[0] {
+		t.Fatalf("served questions = %+v, want %v", served.Questions, wantServed)
+	}
+
+	q := queue.NewMemoryQueue()
+	submissionService := NewSubmissionService(&fakeSubmissionRepository{}, nil, versionRepo, nil, q)
+	_, err = submissionService.CreateSubmission(form, SubmissionInput{
+		Data:         datatypes.JSON(`{"` + wantServed[0] + `": "o1"}`),
+		AttemptToken: attempt.Token,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	messages, err := q.Read(context.Background(), "test", 1, time.Millisecond)
+	if err != nil || len(messages) != 1 {
+		t.Fatalf("Read = %v, %v", messages, err)
+	}
+	var msg SubmissionMessage
+	if err := json.Unmarshal(messages[0].Payload, &msg); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(msg.ServedQuestions, wantServed) {
+		t.Errorf("ServedQuestions = %v, want %v", msg.ServedQuestions, wantServed)
+	}
+	if msg.FormVersionID == nil || *msg.FormVersionID != v1ID {
+		t.Errorf("FormVersionID = %v, want %d", msg.FormVersionID, v1ID)
+	}
+}
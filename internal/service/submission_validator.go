@@ -0,0 +1,117 @@
+// Package service 包含了应用的业务逻辑
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"questflow/internal/question"
+	"strings"
+	"unicode/utf8"
+)
+
+// QuestionError 描述了单个问题的校验错误
+type QuestionError struct {
+	QuestionID string `json:"question_id"`
+	Message    string `json:"message"`
+}
+
+// ValidationError 汇总了一次提交中所有问题的校验错误
+type ValidationError struct {
+	Errors []QuestionError
+}
+
+// Error 实现 error 接口
+func (e *ValidationError) Error() string {
+	msgs := make([]string, 0, len(e.Errors))
+	for _, qe := range e.Errors {
+		if qe.QuestionID == "" {
+			msgs = append(msgs, qe.Message)
+			continue
+		}
+		msgs = append(msgs, fmt.Sprintf("%s: %s", qe.QuestionID, qe.Message))
+	}
+	return "invalid submission data: " + strings.Join(msgs, "; ")
+}
+
+// ValidateSubmissionData 按照表单定义校验提交的答案数据
+// 所有问题都会被检查，返回的 *ValidationError 中包含每个出错问题的详细信息
+func ValidateSubmissionData(def *question.Definition, data []byte) error {
+	var answers map[string]json.RawMessage
+	if err := json.Unmarshal(data, &answers); err != nil || answers == nil {
+		return &ValidationError{Errors: []QuestionError{{Message: "data must be a JSON object"}}}
+	}
+
+	var errs []QuestionError
+	addErr := func(qID, msg string) {
+		errs = append(errs, QuestionError{QuestionID: qID, Message: msg})
+	}
+
+	// 1. 拒绝表单定义中不存在的问题
+	for qID := range answers {
+		if def.QuestionByID(qID) == nil {
+			addErr(qID, "unknown question")
+		}
+	}
+
+	// 2. 按定义顺序逐题校验，保证错误信息的顺序稳定；
+	// 被条件逻辑隐藏的问题不要求作答，也不允许作答
+	visible := def.Visible(answers)
+	for i := range def.Questions {
+		q := &def.Questions[i]
+		raw, exists := answers[q.ID]
+		if !visible[q.ID] {
+			if exists && !question.IsEmptyAnswer(raw) {
+				addErr(q.ID, "question is hidden by logic")
+			}
+			continue
+		}
+		if !exists || question.IsEmptyAnswer(raw) {
+			if q.Required {
+				addErr(q.ID, "answer is required")
+			}
+			continue
+		}
+		if msg := validateAnswer(q, raw); msg != "" {
+			addErr(q.ID, msg)
+		}
+	}
+
+	if len(errs) > 0 {
+		return &ValidationError{Errors: errs}
+	}
+	return nil
+}
+
+// ValidateHiddenFields 校验提交中的隐藏字段：只允许定义中存在的字段，值不能超过长度上限；
+// 返回补上默认值后的隐藏字段
+func ValidateHiddenFields(def *question.Definition, values map[string]string) (map[string]string, error) {
+	var errs []QuestionError
+	for name, value := range values {
+		if def.HiddenFieldByName(name) == nil {
+			errs = append(errs, QuestionError{Message: fmt.Sprintf("unknown hidden field %q", name)})
+			continue
+		}
+		if utf8.RuneCountInString(value) > question.MaxHiddenFieldLength {
+			errs = append(errs, QuestionError{Message: fmt.Sprintf("hidden field %q must be at most %d characters", name, question.MaxHiddenFieldLength)})
+		}
+	}
+	if len(errs) > 0 {
+		return nil, &ValidationError{Errors: errs}
+	}
+	return def.ResolveHiddenFields(func(name string) (string, bool) {
+		value, ok := values[name]
+		return value, ok
+	}), nil
+}
+
+// validateAnswer 通过题型注册表校验单个问题的答案，返回空字符串表示校验通过
+func validateAnswer(q *question.Question, raw json.RawMessage) string {
+	qt, ok := question.Lookup(q.Type)
+	if !ok {
+		return fmt.Sprintf("unsupported question type %q", q.Type)
+	}
+	if err := qt.ValidateAnswer(q, raw); err != nil {
+		return err.Error()
+	}
+	return ""
+}
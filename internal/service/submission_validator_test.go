@@ -0,0 +1,53 @@
+package service
+
+import (
+	"errors"
+	"questflow/internal/question"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestValidateHiddenFields(t *testing.T) {
+	def, err := question.ParseDefinition([]byte(`{
+		"questions": [],
+		"hiddenFields": [
+			{"name": "source", "default": "direct"},
+			{"name": "uid"}
+		]
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		values  map[string]string
+		want    map[string]string
+		wantErr string
+	}{
+		{"defaults", nil, map[string]string{"source": "direct"}, ""},
+		{"provided values", map[string]string{"source": "mail", "uid": "42"}, map[string]string{"source": "mail", "uid": "42"}, ""},
+		{"empty value overrides default", map[string]string{"source": ""}, map[string]string{"source": ""}, ""},
+		{"unknown field", map[string]string{"campaign": "x"}, nil, `unknown hidden field "campaign"`},
+		{"too long", map[string]string{"uid": strings.Repeat("字", question.MaxHiddenFieldLength+1)}, nil, `hidden field "uid" must be at most 255 characters`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ValidateHiddenFields(def, tt.values)
+			if tt.wantErr != "" {
+				var validationErr *ValidationError
+				if !errors.As(err, &validationErr) || len(validationErr.Errors) != 1 || validationErr.Errors[0].Message != tt.wantErr {
+					t.Fatalf("error = %v, want validation error %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ValidateHiddenFields = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
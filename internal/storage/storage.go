@@ -0,0 +1,63 @@
+// Package storage 定义了上传文件存储的抽象，以及本地文件系统和 S3 兼容对象存储两种实现
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"questflow/pkg/config"
+	"strings"
+)
+
+// 存储驱动名称，对应配置中的 storage.driver
+const (
+	DriverLocal = "local"
+	DriverS3    = "s3"
+)
+
+// ErrNotFound 表示指定 key 的文件不存在
+var ErrNotFound = errors.New("file not found in storage")
+
+// Storage 是上传文件存储的抽象，文件按 key 存取，key 由调用方生成，使用 "/" 分隔层级
+type Storage interface {
+	// Put 写入一个文件，size 为文件的字节数，已存在的同名文件会被覆盖
+	Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error
+	// Get 打开一个文件，调用方负责关闭返回的 ReadCloser；文件不存在时返回 ErrNotFound
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	// Delete 删除一个文件，文件不存在时不返回错误
+	Delete(ctx context.Context, key string) error
+}
+
+// New 根据配置中的 storage.driver 创建对应的存储实现，未配置时默认使用本地文件系统
+func New(ctx context.Context) (Storage, error) {
+	switch Driver() {
+	case DriverLocal:
+		return NewLocalStorage(config.Cfg.Storage.Local.Dir)
+	case DriverS3:
+		return NewS3Storage(ctx, config.Cfg.Storage.S3)
+	default:
+		return nil, fmt.Errorf("unknown storage driver %q", config.Cfg.Storage.Driver)
+	}
+}
+
+// Driver 返回当前配置的存储驱动名称
+func Driver() string {
+	if config.Cfg.Storage.Driver == "" {
+		return DriverLocal
+	}
+	return config.Cfg.Storage.Driver
+}
+
+// validKey 检查 key 是否是不含 ".." 等路径穿越片段的相对路径
+func validKey(key string) bool {
+	if key == "" || strings.HasPrefix(key, "/") || strings.Contains(key, "\\") {
+		return false
+	}
+	for _, part := range strings.Split(key, "/") {
+		if part == "" || part == "." || part == ".." {
+			return false
+		}
+	}
+	return true
+}
@@ -25,25 +25,72 @@ func (db *DBConfig) DSN() string {
 		db.User, db.Password, db.Host, db.Port, db.DBName, db.Params)
 }
 
+// S3Config 封装了 S3 兼容对象存储的配置项
+type S3Config struct {
+	Endpoint  string `mapstructure:"endpoint"`
+	Region    string `mapstructure:"region"`
+	Bucket    string `mapstructure:"bucket"`
+	AccessKey string `mapstructure:"access_key"`
+	SecretKey string `mapstructure:"secret_key"`
+	UseSSL    bool   `mapstructure:"use_ssl"`
+}
+
 // AppConfig 是应用配置的结构体
 type AppConfig struct {
 	App struct {
-		Port string `mapstructure:"port"`
-		Mode string `mapstructure:"mode"`
+		Port                   string `mapstructure:"port"`
+		Mode                   string `mapstructure:"mode"`
+		ShutdownTimeoutSeconds int    `mapstructure:"shutdown_timeout_seconds"`
 	} `mapstructure:"app"`
 	Database DBConfig `mapstructure:"database"`
-	Redis    struct {
-		Addr                string `mapstructure:"addr"`
-		Password            string `mapstructure:"password"`
-		DB                  int    `mapstructure:"db"`
-		SubmissionStreamKey string `mapstructure:"submission_stream_key"`
-		SubmissionGroupName string `mapstructure:"submission_group_name"`
+	Queue    struct {
+		Driver string `mapstructure:"driver"`
+	} `mapstructure:"queue"`
+	Redis struct {
+		Addr                          string `mapstructure:"addr"`
+		Password                      string `mapstructure:"password"`
+		DB                            int    `mapstructure:"db"`
+		SubmissionStreamKey           string `mapstructure:"submission_stream_key"`
+		SubmissionGroupName           string `mapstructure:"submission_group_name"`
+		SubmissionDeadLetterStreamKey string `mapstructure:"submission_dead_letter_stream_key"`
+		SubmissionStatusTTLHours      int    `mapstructure:"submission_status_ttl_hours"`
+		IdempotencyTTLHours           int    `mapstructure:"idempotency_ttl_hours"`
 	} `mapstructure:"redis"`
+	Consumer struct {
+		Name                 string `mapstructure:"name"`
+		InstanceID           string `mapstructure:"instance_id"`
+		Concurrency          int    `mapstructure:"concurrency"`
+		BatchSize            int    `mapstructure:"batch_size"`
+		BatchWaitMs          int    `mapstructure:"batch_wait_ms"`
+		MaxAttempts          int    `mapstructure:"max_attempts"`
+		RetryIntervalSeconds int    `mapstructure:"retry_interval_seconds"`
+		ClaimIdleSeconds     int    `mapstructure:"claim_idle_seconds"`
+		ClaimIntervalSeconds int    `mapstructure:"claim_interval_seconds"`
+	} `mapstructure:"consumer"`
+	Exam struct {
+		AttemptSecret      string `mapstructure:"attempt_secret"`
+		GracePeriodSeconds int    `mapstructure:"grace_period_seconds"`
+		LatePolicy         string `mapstructure:"late_policy"`
+		AttemptsPerMinute  int    `mapstructure:"attempts_per_minute"`
+	} `mapstructure:"exam"`
 	JWT struct {
 		Secret      string `mapstructure:"secret"`
 		Issuer      string `mapstructure:"issuer"`
 		ExpireHours int    `mapstructure:"expire_hours"`
 	} `mapstructure:"jwt"`
+	Storage struct {
+		Driver          string `mapstructure:"driver"`
+		MaxFileSizeMB   int    `mapstructure:"max_file_size_mb"`
+		DownloadBaseURL string `mapstructure:"download_base_url"`
+		Local           struct {
+			Dir string `mapstructure:"dir"`
+		} `mapstructure:"local"`
+		S3 S3Config `mapstructure:"s3"`
+
+		UnusedFileTTLHours  int `mapstructure:"unused_file_ttl_hours"`
+		UploadsPerMinute    int `mapstructure:"uploads_per_minute"`
+		FormUploadMBPerHour int `mapstructure:"form_upload_mb_per_hour"`
+	} `mapstructure:"storage"`
 }
 
 // Cfg 是一个全局的配置实例
@@ -34,6 +34,8 @@ func InitMySQL() {
 
 	DB, err = gorm.Open(mysql.Open(dsn), &gorm.Config{
 		Logger: newLogger,
+		// 将唯一索引冲突等驱动错误转换为 gorm.ErrDuplicatedKey 等通用错误
+		TranslateError: true,
 	})
 
 	if err != nil {
@@ -50,3 +52,15 @@ func InitMySQL() {
 
 	log.Println("Database connection successful.")
 }
+
+// Close 关闭数据库连接池
+func Close() error {
+	if DB == nil {
+		return nil
+	}
+	sqlDB, err := DB.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.Close()
+}
@@ -28,19 +28,16 @@ func InitRedis() {
 	log.Println("Redis connection successful.")
 }
 
-// PublishSubmissionMessage 封装了向 submission stream 发送消息的逻辑
-func PublishSubmissionMessage(ctx context.Context, payload []byte) (string, error) {
-	// 使用 redis.XAddArgs 来构造参数
-	args := &redis.XAddArgs{
-		Stream: config.Cfg.Redis.SubmissionStreamKey,
-		Values: map[string]interface{}{"payload": payload},
-	}
-
-	// 发送消息到 Redis Stream
-	messageID, err := RDB.XAdd(ctx, args).Result()
-	if err != nil {
-		return "", err
+// Close 关闭 Redis 客户端连接
+func Close() error {
+	if RDB == nil {
+		return nil
 	}
+	return RDB.Close()
+}
 
-	return messageID, nil
+// Enabled 报告 Redis 客户端是否已初始化
+// 队列驱动不是 redis 且未配置 redis.addr 时不会连接 Redis
+func Enabled() bool {
+	return RDB != nil
 }
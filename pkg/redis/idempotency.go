@@ -0,0 +1,70 @@
+// Package redis 负责初始化和管理 Redis 客户端连接
+package redis
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"questflow/pkg/config"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// defaultIdempotencyTTL 是未配置 idempotency_ttl_hours 时幂等键的保留时长
+const defaultIdempotencyTTL = 24 * time.Hour
+
+// idempotencyPendingTTL 是发布中的幂等键的保留时长：生产者在发布完成前崩溃时，
+// 占位记录很快过期，客户端重试不会长时间收到 "正在处理中"
+const idempotencyPendingTTL = time.Minute
+
+// ErrIdempotencyUnavailable 表示未启用 Redis，无法检查幂等键
+var ErrIdempotencyUnavailable = errors.New("idempotency keys require redis")
+
+// ReserveIdempotencyKey 使用 SETNX 占用某个表单下的幂等键，占位记录在 idempotencyPendingTTL 后过期
+// 占用成功时 reserved 为 true；已被占用时返回首次请求记录的消息ID，
+// 若消息ID为空，说明首次请求仍在发布中。
+// 未启用 Redis 时返回 ErrIdempotencyUnavailable
+func ReserveIdempotencyKey(ctx context.Context, formID uint, key string) (reserved bool, messageID string, err error) {
+	if !Enabled() {
+		return false, "", ErrIdempotencyUnavailable
+	}
+
+	redisKey := idempotencyKey(formID, key)
+	reserved, err = RDB.SetNX(ctx, redisKey, "", idempotencyPendingTTL).Result()
+	if err != nil || reserved {
+		return reserved, "", err
+	}
+
+	messageID, err = RDB.Get(ctx, redisKey).Result()
+	if errors.Is(err, redis.Nil) {
+		// 键恰好在两次调用之间过期，按首次请求处理
+		return ReserveIdempotencyKey(ctx, formID, key)
+	}
+	return false, messageID, err
+}
+
+// CompleteIdempotencyKey 在消息发布成功后记录对应的消息ID，并把保留时长延长到 idempotency_ttl_hours
+func CompleteIdempotencyKey(ctx context.Context, formID uint, key, messageID string) error {
+	if !Enabled() {
+		return nil
+	}
+	ttl := defaultIdempotencyTTL
+	if config.Cfg.Redis.IdempotencyTTLHours > 0 {
+		ttl = time.Duration(config.Cfg.Redis.IdempotencyTTLHours) * time.Hour
+	}
+	return RDB.Set(ctx, idempotencyKey(formID, key), messageID, ttl).Err()
+}
+
+// ReleaseIdempotencyKey 在发布失败时释放幂等键，允许客户端重试
+func ReleaseIdempotencyKey(ctx context.Context, formID uint, key string) error {
+	if !Enabled() {
+		return nil
+	}
+	return RDB.Del(ctx, idempotencyKey(formID, key)).Err()
+}
+
+// idempotencyKey 返回存储幂等键的 Redis key
+func idempotencyKey(formID uint, key string) string {
+	return fmt.Sprintf("%s:idempotency:%d:%s", config.Cfg.Redis.SubmissionStreamKey, formID, key)
+}
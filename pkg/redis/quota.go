@@ -0,0 +1,105 @@
+// Package redis 负责初始化和管理 Redis 客户端连接
+package redis
+
+import (
+	"context"
+	"fmt"
+	"questflow/pkg/config"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// reserveQuotaScript 原子地为一次提交占用多个计数器：KEYS 是计数器，ARGV 是对应的上限。
+// 任意一个计数器已达到上限时不修改任何计数器，返回该计数器的序号（从 1 开始）；全部占用成功时返回 0
+var reserveQuotaScript = redis.NewScript(`
+for i, key in ipairs(KEYS) do
+	if tonumber(redis.call('GET', key) or '0') >= tonumber(ARGV[i]) then
+		return i
+	end
+end
+for _, key in ipairs(KEYS) do
+	redis.call('INCR', key)
+end
+return 0
+`)
+
+// releaseQuotaScript 归还一次提交占用的计数器，计数不会减到 0 以下
+var releaseQuotaScript = redis.NewScript(`
+for _, key in ipairs(KEYS) do
+	if tonumber(redis.call('GET', key) or '0') > 0 then
+		redis.call('DECR', key)
+	end
+end
+return 0
+`)
+
+// raiseQuotaScript 把计数器提高到数据库中的实际提交数，ARGV 是对应的提交数；已经更大的计数器保持不变
+var raiseQuotaScript = redis.NewScript(`
+for i, key in ipairs(KEYS) do
+	if tonumber(redis.call('GET', key) or '0') < tonumber(ARGV[i]) then
+		redis.call('SET', key, ARGV[i])
+	end
+end
+return 0
+`)
+
+// QuotaKey 返回表单某个配额计数器的 key，name 为 "total" 时是总提交数的计数器
+func QuotaKey(formID uint, name string) string {
+	return fmt.Sprintf("%s:quota:%d:%s", config.Cfg.Redis.SubmissionStreamKey, formID, name)
+}
+
+// MissingQuotaKeys 返回尚未创建的计数器，调用方应先用数据库中的提交数初始化这些计数器
+func MissingQuotaKeys(ctx context.Context, keys []string) ([]string, error) {
+	pipe := RDB.Pipeline()
+	cmds := make([]*redis.IntCmd, len(keys))
+	for i, key := range keys {
+		cmds[i] = pipe.Exists(ctx, key)
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, err
+	}
+	var missing []string
+	for i, cmd := range cmds {
+		if cmd.Val() == 0 {
+			missing = append(missing, keys[i])
+		}
+	}
+	return missing, nil
+}
+
+// ReserveQuotas 原子地检查并占用计数器，limits 与 keys 一一对应。
+// 全部占用成功时返回 -1，否则返回第一个已满的计数器在 keys 中的下标
+func ReserveQuotas(ctx context.Context, keys []string, limits []int64) (int, error) {
+	args := make([]interface{}, len(limits))
+	for i, limit := range limits {
+		args[i] = limit
+	}
+	full, err := reserveQuotaScript.Run(ctx, RDB, keys, args...).Int()
+	if err != nil {
+		return 0, err
+	}
+	return full - 1, nil
+}
+
+// ReleaseQuotas 在提交没有进入队列或最终未能写入数据库时归还占用的计数器
+func ReleaseQuotas(ctx context.Context, keys []string) error {
+	if len(keys) == 0 || !Enabled() {
+		return nil
+	}
+	return releaseQuotaScript.Run(ctx, RDB, keys).Err()
+}
+
+// RaiseQuotaCounters 用数据库中的提交数校准计数器：计数器丢失或小于实际提交数时提高到实际提交数。
+// 计数器大于实际提交数是正常的，差值是已入队但尚未写入数据库的提交
+func RaiseQuotaCounters(ctx context.Context, counts map[string]int64) error {
+	if len(counts) == 0 || !Enabled() {
+		return nil
+	}
+	keys := make([]string, 0, len(counts))
+	args := make([]interface{}, 0, len(counts))
+	for key, count := range counts {
+		keys = append(keys, key)
+		args = append(args, count)
+	}
+	return raiseQuotaScript.Run(ctx, RDB, keys, args...).Err()
+}
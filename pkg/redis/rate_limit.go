@@ -0,0 +1,78 @@
+// Package redis 负责初始化和管理 Redis 客户端连接
+package redis
+
+import (
+	"context"
+	"questflow/pkg/config"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// maxLocalWindows 是进程内限流记录的数量上限，超过时清理已过期的窗口
+const maxLocalWindows = 10000
+
+// rateLimitScript 把 KEYS[1] 的计数增加 ARGV[2]，计数还没有过期时间（窗口内第一次计数）时
+// 设置过期时间 ARGV[1]（毫秒），返回当前计数
+var rateLimitScript = redis.NewScript(`
+local n = redis.call('INCRBY', KEYS[1], ARGV[2])
+if redis.call('PTTL', KEYS[1]) < 0 then
+	redis.call('PEXPIRE', KEYS[1], ARGV[1])
+end
+return n
+`)
+
+// Allow 按固定窗口限制 key 对应的操作次数：每个窗口内最多 limit 次，超过时返回 false
+// 未启用 Redis 时在进程内计数，多个 API 实例之间不共享计数
+func Allow(ctx context.Context, key string, limit int, window time.Duration) (bool, error) {
+	return AllowN(ctx, key, 1, int64(limit), window)
+}
+
+// AllowN 与 Allow 相同，但一次操作计 n 个单位，例如按上传的字节数限制总量。
+// 超过上限的操作同样会计入窗口内的计数
+func AllowN(ctx context.Context, key string, n, limit int64, window time.Duration) (bool, error) {
+	if !Enabled() {
+		return localLimiter.allow(key, n, limit, window, time.Now()), nil
+	}
+	redisKey := config.Cfg.Redis.SubmissionStreamKey + ":ratelimit:" + key
+	count, err := rateLimitScript.Run(ctx, RDB, []string{redisKey}, window.Milliseconds(), n).Int64()
+	if err != nil {
+		return false, err
+	}
+	return count <= limit, nil
+}
+
+// localWindow 是进程内的一个限流窗口
+type localWindow struct {
+	count   int64
+	resetAt time.Time
+}
+
+// localRateLimiter 是未启用 Redis 时使用的进程内固定窗口限流
+type localRateLimiter struct {
+	mu      sync.Mutex
+	windows map[string]*localWindow
+}
+
+var localLimiter = &localRateLimiter{windows: make(map[string]*localWindow)}
+
+func (l *localRateLimiter) allow(key string, n, limit int64, window time.Duration, now time.Time) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	w, ok := l.windows[key]
+	if !ok || !now.Before(w.resetAt) {
+		if len(l.windows) >= maxLocalWindows {
+			for k, old := range l.windows {
+				if !now.Before(old.resetAt) {
+					delete(l.windows, k)
+				}
+			}
+		}
+		w = &localWindow{resetAt: now.Add(window)}
+		l.windows[key] = w
+	}
+	w.count += n
+	return w.count <= limit
+}
@@ -0,0 +1,110 @@
+// Package redis 负责初始化和管理 Redis 客户端连接
+package redis
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"questflow/pkg/config"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// 提交消息的处理状态
+const (
+	SubmissionStatusQueued       = "queued"        // 已进入队列，等待处理
+	SubmissionStatusPersisted    = "persisted"     // 已写入数据库
+	SubmissionStatusFailed       = "failed"        // 处理失败，等待重试
+	SubmissionStatusDeadLettered = "dead_lettered" // 多次失败后进入死信队列
+	SubmissionStatusRedriven     = "redriven"      // 死信已被重新投递，后续状态记录在 RedrivenTo 指向的新消息ID下
+)
+
+// defaultSubmissionStatusTTL 是未配置 submission_status_ttl_hours 时状态记录的保留时长
+const defaultSubmissionStatusTTL = 24 * time.Hour
+
+// ErrSubmissionStatusNotFound 表示状态记录不存在或已过期
+var ErrSubmissionStatusNotFound = errors.New("submission status not found")
+
+// SubmissionStatus 记录了一条提交消息的最新处理结果
+type SubmissionStatus struct {
+	MessageID    string    `json:"message_id"`
+	Status       string    `json:"status"`
+	SubmissionID uint      `json:"submission_id,omitempty"` // 仅在 persisted 状态下有值
+	Attempts     int64     `json:"attempts,omitempty"`
+	UpdatedAt    time.Time `json:"updated_at"`
+
+	RedrivenTo string `json:"redriven_to,omitempty"` // 仅在 redriven 状态下有值
+}
+
+// SetSubmissionStatuses 批量写入提交状态，每条记录都带有过期时间
+// 未启用 Redis 时不记录状态
+func SetSubmissionStatuses(ctx context.Context, statuses ...SubmissionStatus) error {
+	if len(statuses) == 0 || !Enabled() {
+		return nil
+	}
+	ttl := submissionStatusTTL()
+
+	pipe := RDB.Pipeline()
+	for _, st := range statuses {
+		if st.UpdatedAt.IsZero() {
+			st.UpdatedAt = time.Now()
+		}
+		value, err := json.Marshal(st)
+		if err != nil {
+			return err
+		}
+		pipe.Set(ctx, submissionStatusKey(st.MessageID), value, ttl)
+	}
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// SetQueuedSubmissionStatus 在消息发布后记录 queued 状态，只在还没有状态记录时写入：
+// consumer 可能在发布返回之前就已经处理完消息，此时保留它写入的 persisted 或 dead_lettered 状态
+func SetQueuedSubmissionStatus(ctx context.Context, messageID string) error {
+	if !Enabled() {
+		return nil
+	}
+	value, err := json.Marshal(SubmissionStatus{
+		MessageID: messageID,
+		Status:    SubmissionStatusQueued,
+		UpdatedAt: time.Now(),
+	})
+	if err != nil {
+		return err
+	}
+	return RDB.SetNX(ctx, submissionStatusKey(messageID), value, submissionStatusTTL()).Err()
+}
+
+// GetSubmissionStatus 查询一条提交消息的处理状态
+func GetSubmissionStatus(ctx context.Context, messageID string) (*SubmissionStatus, error) {
+	if !Enabled() {
+		return nil, ErrSubmissionStatusNotFound
+	}
+	value, err := RDB.Get(ctx, submissionStatusKey(messageID)).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, ErrSubmissionStatusNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	var st SubmissionStatus
+	if err := json.Unmarshal(value, &st); err != nil {
+		return nil, err
+	}
+	return &st, nil
+}
+
+// submissionStatusTTL 返回状态记录的保留时长
+func submissionStatusTTL() time.Duration {
+	if config.Cfg.Redis.SubmissionStatusTTLHours > 0 {
+		return time.Duration(config.Cfg.Redis.SubmissionStatusTTLHours) * time.Hour
+	}
+	return defaultSubmissionStatusTTL
+}
+
+// submissionStatusKey 返回存储某条消息状态的 key
+func submissionStatusKey(messageID string) string {
+	return config.Cfg.Redis.SubmissionStreamKey + ":status:" + messageID
+}